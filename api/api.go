@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+)
+
+// IgnoredCategory is the category of the pull requests that don't need action from the team
+const IgnoredCategory = hosts.IgnoredCategory
+
+// Roles of a user in a pull request, used to filter pull requests
+const (
+	authorRole   = "author"
+	reviewerRole = "reviewer"
+)
+
+// TeamSnapshot is the state of a team's pull requests at its last run
+type TeamSnapshot struct {
+	Team         string        `json:"team"`
+	FetchedAt    time.Time     `json:"fetched_at"`
+	Repositories []*Repository `json:"repositories"`
+}
+
+// Repository is a repository and its open pull requests
+type Repository struct {
+	Name         string         `json:"name"`
+	Link         string         `json:"link"`
+	Host         string         `json:"host"`
+	PullRequests []*PullRequest `json:"pull_requests"`
+}
+
+// PullRequest is an open pull request and its category
+type PullRequest struct {
+	Title      string    `json:"title"`
+	Link       string    `json:"link"`
+	Author     User      `json:"author"`
+	CreateTime time.Time `json:"create_time"`
+	UpdateTime time.Time `json:"update_time"`
+	Size       int       `json:"size,omitempty"`
+	// Category is ready_to_merge, ready_to_review, changes_requested or ignored. Reason (ex: wip) and Explanation (ex: Marked WIP) tell why
+	Category    string `json:"category"`
+	Reason      string `json:"reason,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+	// IgnoredReason is the explanation of ignored pull requests. Deprecated: it is kept for compatibility, use Reason and Explanation
+	IgnoredReason   string      `json:"ignored_reason,omitempty"`
+	Approvals       int         `json:"approvals"`
+	NeededApprovals int         `json:"needed_approvals"`
+	ClaimedBy       string      `json:"claimed_by,omitempty"`
+	Reviewers       []*Reviewer `json:"reviewers"`
+}
+
+// Reviewer is a reviewer of a pull request
+type Reviewer struct {
+	User
+	Approved         bool `json:"approved"`
+	RequestedChanges bool `json:"requested_changes"`
+	Absent           bool `json:"absent"`
+	// TeamMember is true if the reviewer is one of the team's users. Only the team members' reviews are counted
+	TeamMember bool `json:"team_member"`
+}
+
+// User is the author or a reviewer of a pull request
+type User struct {
+	Name           string `json:"name"`
+	GithubUsername string `json:"github_username,omitempty"`
+	BitbucketUUID  string `json:"bitbucket_uuid,omitempty"`
+}
+
+// NewTeamSnapshot builds the snapshot of the pull requests of the given repositories, including the ignored ones
+func NewTeamSnapshot(team *config.TeamConfig, repositories []hosts.Repository, fetchedAt time.Time) *TeamSnapshot {
+	teamMembers := map[string]bool{}
+	for _, user := range team.Users {
+		teamMembers[user.Name] = true
+	}
+	newPullRequest := func(pullRequest *hosts.PullRequest, category string) *PullRequest {
+		snapshot := &PullRequest{
+			Title:           pullRequest.Title,
+			Link:            pullRequest.Link,
+			Author:          newUser(pullRequest.Author),
+			CreateTime:      pullRequest.CreateTime,
+			UpdateTime:      pullRequest.UpdateTime,
+			Size:            pullRequest.Size,
+			Category:        category,
+			NeededApprovals: team.GetNumberOfNeededApprovals(),
+			Reviewers:       []*Reviewer{},
+		}
+		if pullRequest.State != nil {
+			snapshot.ClaimedBy = pullRequest.State.ClaimedBy
+		}
+		if pullRequest.Verdict != nil {
+			snapshot.Reason, snapshot.Explanation = pullRequest.Verdict.Reason, pullRequest.Verdict.Explanation
+			if pullRequest.Verdict.IsIgnored() {
+				snapshot.IgnoredReason = pullRequest.Verdict.Explanation
+			}
+		}
+		for _, reviewer := range pullRequest.Reviewers {
+			teamMember := reviewer.User.Name != "" && teamMembers[reviewer.User.Name]
+			if teamMember && reviewer.Approved {
+				snapshot.Approvals++
+			}
+			snapshot.Reviewers = append(snapshot.Reviewers, &Reviewer{
+				User:             newUser(reviewer.User),
+				Approved:         reviewer.Approved,
+				RequestedChanges: reviewer.RequestedChanges,
+				Absent:           reviewer.Absent,
+				TeamMember:       teamMember,
+			})
+		}
+		return snapshot
+	}
+
+	snapshot := &TeamSnapshot{Team: team.Name, FetchedAt: fetchedAt, Repositories: []*Repository{}}
+	for _, repository := range repositories {
+		repositorySnapshot := &Repository{Name: repository.GetName(), Link: repository.GetLink(), PullRequests: []*PullRequest{}}
+		if host := repository.GetHost(); host != nil {
+			repositorySnapshot.Host = host.GetName()
+		}
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		for _, category := range []struct {
+			name         string
+			pullRequests []*hosts.PullRequest
+		}{
+			{hosts.ReadyToMergeCategory, readyToMerge},
+			{hosts.ReadyToReviewCategory, readyToReview},
+			{hosts.ChangesRequestedCategory, changesRequested},
+		} {
+			for _, pullRequest := range category.pullRequests {
+				repositorySnapshot.PullRequests = append(repositorySnapshot.PullRequests, newPullRequest(pullRequest, category.name))
+			}
+		}
+		for _, pullRequest := range repository.GetIgnoredPullRequests() {
+			repositorySnapshot.PullRequests = append(repositorySnapshot.PullRequests, newPullRequest(pullRequest, IgnoredCategory))
+		}
+		snapshot.Repositories = append(snapshot.Repositories, repositorySnapshot)
+	}
+	return snapshot
+}
+
+func newUser(user config.User) User {
+	return User{Name: user.Name, GithubUsername: user.GithubUsername, BitbucketUUID: user.BitbucketUUID}
+}
+
+// is returns true if the given name, Github username or Bitbucket UUID is the user's (case is ignored)
+func (user User) is(name string) bool {
+	for _, identifier := range []string{user.Name, user.GithubUsername, user.BitbucketUUID} {
+		if identifier != "" && strings.EqualFold(identifier, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns a copy of the snapshot with only the pull requests of the given category that involve the given user,
+// as author or reviewer depending on the given role. Empty filters match all pull requests
+func (snapshot *TeamSnapshot) Filter(user, role, category string) *TeamSnapshot {
+	return snapshot.filter(func(pullRequest *PullRequest) bool {
+		if category != "" && pullRequest.Category != category {
+			return false
+		}
+		return user == "" || isUserInvolved(pullRequest, user, role)
+	})
+}
+
+// filter returns a copy of the snapshot with only the pull requests matching the given filter
+func (snapshot *TeamSnapshot) filter(keep func(*PullRequest) bool) *TeamSnapshot {
+	filtered := &TeamSnapshot{Team: snapshot.Team, FetchedAt: snapshot.FetchedAt, Repositories: []*Repository{}}
+	for _, repository := range snapshot.Repositories {
+		filteredRepository := *repository
+		filteredRepository.PullRequests = []*PullRequest{}
+		for _, pullRequest := range repository.PullRequests {
+			if keep(pullRequest) {
+				filteredRepository.PullRequests = append(filteredRepository.PullRequests, pullRequest)
+			}
+		}
+		if len(filteredRepository.PullRequests) > 0 {
+			filtered.Repositories = append(filtered.Repositories, &filteredRepository)
+		}
+	}
+	return filtered
+}
+
+// Snapshots keeps the last snapshot of each team
+type Snapshots struct {
+	mutex     sync.RWMutex
+	snapshots map[string]*TeamSnapshot
+}
+
+// NewSnapshots creates an empty Snapshots instance
+func NewSnapshots() *Snapshots {
+	return &Snapshots{snapshots: map[string]*TeamSnapshot{}}
+}
+
+// Set replaces the snapshot of the team
+func (snapshots *Snapshots) Set(snapshot *TeamSnapshot) {
+	snapshots.mutex.Lock()
+	defer snapshots.mutex.Unlock()
+	snapshots.snapshots[snapshot.Team] = snapshot
+}
+
+// Get returns the last snapshot of the given team. It is nil if the team has not been run yet
+func (snapshots *Snapshots) Get(team string) *TeamSnapshot {
+	snapshots.mutex.RLock()
+	defer snapshots.mutex.RUnlock()
+	return snapshots.snapshots[team]
+}
+
+// List returns the last snapshot of all teams, sorted by team name
+func (snapshots *Snapshots) List() []*TeamSnapshot {
+	snapshots.mutex.RLock()
+	defer snapshots.mutex.RUnlock()
+	list := []*TeamSnapshot{}
+	for _, snapshot := range snapshots.snapshots {
+		list = append(list, snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Team < list[j].Team })
+	return list
+}
+
+// teamSummary is a team listed by the API
+type teamSummary struct {
+	Team      string    `json:"team"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// NewHandler returns the handler of the read-only API. It serves:
+//   - /api/teams: The teams that have been run
+//   - /api/teams/<team>: The team's last snapshot. The pull requests can be filtered by user (?user=<name>), optionally with
+//     the user's role (?role=author or ?role=reviewer), and by category (?category=ready_to_review)
+func NewHandler(snapshots *Snapshots) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/teams", func(writer http.ResponseWriter, request *http.Request) {
+		teams := []teamSummary{}
+		for _, snapshot := range snapshots.List() {
+			teams = append(teams, teamSummary{Team: snapshot.Team, FetchedAt: snapshot.FetchedAt})
+		}
+		writeJSON(writer, teams)
+	})
+	mux.HandleFunc("/api/teams/", func(writer http.ResponseWriter, request *http.Request) {
+		team := strings.TrimPrefix(request.URL.Path, "/api/teams/")
+		snapshot := snapshots.Get(team)
+		if snapshot == nil {
+			http.Error(writer, "Unknown team or the team has not been run yet", http.StatusNotFound)
+			return
+		}
+
+		query := request.URL.Query()
+		user, role, category := query.Get("user"), query.Get("role"), query.Get("category")
+		if role != "" && role != authorRole && role != reviewerRole {
+			http.Error(writer, "The role must be author or reviewer", http.StatusBadRequest)
+			return
+		}
+		writeJSON(writer, snapshot.Filter(user, role, category))
+	})
+	return mux
+}
+
+// isUserInvolved returns true if the user is the pull request's author or one of its reviewers, depending on the given role
+func isUserInvolved(pullRequest *PullRequest, user, role string) bool {
+	if role != reviewerRole && pullRequest.Author.is(user) {
+		return true
+	}
+	if role != authorRole {
+		for _, reviewer := range pullRequest.Reviewers {
+			if reviewer.is(user) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJSON(writer http.ResponseWriter, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(value)
+}
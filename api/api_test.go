@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSnapshot(ctrl *gomock.Controller) *TeamSnapshot {
+	author := config.User{Name: "author", GithubUsername: "author-gh"}
+	reviewer := config.User{Name: "reviewer"}
+	team := &config.TeamConfig{Name: "my-team", NumberOfApprovals: 2, Users: []config.User{author, reviewer}}
+
+	host := hosts.NewMockHost(ctrl)
+	host.EXPECT().GetName().Return("Github").AnyTimes()
+	repository := hosts.NewMockRepository(ctrl)
+	repository.EXPECT().GetName().Return("repo").AnyTimes()
+	repository.EXPECT().GetLink().Return("repo.com").AnyTimes()
+	repository.EXPECT().GetHost().Return(host).AnyTimes()
+	repository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*hosts.PullRequest{},
+		[]*hosts.PullRequest{{Title: "pr1", Link: "pr1.com", Author: author, State: &state.PullRequestState{ClaimedBy: "reviewer"}, Reviewers: []*hosts.Reviewer{
+			{User: reviewer, Approved: true},
+			{User: config.User{Name: "outsider"}, Approved: true},
+		}}},
+		[]*hosts.PullRequest{},
+	).AnyTimes()
+	repository.EXPECT().GetIgnoredPullRequests().Return([]*hosts.PullRequest{
+		{Title: "wip", Link: "wip.com", Author: reviewer, Verdict: &hosts.Verdict{Category: hosts.IgnoredCategory, Reason: hosts.WIPReason, Explanation: "Marked WIP"}},
+	}).AnyTimes()
+	emptyRepository := hosts.NewMockRepository(ctrl)
+	emptyRepository.EXPECT().GetName().Return("empty").AnyTimes()
+	emptyRepository.EXPECT().GetLink().Return("empty.com").AnyTimes()
+	emptyRepository.EXPECT().GetHost().Return(nil).AnyTimes()
+	emptyRepository.EXPECT().GetPullRequestsToDisplay().Return([]*hosts.PullRequest{}, []*hosts.PullRequest{}, []*hosts.PullRequest{}).AnyTimes()
+	emptyRepository.EXPECT().GetIgnoredPullRequests().Return([]*hosts.PullRequest{}).AnyTimes()
+
+	return NewTeamSnapshot(team, []hosts.Repository{repository, emptyRepository}, time.Date(2019, 7, 22, 9, 0, 0, 0, time.UTC))
+}
+
+func TestNewTeamSnapshot(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	snapshot := newTestSnapshot(ctrl)
+	assert.Equal(t, "my-team", snapshot.Team)
+	assert.Len(t, snapshot.Repositories, 2)
+	repository := snapshot.Repositories[0]
+	assert.Equal(t, "Github", repository.Host)
+	assert.Len(t, repository.PullRequests, 2)
+
+	pullRequest := repository.PullRequests[0]
+	assert.Equal(t, hosts.ReadyToReviewCategory, pullRequest.Category)
+	assert.Equal(t, 1, pullRequest.Approvals) // The outsider's approval doesn't count
+	assert.Equal(t, 2, pullRequest.NeededApprovals)
+	assert.Equal(t, "reviewer", pullRequest.ClaimedBy)
+	assert.True(t, pullRequest.Reviewers[0].TeamMember)
+	assert.False(t, pullRequest.Reviewers[1].TeamMember)
+
+	ignored := repository.PullRequests[1]
+	assert.Equal(t, IgnoredCategory, ignored.Category)
+	assert.Equal(t, hosts.WIPReason, ignored.Reason)
+	assert.Equal(t, "Marked WIP", ignored.Explanation)
+	assert.Equal(t, "Marked WIP", ignored.IgnoredReason) // Kept for compatibility
+	assert.Equal(t, "", pullRequest.IgnoredReason)
+	assert.Equal(t, "", snapshot.Repositories[1].Host)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	snapshots := NewSnapshots()
+	snapshots.Set(newTestSnapshot(ctrl))
+	handler := NewHandler(snapshots)
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+	getTitles := func(url string) []string {
+		recorder := get(url)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		snapshot := &TeamSnapshot{}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), snapshot))
+		titles := []string{}
+		for _, repository := range snapshot.Repositories {
+			for _, pullRequest := range repository.PullRequests {
+				titles = append(titles, pullRequest.Title)
+			}
+		}
+		return titles
+	}
+
+	recorder := get("/api/teams")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[{"team": "my-team", "fetched_at": "2019-07-22T09:00:00Z"}]`, recorder.Body.String())
+
+	assert.Equal(t, []string{"pr1", "wip"}, getTitles("/api/teams/my-team"))
+	assert.Equal(t, []string{"wip"}, getTitles("/api/teams/my-team?category=ignored"))
+	assert.Equal(t, []string{"pr1"}, getTitles("/api/teams/my-team?user=AUTHOR-GH"))
+	assert.Equal(t, []string{"pr1", "wip"}, getTitles("/api/teams/my-team?user=reviewer"))
+	assert.Equal(t, []string{"pr1"}, getTitles("/api/teams/my-team?user=reviewer&role=reviewer"))
+	assert.Equal(t, []string{"wip"}, getTitles("/api/teams/my-team?user=reviewer&role=author"))
+	assert.Equal(t, []string{}, getTitles("/api/teams/my-team?user=nobody"))
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/teams/my-team?user=reviewer&role=owner").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/teams/other-team").Code)
+}
@@ -0,0 +1,315 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// downloadTimeout is the longest time to wait for a calendar to be downloaded
+const downloadTimeout = 30 * time.Second
+
+// recurrenceHorizonYears is how far in the future recurring events without an end (COUNT or UNTIL) are expanded.
+// Calendars are loaded again with the configuration, so the horizon moves forward
+const recurrenceHorizonYears = 1
+
+var httpClient = &http.Client{Timeout: downloadTimeout}
+
+// Event is a period read from an iCalendar file
+type Event struct {
+	Summary string
+	Start   time.Time
+	// End is exclusive
+	End time.Time
+}
+
+// Contains returns true if the given time is within the event
+func (event Event) Contains(at time.Time) bool {
+	return !at.Before(event.Start) && at.Before(event.End)
+}
+
+// IsDuring returns true if the given time is within one of the given events
+func IsDuring(events []Event, at time.Time) bool {
+	for _, event := range events {
+		if event.Contains(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge returns the given events sorted by start, with the overlapping events merged together
+func Merge(events []Event) []Event {
+	sorted := append([]Event{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	merged := []Event{}
+	for _, event := range sorted {
+		if last := len(merged) - 1; last >= 0 && !event.Start.After(merged[last].End) {
+			if event.End.After(merged[last].End) {
+				merged[last].End = event.End
+			}
+			continue
+		}
+		merged = append(merged, event)
+	}
+	return merged
+}
+
+// Load reads the events of the iCalendar file at the given path or URL (http or https).
+// Dates without a time zone are read in the given location
+func Load(path string, location *time.Location) ([]Event, error) {
+	var reader io.ReadCloser
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		response, err := httpClient.Get(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to download the calendar %s: %v", path, err)
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return nil, fmt.Errorf("Unable to download the calendar %s: %s", path, response.Status)
+		}
+		reader = response.Body
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the calendar %s: %v", path, err)
+		}
+		reader = file
+	}
+	defer reader.Close()
+	return Parse(reader, location)
+}
+
+// Parse reads the events (VEVENT) of an iCalendar stream. All-day events last until the end of their last day.
+// Recurring events are expanded (see expand). Dates without a time zone are read in the given location
+func Parse(reader io.Reader, location *time.Location) ([]Event, error) {
+	lines, err := unfoldLines(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the calendar: %v", err)
+	}
+
+	horizon := time.Now().AddDate(recurrenceHorizonYears, 0, 0)
+	events := []Event{}
+	var current *Event
+	var allDay bool
+	var rule string
+	var excluded []time.Time
+	for _, line := range lines {
+		name, parameters, value := parseLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current, allDay, rule, excluded = &Event{}, false, "", nil
+		case current == nil:
+			continue
+		case name == "END" && value == "VEVENT":
+			if current.Start.IsZero() {
+				return nil, fmt.Errorf("The %q event has no start date", current.Summary)
+			}
+			if current.End.IsZero() {
+				current.End = current.Start
+				if allDay {
+					current.End = current.Start.AddDate(0, 0, 1)
+				}
+			}
+			occurrences, err := expand(*current, rule, excluded, location, horizon)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, occurrences...)
+			current = nil
+		case name == "SUMMARY":
+			current.Summary = value
+		case name == "RRULE":
+			rule = value
+		case name == "EXDATE":
+			for _, exdate := range strings.Split(value, ",") {
+				date, _, err := parseDate(parameters, exdate, location)
+				if err != nil {
+					return nil, err
+				}
+				excluded = append(excluded, date)
+			}
+		case name == "DTSTART" || name == "DTEND":
+			date, isDate, err := parseDate(parameters, value, location)
+			if err != nil {
+				return nil, err
+			}
+			if name == "DTSTART" {
+				current.Start, allDay = date, isDate
+			} else {
+				current.End = date
+			}
+		}
+	}
+	return events, nil
+}
+
+// expand returns the occurrences of the event according to its recurrence rule (RRULE), without the excluded dates (EXDATE).
+// The FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL and, for weekly rules, BYDAY parts are supported.
+// Events with other rules only keep their first occurrence. Rules without COUNT or UNTIL are expanded until the given horizon
+func expand(event Event, rule string, excluded []time.Time, location *time.Location, horizon time.Time) ([]Event, error) {
+	if rule == "" {
+		return []Event{event}, nil
+	}
+	var unsupported = func(reason string) ([]Event, error) {
+		log.Warningf("Only the first occurrence of the %q calendar event is used: %s are not supported (%s)", event.Summary, reason, rule)
+		return []Event{event}, nil
+	}
+
+	var years, months, days int
+	interval, count, until, weekdays := 1, 0, horizon, map[time.Weekday]bool{}
+	for _, part := range strings.Split(rule, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, fmt.Errorf("Invalid recurrence rule %q of the %q event", rule, event.Summary)
+		}
+		key, value := strings.ToUpper(keyValue[0]), keyValue[1]
+		var err error
+		switch key {
+		case "FREQ":
+			switch strings.ToUpper(value) {
+			case "DAILY":
+				days = 1
+			case "WEEKLY":
+				days = 7
+			case "MONTHLY":
+				months = 1
+			case "YEARLY":
+				years = 1
+			default:
+				return unsupported(strings.ToLower(value) + " recurrences")
+			}
+		case "INTERVAL":
+			interval, err = strconv.Atoi(value)
+		case "COUNT":
+			count, err = strconv.Atoi(value)
+		case "UNTIL":
+			until, _, err = parseDate(map[string]string{}, value, location)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := parseWeekday(day)
+				if !ok {
+					return unsupported("BYDAY rules with positions")
+				}
+				weekdays[weekday] = true
+			}
+		case "WKST":
+			// Weeks always start on Monday
+		default:
+			return unsupported(key + " rules")
+		}
+		if err != nil || interval < 1 || count < 0 {
+			return nil, fmt.Errorf("Invalid recurrence rule %q of the %q event", rule, event.Summary)
+		}
+	}
+	if len(weekdays) > 0 && days != 7 {
+		return unsupported("BYDAY rules of non-weekly recurrences")
+	}
+
+	isExcluded := map[int64]bool{}
+	for _, date := range excluded {
+		isExcluded[date.Unix()] = true
+	}
+	occurrences := []Event{}
+	found := 0
+	// Weekly recurrences with days are expanded day by day, weeks starting on Monday
+	weekOffset := (int(event.Start.Weekday()) + 6) % 7
+	for index := 0; count == 0 || found < count; index++ {
+		var offsetYears, offsetMonths, offsetDays int
+		if len(weekdays) > 0 {
+			offsetDays = index
+		} else {
+			offsetYears, offsetMonths, offsetDays = index*interval*years, index*interval*months, index*interval*days
+		}
+		start := event.Start.AddDate(offsetYears, offsetMonths, offsetDays)
+		if start.After(until) {
+			break
+		}
+		if len(weekdays) > 0 {
+			if (index+weekOffset)/7%interval != 0 || !weekdays[start.Weekday()] {
+				continue
+			}
+		} else if start.Day() != event.Start.Day() {
+			continue // Skip the months that don't have the day (ex: the 31st)
+		}
+		found++
+		if !isExcluded[start.Unix()] {
+			occurrences = append(occurrences, Event{Summary: event.Summary, Start: start, End: event.End.AddDate(offsetYears, offsetMonths, offsetDays)})
+		}
+	}
+	return occurrences, nil
+}
+
+// parseWeekday parses a two-letter weekday (ex: MO)
+func parseWeekday(day string) (time.Weekday, bool) {
+	for weekday, name := range []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"} {
+		if strings.ToUpper(day) == name {
+			return time.Weekday(weekday), true
+		}
+	}
+	return 0, false
+}
+
+// unfoldLines returns the content lines of the stream. Long lines are folded on multiple lines starting with a space or a tab
+func unfoldLines(reader io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line (ex: DTSTART;TZID=America/Montreal:20190715T090000) into its name, its parameters and its value
+func parseLine(line string) (string, map[string]string, string) {
+	parameters := map[string]string{}
+	separator := strings.Index(line, ":")
+	if separator < 0 {
+		return strings.ToUpper(line), parameters, ""
+	}
+	nameAndParameters := strings.Split(line[:separator], ";")
+	for _, parameter := range nameAndParameters[1:] {
+		if keyValue := strings.SplitN(parameter, "=", 2); len(keyValue) == 2 {
+			parameters[strings.ToUpper(keyValue[0])] = strings.Trim(keyValue[1], `"`)
+		}
+	}
+	return strings.ToUpper(nameAndParameters[0]), parameters, line[separator+1:]
+}
+
+// parseDate parses a DATE (20190715) or a DATE-TIME (20190715T090000, in UTC if it ends with Z) value. It returns true if the value is a date
+func parseDate(parameters map[string]string, value string, location *time.Location) (time.Time, bool, error) {
+	if tzid, ok := parameters["TZID"]; ok {
+		if tzidLocation, err := time.LoadLocation(tzid); err == nil {
+			location = tzidLocation
+		}
+	}
+	if parameters["VALUE"] == "DATE" || len(value) == len("20060102") {
+		date, err := time.ParseInLocation("20060102", value, location)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("Invalid calendar date %q: %v", value, err)
+		}
+		return date, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		location = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	}
+	date, err := time.ParseInLocation("20060102T150405", value, location)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("Invalid calendar date %q: %v", value, err)
+	}
+	return date, false, nil
+}
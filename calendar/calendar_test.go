@@ -0,0 +1,183 @@
+package calendar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Vacation in the\r\n" +
+	"  mountains\r\n" +
+	"DTSTART;VALUE=DATE:20190715\r\n" +
+	"DTEND;VALUE=DATE:20190720\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Dentist\r\n" +
+	"DTSTART;TZID=America/Montreal:20190722T090000\r\n" +
+	"DTEND;TZID=America/Montreal:20190722T110000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Holiday\r\n" +
+	"DTSTART;VALUE=DATE:20190701\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Conference\r\n" +
+	"DTSTART:20190801T130000Z\r\n" +
+	"DTEND:20190801T150000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	montreal, _ := time.LoadLocation("America/Montreal")
+	events, err := Parse(strings.NewReader(testCalendar), tokyo)
+	assert.Nil(t, err)
+	assert.Len(t, events, 4)
+
+	assert.Equal(t, "Vacation in the mountains", events[0].Summary)
+	assert.True(t, time.Date(2019, 7, 15, 0, 0, 0, 0, tokyo).Equal(events[0].Start))
+	assert.True(t, time.Date(2019, 7, 20, 0, 0, 0, 0, tokyo).Equal(events[0].End))
+	assert.True(t, events[0].Contains(time.Date(2019, 7, 19, 23, 59, 0, 0, tokyo)))
+	assert.False(t, events[0].Contains(time.Date(2019, 7, 20, 0, 0, 0, 0, tokyo)))
+
+	assert.True(t, time.Date(2019, 7, 22, 9, 0, 0, 0, montreal).Equal(events[1].Start))
+	assert.True(t, time.Date(2019, 7, 22, 11, 0, 0, 0, montreal).Equal(events[1].End))
+
+	// All-day events without an end last one day
+	assert.True(t, time.Date(2019, 7, 2, 0, 0, 0, 0, tokyo).Equal(events[2].End))
+
+	assert.True(t, time.Date(2019, 8, 1, 13, 0, 0, 0, time.UTC).Equal(events[3].Start))
+
+	assert.True(t, IsDuring(events, time.Date(2019, 7, 1, 12, 0, 0, 0, tokyo)))
+	assert.False(t, IsDuring(events, time.Date(2019, 7, 3, 12, 0, 0, 0, tokyo)))
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	day := func(day int) time.Time { return time.Date(2019, 7, day, 0, 0, 0, 0, time.UTC) }
+	merged := Merge([]Event{
+		{Summary: "third", Start: day(20), End: day(21)},
+		{Summary: "first", Start: day(1), End: day(3)},
+		{Summary: "second", Start: day(2), End: day(5)},
+		{Summary: "adjacent", Start: day(5), End: day(6)},
+	})
+	assert.Equal(t, []Event{
+		{Summary: "first", Start: day(1), End: day(6)},
+		{Summary: "third", Start: day(20), End: day(21)},
+	}, merged)
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse(strings.NewReader("BEGIN:VEVENT\nSUMMARY:No date\nEND:VEVENT\n"), time.UTC)
+	assert.EqualError(t, err, `The "No date" event has no start date`)
+
+	_, err = Parse(strings.NewReader("BEGIN:VEVENT\nDTSTART:2019-07-15\nEND:VEVENT\n"), time.UTC)
+	assert.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	filePath := path.Join(os.TempDir(), fmt.Sprintf("prr-calendar-%d.ics", time.Now().UnixNano()))
+	ioutil.WriteFile(filePath, []byte(testCalendar), 0644)
+	defer os.Remove(filePath)
+	events, err := Load(filePath, time.UTC)
+	assert.Nil(t, err)
+	assert.Len(t, events, 4)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/calendar.ics" {
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Write([]byte(testCalendar))
+	}))
+	defer server.Close()
+	events, err = Load(server.URL+"/calendar.ics", time.UTC)
+	assert.Nil(t, err)
+	assert.Len(t, events, 4)
+
+	_, err = Load(server.URL+"/missing.ics", time.UTC)
+	assert.EqualError(t, err, fmt.Sprintf("Unable to download the calendar %s/missing.ics: 404 Not Found", server.URL))
+
+	_, err = Load(path.Join(os.TempDir(), "missing.ics"), time.UTC)
+	assert.Error(t, err)
+}
+
+func TestParseRecurringEvents(t *testing.T) {
+	t.Parallel()
+
+	montreal, _ := time.LoadLocation("America/Montreal")
+	events, err := Parse(strings.NewReader("BEGIN:VCALENDAR\n"+
+		"BEGIN:VEVENT\nSUMMARY:New year\nDTSTART;VALUE=DATE:20190101\nRRULE:FREQ=YEARLY\nEND:VEVENT\n"+
+		"BEGIN:VEVENT\nSUMMARY:Sprint planning\nDTSTART:20190304T090000\nDTEND:20190304T110000\n"+
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20190320T000000Z\nEXDATE:20190306T090000\nEND:VEVENT\n"+
+		"BEGIN:VEVENT\nSUMMARY:End of month\nDTSTART;VALUE=DATE:20190131\nRRULE:FREQ=MONTHLY;COUNT=3\nEND:VEVENT\n"+
+		"BEGIN:VEVENT\nSUMMARY:First monday\nDTSTART;VALUE=DATE:20190107\nRRULE:FREQ=MONTHLY;BYDAY=1MO\nEND:VEVENT\n"+
+		"END:VCALENDAR\n"), montreal)
+	assert.Nil(t, err)
+
+	summaries := map[string][]Event{}
+	for _, event := range events {
+		summaries[event.Summary] = append(summaries[event.Summary], event)
+	}
+	// Recurrences without an end are expanded until next year
+	assert.True(t, IsDuring(summaries["New year"], time.Date(2021, 1, 1, 12, 0, 0, 0, montreal)))
+	assert.True(t, IsDuring(summaries["New year"], time.Date(time.Now().Year()+1, 1, 1, 12, 0, 0, 0, montreal)))
+	assert.False(t, IsDuring(summaries["New year"], time.Date(2021, 1, 2, 12, 0, 0, 0, montreal)))
+
+	// Every other week on Monday and Wednesday, without the excluded date
+	starts := []time.Time{}
+	for _, event := range summaries["Sprint planning"] {
+		starts = append(starts, event.Start)
+		assert.Equal(t, 2*time.Hour, event.End.Sub(event.Start))
+	}
+	assert.Equal(t, []time.Time{
+		time.Date(2019, 3, 4, 9, 0, 0, 0, montreal),
+		time.Date(2019, 3, 18, 9, 0, 0, 0, montreal), // After the change to daylight saving time. The 20th is after the end
+	}, starts)
+
+	// Months without a 31st are skipped
+	assert.Len(t, summaries["End of month"], 3)
+	assert.True(t, time.Date(2019, 5, 31, 0, 0, 0, 0, montreal).Equal(summaries["End of month"][2].Start))
+
+	// Unsupported rules only keep the first occurrence
+	assert.Len(t, summaries["First monday"], 1)
+}
+
+func TestParseInvalidRecurrenceRule(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse(strings.NewReader("BEGIN:VEVENT\nSUMMARY:Invalid\nDTSTART:20190715T090000Z\nRRULE:FREQ=DAILY;COUNT=many\nEND:VEVENT\n"), time.UTC)
+	assert.EqualError(t, err, `Invalid recurrence rule "FREQ=DAILY;COUNT=many" of the "Invalid" event`)
+}
+
+func TestLoadTimeout(t *testing.T) {
+	// Not parallel, the HTTP client is replaced
+	defaultClient := httpClient
+	httpClient = &http.Client{Timeout: 10 * time.Millisecond}
+	defer func() { httpClient = defaultClient }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+	}))
+	defer server.Close()
+	_, err := Load(server.URL+"/calendar.ics", time.UTC)
+	assert.Contains(t, err.Error(), "Client.Timeout exceeded")
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/julienduchesne/pull-request-reminder/api"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/messages"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Output formats of the commands printing pull requests
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
+// options are the flags of the commands. The output and dry-run flags are only registered on the commands that read them
+type options struct {
+	configPath string
+	teams      string
+	logLevel   string
+	output     string
+
+	dryRun          bool
+	dryRunDirectory string
+}
+
+func (options *options) register(flagSet *flag.FlagSet, command *command) {
+	flagSet.StringVar(&options.configPath, "config", "", "Path of the configuration file, local or on S3. Overrides PRR_CONFIG")
+	flagSet.StringVar(&options.teams, "team", "", "Comma-separated names of the teams to handle. All teams are handled by default")
+	flagSet.StringVar(&options.logLevel, "log-level", "", "Log level (debug, info, warning or error). Overrides PRR_LOG_LEVEL")
+	if len(command.outputs) > 0 {
+		flagSet.StringVar(&options.output, "output", command.outputs[0], "Output format: "+strings.Join(command.outputs, " or "))
+	}
+	if command.dryRun {
+		flagSet.BoolVar(&options.dryRun, "dry-run", false, "Print the messages instead of sending them. The state is not modified")
+		flagSet.StringVar(&options.dryRunDirectory, "dry-run-dir", "", "Write the messages of a dry run to files in this directory instead of printing them. Implies -dry-run")
+	}
+}
+
+// commandContext is given to the commands. It reads the configuration with the command-line flags applied
+type commandContext struct {
+	options    *options
+	readConfig func() (*config.GlobalConfig, error)
+	stdout     io.Writer
+	// dryRun is set if the messages must be rendered instead of being sent
+	dryRun *messages.DryRun
+}
+
+// command is a subcommand of the program
+type command struct {
+	name        string
+	arguments   string
+	description string
+	// numberOfArguments is the number of positional arguments expected by the command
+	numberOfArguments int
+	// outputs are the output formats of the command, the default first. The command has no -output flag if it is empty
+	outputs []string
+	// dryRun is true if the command accepts the -dry-run and -dry-run-dir flags
+	dryRun bool
+	run    func(context *commandContext, arguments []string) error
+}
+
+// defaultCommand is run when no command is given
+const defaultCommand = "run"
+
+var commands = []*command{
+	{name: "run", description: "Notify the teams about their pull requests needing action (default)", dryRun: true, run: runCommand},
+	{name: "list", description: "Print the open pull requests of the teams, with their category", outputs: []string{textOutput, jsonOutput}, run: listCommand},
+	{name: "validate-config", description: "Validate the configuration file", run: validateConfigCommand},
+	{name: "explain", arguments: "<pull request URL>", description: "Explain why a pull request is or isn't in the teams' messages", numberOfArguments: 1,
+		outputs: []string{textOutput, jsonOutput}, run: explainCommand},
+	{name: "export", description: "Write a row per open pull request of the teams, as CSV or JSON Lines, to analyse review latency",
+		outputs: []string{csvExportFormat, jsonLinesExportFormat}, run: exportCommand},
+	{name: "serve", description: "Run each team on its cron schedule until stopped", dryRun: true, run: serveCommand},
+}
+
+// execute parses the command-line arguments and runs the requested command
+func execute(arguments []string, stdout, stderr io.Writer) error {
+	commandName := defaultCommand
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		commandName, arguments = arguments[0], arguments[1:]
+	}
+	if commandName == "help" {
+		printUsage(stdout)
+		return nil
+	}
+	var selectedCommand *command
+	for _, command := range commands {
+		if command.name == commandName {
+			selectedCommand = command
+		}
+	}
+	if selectedCommand == nil {
+		printUsage(stderr)
+		return fmt.Errorf("Unknown command %q", commandName)
+	}
+
+	options := &options{}
+	flagSet := flag.NewFlagSet(selectedCommand.name, flag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: pull-request-reminder %s [flags] %s\n\n%s\n\nFlags:\n", selectedCommand.name, selectedCommand.arguments, selectedCommand.description)
+		flagSet.PrintDefaults()
+	}
+	options.register(flagSet, selectedCommand)
+	if err := flagSet.Parse(arguments); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if flagSet.NArg() != selectedCommand.numberOfArguments {
+		flagSet.Usage()
+		return fmt.Errorf("The %s command expects %d argument(s), got %d", selectedCommand.name, selectedCommand.numberOfArguments, flagSet.NArg())
+	}
+	validOutput := len(selectedCommand.outputs) == 0
+	for _, output := range selectedCommand.outputs {
+		validOutput = validOutput || output == options.output
+	}
+	if !validOutput {
+		return fmt.Errorf("Invalid output format %q. It must be %s", options.output, strings.Join(selectedCommand.outputs, " or "))
+	}
+
+	configReader, err := config.NewReader()
+	if err != nil {
+		return fmt.Errorf("Error while initializing the configuration reader: %v", err)
+	}
+	if options.configPath != "" {
+		configReader.SetConfigFilePath(options.configPath)
+	}
+	if options.logLevel != "" {
+		configReader.SetLogLevel(options.logLevel)
+	}
+	context := &commandContext{
+		options: options,
+		readConfig: func() (*config.GlobalConfig, error) {
+			globalConfig, err := configReader.ReadConfig()
+			if err != nil {
+				return nil, err
+			}
+			if err = selectTeams(globalConfig, options.teams); err != nil {
+				return nil, err
+			}
+			return globalConfig, nil
+		},
+		stdout: stdout,
+	}
+	if options.dryRun || options.dryRunDirectory != "" {
+		context.dryRun = &messages.DryRun{Writer: stdout, Directory: options.dryRunDirectory}
+	}
+	return selectedCommand.run(context, flagSet.Args())
+}
+
+func printUsage(writer io.Writer) {
+	fmt.Fprintln(writer, "Usage: pull-request-reminder [command] [flags] [arguments]")
+	fmt.Fprintln(writer, "\nCommands:")
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	for _, command := range commands {
+		fmt.Fprintf(tabWriter, "  %s %s\t%s\n", command.name, command.arguments, command.description)
+	}
+	tabWriter.Flush()
+	fmt.Fprintln(writer, "\nRun `pull-request-reminder <command> -h` to list the flags of a command")
+}
+
+// selectTeams only keeps the teams with the given comma-separated names. All teams are kept if no names are given
+func selectTeams(globalConfig *config.GlobalConfig, names string) error {
+	if names == "" {
+		return nil
+	}
+	teamsByName := map[string]*config.TeamConfig{}
+	for _, team := range globalConfig.Teams {
+		teamsByName[team.Name] = team
+	}
+	selectedTeams := []*config.TeamConfig{}
+	for _, name := range strings.Split(names, ",") {
+		team, ok := teamsByName[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("Unknown team %q", strings.TrimSpace(name))
+		}
+		selectedTeams = append(selectedTeams, team)
+	}
+	globalConfig.Teams = selectedTeams
+	return nil
+}
+
+// runCommand notifies the teams about their pull requests needing action and exits.
+// Interactions, metrics and the other endpoints are only served by the serve command
+func runCommand(context *commandContext, arguments []string) error {
+	globalConfig, err := context.readConfig()
+	if err != nil {
+		return fmt.Errorf("Error while reading the configuration: %v", err)
+	}
+	newStore := state.NewStore
+	if context.dryRun != nil {
+		newStore = newReadOnlyStore
+	}
+	store, err := newStore(globalConfig.StatePath)
+	if err != nil {
+		return fmt.Errorf("Error while initializing the state store: %v", err)
+	}
+	snapshots := api.NewSnapshots()
+	for _, team := range globalConfig.Teams {
+		if err = runTeam(team, store, snapshots, context.dryRun); err != nil {
+			return fmt.Errorf("Error while running the %s team: %v", team.Name, err)
+		}
+	}
+	if globalConfig.MetricsTextfile != "" {
+		if err = prometheus.WriteToTextfile(globalConfig.MetricsTextfile, prometheus.DefaultGatherer); err != nil {
+			log.WithError(err).Errorln("Error while writing the metrics")
+		}
+	}
+	return nil
+}
+
+func serveCommand(context *commandContext, arguments []string) error {
+	return serve(context.readConfig, context.dryRun)
+}
+
+func validateConfigCommand(context *commandContext, arguments []string) error {
+	globalConfig, err := context.readConfig()
+	if err != nil {
+		return fmt.Errorf("The configuration is invalid: %v", err)
+	}
+	teamNames := []string{}
+	for _, team := range globalConfig.Teams {
+		teamNames = append(teamNames, team.Name)
+	}
+	fmt.Fprintf(context.stdout, "The configuration is valid. Teams: %s\n", strings.Join(teamNames, ", "))
+	return nil
+}
+
+// listCommand prints the open pull requests of the teams, without notifying anyone
+func listCommand(context *commandContext, arguments []string) error {
+	teamSnapshots, err := getSnapshots(context)
+	if err != nil {
+		return err
+	}
+	if context.options.output == jsonOutput {
+		return writeJSON(context.stdout, teamSnapshots)
+	}
+
+	now := time.Now()
+	for index, snapshot := range teamSnapshots {
+		if index > 0 {
+			fmt.Fprintln(context.stdout)
+		}
+		fmt.Fprintf(context.stdout, "%s\n", snapshot.Team)
+		tabWriter := tabwriter.NewWriter(context.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "REPOSITORY\tPULL REQUEST\tAUTHOR\tAGE\tAPPROVALS\tCATEGORY\tLINK")
+		for _, repository := range snapshot.Repositories {
+			for _, pullRequest := range repository.PullRequests {
+				category := pullRequest.Category
+				if category == api.IgnoredCategory {
+					category = fmt.Sprintf("%s (%s)", category, pullRequest.Explanation)
+				}
+				fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%d/%d\t%s\t%s\n", repository.Name, pullRequest.Title, pullRequest.Author.Name,
+					utilities.HumanizeDuration(now.Sub(pullRequest.CreateTime)), pullRequest.Approvals, pullRequest.NeededApprovals, category, pullRequest.Link)
+			}
+		}
+		tabWriter.Flush()
+	}
+	return nil
+}
+
+// explainedPullRequest is a pull request found by the explain command
+type explainedPullRequest struct {
+	Team       string `json:"team"`
+	Repository string `json:"repository"`
+	*api.PullRequest
+}
+
+// explainCommand prints the category of the pull request with the given URL for each team watching its repository
+func explainCommand(context *commandContext, arguments []string) error {
+	link := strings.TrimSuffix(arguments[0], "/")
+	teamSnapshots, err := getSnapshots(context)
+	if err != nil {
+		return err
+	}
+	found := []*explainedPullRequest{}
+	for _, snapshot := range teamSnapshots {
+		for _, repository := range snapshot.Repositories {
+			for _, pullRequest := range repository.PullRequests {
+				if strings.TrimSuffix(pullRequest.Link, "/") == link {
+					found = append(found, &explainedPullRequest{Team: snapshot.Team, Repository: repository.Name, PullRequest: pullRequest})
+				}
+			}
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("The pull request %s is not one of the open pull requests of the teams' repositories", link)
+	}
+	if context.options.output == jsonOutput {
+		return writeJSON(context.stdout, found)
+	}
+
+	for _, pullRequest := range found {
+		fmt.Fprintf(context.stdout, "%s team: %s (%s)\n", pullRequest.Team, pullRequest.Title, pullRequest.Repository)
+		fmt.Fprintf(context.stdout, "  %s\n", explainCategory(pullRequest.PullRequest))
+		fmt.Fprintf(context.stdout, "  Approvals from the team: %d/%d\n", pullRequest.Approvals, pullRequest.NeededApprovals)
+		for _, reviewer := range pullRequest.Reviewers {
+			fmt.Fprintf(context.stdout, "  Reviewer %s: %s\n", reviewer.Name, explainReviewer(reviewer))
+		}
+	}
+	return nil
+}
+
+// categoryTitles describe the categories in the explain command
+var categoryTitles = map[string]string{
+	hosts.ReadyToMergeCategory:     "Listed as ready to merge",
+	hosts.ReadyToReviewCategory:    "Listed as in need of approvers",
+	hosts.ChangesRequestedCategory: "Listed as waiting on its author",
+	api.IgnoredCategory:            "Ignored",
+}
+
+func explainCategory(pullRequest *api.PullRequest) string {
+	return fmt.Sprintf("%s: %s (%s)", categoryTitles[pullRequest.Category], pullRequest.Explanation, pullRequest.Reason)
+}
+
+func explainReviewer(reviewer *api.Reviewer) string {
+	reviewStatus := "pending"
+	if reviewer.Approved {
+		reviewStatus = "approved"
+	} else if reviewer.RequestedChanges {
+		reviewStatus = "requested changes"
+	}
+	if reviewer.Absent {
+		reviewStatus += ", out of office"
+	}
+	if !reviewer.TeamMember {
+		reviewStatus += ", not one of the team's users"
+	}
+	return reviewStatus
+}
+
+// getSnapshots fetches the pull requests of the selected teams, without notifying anyone
+func getSnapshots(context *commandContext) ([]*api.TeamSnapshot, error) {
+	teamSnapshots := []*api.TeamSnapshot{}
+	err := fetchRepositories(context, func(team *config.TeamConfig, repositories []hosts.Repository) {
+		teamSnapshots = append(teamSnapshots, api.NewTeamSnapshot(team, repositories, time.Now()))
+	})
+	return teamSnapshots, err
+}
+
+// fetchRepositories fetches the repositories of each selected team and gives them to the handle function.
+// The state is read but not modified
+func fetchRepositories(context *commandContext, handle func(team *config.TeamConfig, repositories []hosts.Repository)) error {
+	globalConfig, err := context.readConfig()
+	if err != nil {
+		return fmt.Errorf("Error while reading the configuration: %v", err)
+	}
+	store, err := newReadOnlyStore(globalConfig.StatePath)
+	if err != nil {
+		return fmt.Errorf("Error while initializing the state store: %v", err)
+	}
+	for _, team := range globalConfig.Teams {
+		repositories, err := getRepositories(hosts.GetHosts(team, store))
+		if err != nil {
+			return fmt.Errorf("Error while fetching the pull requests of the %s team: %v", team.Name, err)
+		}
+		handle(team, repositories)
+	}
+	return nil
+}
+
+func writeJSON(writer io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/julienduchesne/pull-request-reminder/api"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecute(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Nil(t, execute([]string{"help"}, stdout, stderr))
+	assert.Contains(t, stdout.String(), "validate-config")
+	assert.Contains(t, stdout.String(), "explain <pull request URL>")
+
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Nil(t, execute([]string{"list", "-h"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "-team")
+
+	assert.EqualError(t, execute([]string{"unknown"}, ioutil.Discard, ioutil.Discard), `Unknown command "unknown"`)
+	assert.EqualError(t, execute([]string{"explain"}, ioutil.Discard, ioutil.Discard), "The explain command expects 1 argument(s), got 0")
+	assert.EqualError(t, execute([]string{"list", "-output", "xml"}, ioutil.Discard, ioutil.Discard), `Invalid output format "xml". It must be text or json`)
+	assert.NotNil(t, execute([]string{"run", "-unknown-flag"}, ioutil.Discard, ioutil.Discard))
+
+	// Flags are only registered on the commands that read them
+	assert.EqualError(t, execute([]string{"run", "-output", "json"}, ioutil.Discard, ioutil.Discard), "flag provided but not defined: -output")
+	assert.EqualError(t, execute([]string{"list", "-dry-run"}, ioutil.Discard, ioutil.Discard), "flag provided but not defined: -dry-run")
+	stderr = &bytes.Buffer{}
+	assert.Nil(t, execute([]string{"validate-config", "-h"}, ioutil.Discard, stderr))
+	assert.NotContains(t, stderr.String(), "-output")
+}
+
+func TestValidateConfigCommand(t *testing.T) {
+	configPath := path.Join(os.TempDir(), "validate_config_file")
+	defer os.Remove(configPath)
+
+	ioutil.WriteFile(configPath, []byte("teams:\n- name: team1\n- name: team2\n"), 0644)
+	stdout := &bytes.Buffer{}
+	assert.Nil(t, execute([]string{"validate-config", "-config", configPath}, stdout, ioutil.Discard))
+	assert.Equal(t, "The configuration is valid. Teams: team1, team2\n", stdout.String())
+
+	stdout = &bytes.Buffer{}
+	assert.Nil(t, execute([]string{"validate-config", "-config", configPath, "-team", "team2"}, stdout, ioutil.Discard))
+	assert.Equal(t, "The configuration is valid. Teams: team2\n", stdout.String())
+
+	assert.EqualError(t, execute([]string{"validate-config", "-config", configPath, "-team", "team3"}, ioutil.Discard, ioutil.Discard),
+		`The configuration is invalid: Unknown team "team3"`)
+
+	ioutil.WriteFile(configPath, []byte("teams:\n- name: team1\n  cron: invalid\n"), 0644)
+	assert.NotNil(t, execute([]string{"validate-config", "-config", configPath}, ioutil.Discard, ioutil.Discard))
+}
+
+func TestSelectTeams(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func() *config.GlobalConfig {
+		return &config.GlobalConfig{Teams: []*config.TeamConfig{{Name: "team1"}, {Name: "team2"}, {Name: "team3"}}}
+	}
+	getNames := func(globalConfig *config.GlobalConfig) []string {
+		names := []string{}
+		for _, team := range globalConfig.Teams {
+			names = append(names, team.Name)
+		}
+		return names
+	}
+
+	globalConfig := newConfig()
+	assert.Nil(t, selectTeams(globalConfig, ""))
+	assert.Equal(t, []string{"team1", "team2", "team3"}, getNames(globalConfig))
+
+	globalConfig = newConfig()
+	assert.Nil(t, selectTeams(globalConfig, "team3, team1"))
+	assert.Equal(t, []string{"team3", "team1"}, getNames(globalConfig))
+
+	assert.EqualError(t, selectTeams(newConfig(), "team1,team4"), `Unknown team "team4"`)
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "Listed as in need of approvers: Not enough approvals from the team (needs_approvals)", explainCategory(&api.PullRequest{
+		Category: hosts.ReadyToReviewCategory, Reason: hosts.NeedsApprovalsReason, Explanation: "Not enough approvals from the team",
+	}))
+	assert.Equal(t, "Ignored: Marked WIP (wip)", explainCategory(&api.PullRequest{Category: api.IgnoredCategory, Reason: hosts.WIPReason, Explanation: "Marked WIP"}))
+
+	assert.Equal(t, "approved", explainReviewer(&api.Reviewer{Approved: true, TeamMember: true}))
+	assert.Equal(t, "pending, out of office, not one of the team's users", explainReviewer(&api.Reviewer{Absent: true}))
+}
+
+func TestRunDryRun(t *testing.T) {
+	directory, err := ioutil.TempDir("", "dry-run")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	configPath, statePath := path.Join(directory, "config"), path.Join(directory, "state")
+	ioutil.WriteFile(configPath, []byte("state_path: "+statePath+"\nteams:\n- name: team1\n"), 0644)
+
+	assert.Nil(t, execute([]string{"run", "-dry-run", "-config", configPath}, ioutil.Discard, ioutil.Discard))
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err), "The state should not be written in dry-run mode")
+
+	assert.Nil(t, execute([]string{"run", "-config", configPath}, ioutil.Discard, ioutil.Discard))
+	_, err = os.Stat(statePath)
+	assert.Nil(t, err)
+}
@@ -24,16 +24,31 @@ const defaultConfigFileName = ".prr-config"
 type EnvironmentConfig struct {
 	ConfigFilePath string `envconfig:"config"`
 	LogLevel       string `envconfig:"log_level"`
+	StatePath      string `envconfig:"state"`
 
 	BitbucketUsername string `envconfig:"bitbucket_username"`
 	BitbucketPassword string `envconfig:"bitbucket_password"`
 	GithubToken       string `envconfig:"github_token"`
 	SlackToken        string `envconfig:"slack_token"`
+
+	SlackSigningSecret string `envconfig:"slack_signing_secret"`
+	ListenAddress      string `envconfig:"listen_address"`
+	// InternalListenAddress is where the API and the dashboard are served
+	InternalListenAddress string `envconfig:"internal_listen_address"`
+	MetricsTextfile       string `envconfig:"metrics_textfile"`
 }
 
 // GlobalConfig represents the read configuration file
 type GlobalConfig struct {
-	Teams []*TeamConfig
+	ListenAddress string `yaml:"listen_address"`
+	// InternalListenAddress is where the API and the dashboard are served. They expose the pull requests of all teams
+	// without authentication, so it must not be reachable publicly, unlike the listen address that receives the Slack interactions
+	InternalListenAddress string `yaml:"internal_listen_address"`
+	StatePath             string `yaml:"state_path"`
+	Teams                 []*TeamConfig
+
+	// MetricsTextfile is the path of the file where metrics are written after handling the teams, for the textfile collector of the Prometheus node exporter
+	MetricsTextfile string `yaml:"metrics_textfile"`
 }
 
 // Reader represents an utility that will read the configuration from the environment as well as a config file
@@ -52,8 +67,14 @@ func NewReader() (*Reader, error) {
 		envConfig.ConfigFilePath = defaultConfigFileName
 	}
 	configReader := &Reader{envConfig: envConfig}
+	configReader.SetConfigFilePath(envConfig.ConfigFilePath)
+	return configReader, nil
+}
 
-	if strings.HasPrefix(envConfig.ConfigFilePath, "s3://") {
+// SetConfigFilePath overrides the path of the configuration file given by the environment. It can be a local path or an S3 path
+func (configReader *Reader) SetConfigFilePath(configFilePath string) {
+	configReader.envConfig.ConfigFilePath = configFilePath
+	if strings.HasPrefix(configFilePath, "s3://") {
 		sess := session.Must(session.NewSessionWithOptions(session.Options{
 			SharedConfigState: session.SharedConfigEnable,
 		}))
@@ -61,8 +82,11 @@ func NewReader() (*Reader, error) {
 	} else {
 		configReader.readFunc = readFileConfig
 	}
+}
 
-	return configReader, nil
+// SetLogLevel overrides the log level given by the environment
+func (configReader *Reader) SetLogLevel(logLevel string) {
+	configReader.envConfig.LogLevel = logLevel
 }
 
 // ReadConfig reads the configuration file at the given path and injects environment variables in the read configuration (team configs)
@@ -79,8 +103,26 @@ func (configReader *Reader) ReadConfig() (config *GlobalConfig, err error) {
 		return nil, err
 	}
 	log.SetLevel(logLevel)
+	if envConfig.StatePath != "" {
+		config.StatePath = envConfig.StatePath
+	}
+	if envConfig.ListenAddress != "" {
+		config.ListenAddress = envConfig.ListenAddress
+	}
+	if envConfig.InternalListenAddress != "" {
+		config.InternalListenAddress = envConfig.InternalListenAddress
+	}
+	if envConfig.MetricsTextfile != "" {
+		config.MetricsTextfile = envConfig.MetricsTextfile
+	}
 	for _, team := range config.Teams {
 		team.setEnvironmentConfig(envConfig)
+		if err = team.Validate(); err != nil {
+			return nil, err
+		}
+		if err = team.loadCalendars(); err != nil {
+			return nil, err
+		}
 	}
 	return
 }
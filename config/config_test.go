@@ -2,12 +2,14 @@ package config
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
@@ -43,6 +45,10 @@ func TestReadingConfigSetsEnvironmentVariables(t *testing.T) {
 	assert.Equal(t, envConfig.BitbucketPassword, team.Hosts.Bitbucket.Password)
 	assert.Equal(t, envConfig.GithubToken, team.Hosts.Github.Token)
 	assert.Equal(t, envConfig.SlackToken, team.Messaging.Slack.Token)
+	assert.Equal(t, envConfig.SlackSigningSecret, team.Messaging.Slack.SigningSecret)
+	assert.Equal(t, envConfig.ListenAddress, config.ListenAddress)
+	assert.Equal(t, envConfig.InternalListenAddress, config.InternalListenAddress)
+	assert.Equal(t, envConfig.MetricsTextfile, config.MetricsTextfile)
 }
 
 func TestReadFileConfig(t *testing.T) {
@@ -58,8 +64,121 @@ func TestReadFileConfig(t *testing.T) {
 	}
 	config, err := configReader.ReadConfig()
 	assert.Equal(t, log.InfoLevel, log.GetLevel()) // Default level is info
+	assert.Equal(t, "", config.StatePath)          // The state is optional
 	assert.Len(t, config.Teams, 1)
 	assert.Nil(t, err)
+
+	team := config.Teams[0]
+	assert.Equal(t, Schedule{
+		Timezone:    "Asia/Tokyo",
+		WorkingDays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"},
+		QuietHours:  "18:00-09:00",
+	}, team.GetUserSchedule(team.Users[0]))
+	assert.Equal(t, []DateRange{{From: "2019-07-15", To: "2019-07-19"}}, team.Users[0].OutOfOffice)
+}
+
+func TestReadConfigWithInvalidSchedule(t *testing.T) {
+	t.Parallel()
+
+	configReader := &Reader{
+		envConfig: getTestEnvConfig(""),
+		readFunc: func(string) (*GlobalConfig, error) {
+			config := &GlobalConfig{}
+			yaml.Unmarshal(testGlobalConfig, config)
+			config.Teams[0].Users[0].QuietHours = "evenings"
+			return config, nil
+		},
+	}
+
+	_, err := configReader.ReadConfig()
+	assert.EqualError(t, err, `Invalid schedule for John Doe: Invalid quiet hours "evenings". The expected format is HH:MM-HH:MM`)
+}
+
+func TestReadConfigWithOutOfOffice(t *testing.T) {
+	t.Parallel()
+
+	calendarFileName := path.Join(os.TempDir(), "prr-out-of-office.ics")
+	ioutil.WriteFile(calendarFileName, []byte("BEGIN:VEVENT\nDTSTART;VALUE=DATE:20190722\nEND:VEVENT\n"), 0644)
+	defer os.Remove(calendarFileName)
+
+	cases := []struct {
+		name          string
+		outOfOffice   []DateRange
+		calendar      string
+		expectedError string
+	}{
+		{
+			name:     "Calendar",
+			calendar: calendarFileName,
+		},
+		{
+			name:          "Invalid date",
+			outOfOffice:   []DateRange{{From: "2019/07/15"}},
+			expectedError: `Invalid out of office period for John Doe: Invalid date "2019/07/15". The expected format is YYYY-MM-DD`,
+		},
+		{
+			name:          "Missing calendar",
+			calendar:      path.Join(os.TempDir(), "missing.ics"),
+			expectedError: fmt.Sprintf("Unable to load the out of office calendar of John Doe: Unable to read the calendar %s", path.Join(os.TempDir(), "missing.ics")),
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			configReader := &Reader{
+				envConfig: getTestEnvConfig(""),
+				readFunc: func(string) (*GlobalConfig, error) {
+					config := &GlobalConfig{}
+					yaml.Unmarshal(testGlobalConfig, config)
+					config.Teams[0].Users[0].OutOfOffice = tt.outOfOffice
+					config.Teams[0].Users[0].OutOfOfficeCalendar = tt.calendar
+					return config, nil
+				},
+			}
+
+			config, err := configReader.ReadConfig()
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+			assert.Nil(t, err)
+			team := config.Teams[0]
+			tokyo, _ := time.LoadLocation("Asia/Tokyo")
+			assert.True(t, team.IsUserAbsent(team.Users[0], time.Date(2019, 7, 22, 12, 0, 0, 0, tokyo)))
+			assert.False(t, team.IsUserAbsent(team.Users[0], time.Date(2019, 7, 23, 12, 0, 0, 0, tokyo)))
+		})
+	}
+}
+
+func TestReadConfigWithHolidayCalendar(t *testing.T) {
+	t.Parallel()
+
+	calendarFileName := path.Join(os.TempDir(), "prr-holidays.ics")
+	ioutil.WriteFile(calendarFileName, []byte("BEGIN:VEVENT\nSUMMARY:Canada Day\nDTSTART;VALUE=DATE:20190701\nEND:VEVENT\n"), 0644)
+	defer os.Remove(calendarFileName)
+
+	for _, holidayCalendar := range []string{calendarFileName, path.Join(os.TempDir(), "missing.ics")} {
+		configReader := &Reader{
+			envConfig: getTestEnvConfig(""),
+			readFunc: func(string) (*GlobalConfig, error) {
+				config := &GlobalConfig{}
+				yaml.Unmarshal(testGlobalConfig, config)
+				config.Teams[0].HolidayCalendar = holidayCalendar
+				return config, nil
+			},
+		}
+		config, err := configReader.ReadConfig()
+		if holidayCalendar != calendarFileName {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "Unable to load the holiday calendar of the test_team team")
+			continue
+		}
+		assert.Nil(t, err)
+		montreal, _ := time.LoadLocation("America/Montreal")
+		assert.True(t, config.Teams[0].IsHoliday(time.Date(2019, 7, 1, 23, 0, 0, 0, montreal)))
+		assert.False(t, config.Teams[0].IsHoliday(time.Date(2019, 7, 2, 0, 0, 0, 0, montreal)))
+	}
 }
 
 func TestReadS3Config(t *testing.T) {
@@ -68,7 +187,9 @@ func TestReadS3Config(t *testing.T) {
 		readFunc:  getS3ConfigReadFunc(&mockedS3Client{t: t}),
 	}
 	configReader.envConfig.LogLevel = "DEBUG"
+	configReader.envConfig.StatePath = "/tmp/state"
 	config, err := configReader.ReadConfig()
+	assert.Equal(t, "/tmp/state", config.StatePath)
 	assert.Equal(t, log.DebugLevel, log.GetLevel()) // Log level was set to default
 	assert.Len(t, config.Teams, 1)
 	assert.Nil(t, err)
@@ -83,12 +204,17 @@ func TestCreateConfigReader(t *testing.T) {
 	assert.Equal(t, expectedFunc, gottenFunc)
 
 	for key, value := range map[string]string{
-		"PRR_BITBUCKET_PASSWORD": "bb_pass",
-		"PRR_BITBUCKET_USERNAME": "bb_user",
-		"PRR_GITHUB_TOKEN":       "gh_token",
-		"PRR_SLACK_TOKEN":        "xoxb_test",
-		"PRR_CONFIG":             "s3://bucket/key",
-		"PRR_LOG_LEVEL":          "DEBUG",
+		"PRR_BITBUCKET_PASSWORD":      "bb_pass",
+		"PRR_BITBUCKET_USERNAME":      "bb_user",
+		"PRR_GITHUB_TOKEN":            "gh_token",
+		"PRR_SLACK_TOKEN":             "xoxb_test",
+		"PRR_SLACK_SIGNING_SECRET":    "secret",
+		"PRR_LISTEN_ADDRESS":          ":8080",
+		"PRR_INTERNAL_LISTEN_ADDRESS": "127.0.0.1:8081",
+		"PRR_CONFIG":                  "s3://bucket/key",
+		"PRR_LOG_LEVEL":               "DEBUG",
+		"PRR_STATE":                   "/tmp/state",
+		"PRR_METRICS_TEXTFILE":        "/tmp/prr.prom",
 	} {
 		oldValue := os.Getenv(key)
 		if oldValue != "" {
@@ -101,13 +227,27 @@ func TestCreateConfigReader(t *testing.T) {
 	configReader, err = NewReader()
 	assert.Nil(t, err)
 	assert.Equal(t, "DEBUG", configReader.envConfig.LogLevel)
+	assert.Equal(t, "/tmp/state", configReader.envConfig.StatePath)
 	assert.Equal(t, "bb_pass", configReader.envConfig.BitbucketPassword)
 	assert.Equal(t, "bb_user", configReader.envConfig.BitbucketUsername)
 	assert.Equal(t, "gh_token", configReader.envConfig.GithubToken)
 	assert.Equal(t, "xoxb_test", configReader.envConfig.SlackToken)
+	assert.Equal(t, "secret", configReader.envConfig.SlackSigningSecret)
+	assert.Equal(t, ":8080", configReader.envConfig.ListenAddress)
+	assert.Equal(t, "127.0.0.1:8081", configReader.envConfig.InternalListenAddress)
+	assert.Equal(t, "/tmp/prr.prom", configReader.envConfig.MetricsTextfile)
 	expectedFunc = runtime.FuncForPC(reflect.ValueOf(getS3ConfigReadFunc(nil)).Pointer()).Name()
 	gottenFunc = runtime.FuncForPC(reflect.ValueOf(configReader.readFunc).Pointer()).Name()
 	assert.Equal(t, expectedFunc, gottenFunc)
+
+	// Command-line flags override the environment
+	configReader.SetConfigFilePath("/tmp/config")
+	configReader.SetLogLevel("WARN")
+	assert.Equal(t, "/tmp/config", configReader.envConfig.ConfigFilePath)
+	assert.Equal(t, "WARN", configReader.envConfig.LogLevel)
+	expectedFunc = runtime.FuncForPC(reflect.ValueOf(readFileConfig).Pointer()).Name()
+	gottenFunc = runtime.FuncForPC(reflect.ValueOf(configReader.readFunc).Pointer()).Name()
+	assert.Equal(t, expectedFunc, gottenFunc)
 }
 
 func getTestEnvConfig(path string) *EnvironmentConfig {
@@ -117,6 +257,11 @@ func getTestEnvConfig(path string) *EnvironmentConfig {
 		BitbucketPassword: "BB_PASSWORD",
 		GithubToken:       "GH_TOKEN",
 		SlackToken:        "xoxb-stuff",
+
+		SlackSigningSecret:    "signing-secret",
+		ListenAddress:         ":8080",
+		InternalListenAddress: "127.0.0.1:8081",
+		MetricsTextfile:       "/tmp/prr.prom",
 	}
 }
 
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/calendar"
+)
+
+// Schedule represents when a team or a user works and can be messaged. Empty fields mean no restriction
+type Schedule struct {
+	// Timezone is an IANA time zone name (ex: America/Montreal). Defaults to UTC
+	Timezone string `yaml:"timezone"`
+	// WorkingDays are the days (ex: monday or mon) on which messages can be sent. Defaults to all days
+	WorkingDays []string `yaml:"working_days"`
+	// QuietHours is the local time range during which no messages are sent (ex: 18:00-09:00)
+	QuietHours string `yaml:"quiet_hours"`
+}
+
+// Merge returns the schedule with its empty fields set from the given fallback schedule
+func (schedule Schedule) Merge(fallback Schedule) Schedule {
+	if schedule.Timezone == "" {
+		schedule.Timezone = fallback.Timezone
+	}
+	if len(schedule.WorkingDays) == 0 {
+		schedule.WorkingDays = fallback.WorkingDays
+	}
+	if schedule.QuietHours == "" {
+		schedule.QuietHours = fallback.QuietHours
+	}
+	return schedule
+}
+
+// Validate returns an error if the time zone, the working days or the quiet hours can't be parsed
+func (schedule Schedule) Validate() error {
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("Invalid timezone %q: %v", schedule.Timezone, err)
+	}
+	for _, day := range schedule.WorkingDays {
+		if _, ok := parseWeekday(day); !ok {
+			return fmt.Errorf("Invalid working day %q", day)
+		}
+	}
+	if _, _, ok := schedule.quietHours(); !ok && schedule.QuietHours != "" {
+		return fmt.Errorf("Invalid quiet hours %q. The expected format is HH:MM-HH:MM", schedule.QuietHours)
+	}
+	return nil
+}
+
+// IsAvailable returns true if the given time is on a working day and outside of the quiet hours
+func (schedule Schedule) IsAvailable(now time.Time) bool {
+	now = now.In(schedule.location())
+	if !schedule.isWorkingDay(now.Weekday()) {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	for _, period := range schedule.availablePeriods() {
+		if minute >= period[0] && minute < period[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// NextAvailableTime returns the first time, from the given time, at which the schedule is available.
+// The zero time is returned if the schedule is never available
+func (schedule Schedule) NextAvailableTime(now time.Time) time.Time {
+	if schedule.IsAvailable(now) {
+		return now
+	}
+	now = now.In(schedule.location())
+	// Availability only starts at midnight or at the end of the quiet hours
+	for days := 0; days <= 7; days++ {
+		day := time.Date(now.Year(), now.Month(), now.Day()+days, 0, 0, 0, 0, now.Location())
+		for _, period := range schedule.availablePeriods() {
+			start := atMinute(day, period[0])
+			if start.After(now) && schedule.IsAvailable(start) {
+				return start
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// BusinessDuration returns the time elapsed between the two given times, only counting the working days outside of the quiet hours
+func (schedule Schedule) BusinessDuration(from, to time.Time) time.Duration {
+	location, periods := schedule.location(), schedule.availablePeriods()
+	from, to = from.In(location), to.In(location)
+	duration := time.Duration(0)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !schedule.isWorkingDay(day.Weekday()) {
+			continue
+		}
+		for _, period := range periods {
+			start := atMinute(day, period[0])
+			end := atMinute(day, period[1])
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if end.After(start) {
+				duration += end.Sub(start)
+			}
+		}
+	}
+	return duration
+}
+
+// DateRange is an inclusive range of days in the YYYY-MM-DD format (ex: 2019-07-15 to 2019-07-19)
+type DateRange struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// event returns the period from the start of the first day to the end of the last day in the given location
+func (dateRange DateRange) event(location *time.Location) (calendar.Event, error) {
+	const dateFormat = "2006-01-02"
+	from, err := time.ParseInLocation(dateFormat, dateRange.From, location)
+	if err != nil {
+		return calendar.Event{}, fmt.Errorf("Invalid date %q. The expected format is YYYY-MM-DD", dateRange.From)
+	}
+	to := from
+	if dateRange.To != "" {
+		if to, err = time.ParseInLocation(dateFormat, dateRange.To, location); err != nil {
+			return calendar.Event{}, fmt.Errorf("Invalid date %q. The expected format is YYYY-MM-DD", dateRange.To)
+		}
+	}
+	if to.Before(from) {
+		return calendar.Event{}, fmt.Errorf("The period ends (%s) before it starts (%s)", dateRange.To, dateRange.From)
+	}
+	return calendar.Event{Start: from, End: to.AddDate(0, 0, 1)}, nil
+}
+
+func (schedule Schedule) location() *time.Location {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+func (schedule Schedule) isWorkingDay(weekday time.Weekday) bool {
+	if len(schedule.WorkingDays) == 0 {
+		return true
+	}
+	for _, day := range schedule.WorkingDays {
+		if workingDay, ok := parseWeekday(day); ok && workingDay == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// quietHours returns the start and end of the quiet hours in minutes since midnight
+func (schedule Schedule) quietHours() (int, int, bool) {
+	bounds := strings.Split(schedule.QuietHours, "-")
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	minutes := []int{}
+	for _, bound := range bounds {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(bound))
+		if err != nil {
+			return 0, 0, false
+		}
+		minutes = append(minutes, parsed.Hour()*60+parsed.Minute())
+	}
+	return minutes[0], minutes[1], true
+}
+
+// availablePeriods returns the periods of a day (start and end in minutes since midnight) that are outside of the quiet hours
+func (schedule Schedule) availablePeriods() [][2]int {
+	const endOfDay = 24 * 60
+	start, end, ok := schedule.quietHours()
+	switch {
+	case !ok || start == end:
+		return [][2]int{{0, endOfDay}}
+	case start < end:
+		// Ex: 12:00-13:00
+		return [][2]int{{0, start}, {end, endOfDay}}
+	default:
+		// Ex: 18:00-09:00
+		return [][2]int{{end, start}}
+	}
+}
+
+// atMinute returns the time at the given number of minutes since the midnight of the given day. Daylight saving time changes are accounted for
+func atMinute(day time.Time, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minute, 0, 0, day.Location())
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	day = strings.ToLower(strings.TrimSpace(day))
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if day == name || day == name[:3] {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleIsAvailable(t *testing.T) {
+	t.Parallel()
+
+	schedule := Schedule{Timezone: "America/Montreal", WorkingDays: []string{"mon", "Tuesday"}, QuietHours: "18:00-09:00"}
+	location, _ := time.LoadLocation("America/Montreal")
+
+	cases := []struct {
+		name      string
+		time      time.Time
+		available bool
+	}{
+		{"Monday morning", time.Date(2019, 7, 15, 9, 0, 0, 0, location), true},
+		{"Tuesday afternoon", time.Date(2019, 7, 16, 17, 59, 0, 0, location), true},
+		{"Monday night", time.Date(2019, 7, 15, 3, 0, 0, 0, location), false},
+		{"Monday evening", time.Date(2019, 7, 15, 18, 0, 0, 0, location), false},
+		{"Wednesday", time.Date(2019, 7, 17, 12, 0, 0, 0, location), false},
+		{"Monday morning in UTC", time.Date(2019, 7, 15, 13, 0, 0, 0, time.UTC), true},
+		{"Monday morning in Montreal but not in UTC", time.Date(2019, 7, 15, 8, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.available, schedule.IsAvailable(tt.time))
+		})
+	}
+
+	assert.True(t, Schedule{}.IsAvailable(time.Date(2019, 7, 14, 3, 0, 0, 0, time.UTC)), "An empty schedule is always available")
+	assert.False(t, Schedule{QuietHours: "12:00-13:00"}.IsAvailable(time.Date(2019, 7, 14, 12, 30, 0, 0, time.UTC)))
+	assert.True(t, Schedule{QuietHours: "12:00-13:00"}.IsAvailable(time.Date(2019, 7, 14, 13, 0, 0, 0, time.UTC)))
+}
+
+func TestScheduleNextAvailableTime(t *testing.T) {
+	t.Parallel()
+
+	schedule := Schedule{Timezone: "Asia/Tokyo", WorkingDays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}, QuietHours: "18:00-09:00"}
+	location, _ := time.LoadLocation("Asia/Tokyo")
+
+	available := time.Date(2019, 7, 15, 10, 0, 0, 0, location)
+	assert.Equal(t, available, schedule.NextAvailableTime(available))
+	// Monday night -> Tuesday morning
+	assert.True(t, time.Date(2019, 7, 16, 9, 0, 0, 0, location).Equal(schedule.NextAvailableTime(time.Date(2019, 7, 15, 20, 0, 0, 0, location))))
+	// Friday evening -> Monday morning
+	assert.True(t, time.Date(2019, 7, 22, 9, 0, 0, 0, location).Equal(schedule.NextAvailableTime(time.Date(2019, 7, 19, 18, 30, 0, 0, location))))
+	// No quiet hours, Saturday -> Monday at midnight
+	schedule.QuietHours = ""
+	assert.True(t, time.Date(2019, 7, 22, 0, 0, 0, 0, location).Equal(schedule.NextAvailableTime(time.Date(2019, 7, 20, 12, 0, 0, 0, location))))
+}
+
+func TestScheduleBusinessDuration(t *testing.T) {
+	t.Parallel()
+
+	schedule := Schedule{WorkingDays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"}, QuietHours: "17:00-09:00"}
+
+	// Friday 16:00 to Monday 10:00: 1 hour on Friday and 1 hour on Monday
+	friday := time.Date(2019, 7, 19, 16, 0, 0, 0, time.UTC)
+	monday := time.Date(2019, 7, 22, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, 2*time.Hour, schedule.BusinessDuration(friday, monday))
+	assert.Equal(t, monday.Sub(friday), Schedule{}.BusinessDuration(friday, monday))
+	assert.Equal(t, time.Duration(0), schedule.BusinessDuration(monday, friday))
+	// A full week of work
+	assert.Equal(t, 40*time.Hour, schedule.BusinessDuration(monday, monday.AddDate(0, 0, 7)))
+}
+
+func TestScheduleValidate(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, Schedule{}.Validate())
+	assert.Nil(t, Schedule{Timezone: "Europe/Paris", WorkingDays: []string{"Sun"}, QuietHours: "22:00 - 07:30"}.Validate())
+	assert.EqualError(t, Schedule{WorkingDays: []string{"weekend"}}.Validate(), `Invalid working day "weekend"`)
+	assert.EqualError(t, Schedule{QuietHours: "22h-7h"}.Validate(), `Invalid quiet hours "22h-7h". The expected format is HH:MM-HH:MM`)
+	assert.Error(t, Schedule{Timezone: "Mars/Olympus"}.Validate())
+}
@@ -1,7 +1,19 @@
 package config
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/calendar"
+	"github.com/robfig/cron/v3"
+)
+
+// Orders in which pull requests can be sorted in messages
+const (
+	SortByAge       = "oldest"
+	SortByStaleness = "stale"
+	SortByApprovals = "approvals"
+	SortBySize      = "size"
 )
 
 // TeamConfig represents the full configuration needed to handle a team.
@@ -17,9 +29,78 @@ type TeamConfig struct {
 		Github    GithubConfig    `yaml:"github"`
 	}
 	Messaging struct {
-		Slack SlackConfig `yaml:"slack"`
+		Slack     SlackConfig      `yaml:"slack"`
+		Templates MessageTemplates `yaml:"templates"`
+
+		// Sort and GroupBy set the order of the pull requests and how they are grouped in all messages. They can be overridden by each handler
+		Sort    string `yaml:"sort"`
+		GroupBy string `yaml:"group_by"`
+
+		Delta DeltaConfig `yaml:"delta"`
+
+		// Reports are summaries written on each run, in addition to the Slack messages
+		Reports []ReportConfig `yaml:"reports"`
 	}
 	Users []User `yaml:"users"`
+
+	// Schedule is the team's default time zone, working days and quiet hours. When AgeInBusinessHours is set,
+	// the age of pull requests only counts the time spent in the team's working hours
+	Schedule           `yaml:",inline"`
+	AgeInBusinessHours bool `yaml:"age_in_business_hours"`
+
+	// Holidays and HolidayCalendar (path or URL of an iCalendar file) are the days, in the team's time zone, on which the
+	// reminder doesn't run. They are not counted in the age of pull requests
+	Holidays        []DateRange `yaml:"holidays"`
+	HolidayCalendar string      `yaml:"holiday_calendar"`
+
+	// holidays are the events loaded from the holiday calendar
+	holidays []calendar.Event
+
+	// Escalations is the escalation ladder applied to pull requests needing action, depending on their age
+	Escalations []EscalationRule `yaml:"escalations"`
+
+	// Cron is the cron expression (ex: "0 9 * * MON-FRI") of the team's runs in serve mode. It is read in the team's time zone
+	Cron string `yaml:"cron"`
+}
+
+// EscalationRule is a step of the escalation ladder. Each step is sent once per pull request, when its age reaches the step's delay.
+// When a pull request reaches multiple steps at once, only the last one is sent
+type EscalationRule struct {
+	After time.Duration `yaml:"after"`
+	// MessageReviewers messages the pending reviewers individually (or the author, for pull requests waiting on them)
+	MessageReviewers bool `yaml:"message_reviewers"`
+	// Channel is where the escalated pull requests are posted. Defaults to the team's channel when reviewers are not messaged
+	Channel string `yaml:"channel"`
+	// Mentions are the names of team members or raw Slack mentions (ex: <!subteam^S0123ABCD>) called out in the channel post
+	Mentions []string `yaml:"mentions"`
+}
+
+// GetChannel returns the channel to post the escalation in, given the team's channel. It is empty if the escalation is not posted
+func (rule EscalationRule) GetChannel(teamChannel string) string {
+	if rule.Channel != "" || rule.MessageReviewers {
+		return rule.Channel
+	}
+	return teamChannel
+}
+
+// DeltaConfig represents the "delta" mode, in which messages only contain the pull requests that changed since the last run:
+// pull requests new to the list, that changed category or that have been stale for longer than one of the thresholds since the last run.
+// All pull requests are sent on the first run of the digest day (ex: monday)
+type DeltaConfig struct {
+	Enabled         bool            `yaml:"enabled"`
+	StaleThresholds []time.Duration `yaml:"stale_thresholds"`
+	DigestDay       string          `yaml:"digest_day"`
+}
+
+// ReportConfig represents a summary of the team's pull requests written on each run: a colorized table for the terminal,
+// a Markdown document (ex: for wikis or GitHub issues) or a standalone HTML page
+type ReportConfig struct {
+	// Format is terminal, markdown or html
+	Format string `yaml:"format"`
+	// Path is the file the report is written to. The report is printed on the standard output if it is empty
+	Path string `yaml:"path"`
+	// Sort overrides the team's sort
+	Sort string `yaml:"sort"`
 }
 
 // BitbucketConfig represents a team's bitbucket configuration
@@ -44,15 +125,106 @@ type SlackConfig struct {
 	MessageUsersIndividually bool   `yaml:"message_users_individually"`
 	Token                    string `yaml:"token"`
 
+	// ChannelMessageMode sets what is done with the channel message on each run: "new" (default) posts a new message,
+	// "update" updates the last message and "thread" replies in the thread of the last message.
+	// The last message is reused until it is older than the rollover period (defaults to 24h)
+	ChannelMessageMode string        `yaml:"channel_message_mode"`
+	RolloverPeriod     time.Duration `yaml:"rollover_period"`
+
+	// Interactive adds buttons to snooze, claim or skip each pull request. Clicks are received by the interactions endpoint
+	// and they are verified with the signing secret
+	Interactive   bool   `yaml:"interactive"`
+	SigningSecret string `yaml:"signing_secret"`
+
+	// MentionPendingReviewers mentions, in the channel message, the team reviewers who have not approved the pull requests needing review.
+	// Pull requests needing review without any pending reviewer mention the given user groups (IDs) instead
+	MentionPendingReviewers bool     `yaml:"mention_pending_reviewers"`
+	UnassignedUserGroups    []string `yaml:"unassigned_user_groups"`
+
+	// UserMessagePeriod is the minimum time between two individual messages to the same user. When running frequently (ex: hourly),
+	// it makes sure that users are messaged once, as soon as they are available according to their schedule
+	UserMessagePeriod time.Duration `yaml:"user_message_period"`
+
+	// ResolveUsers finds the Slack user IDs of team members from their email, slack_username or name
+	ResolveUsers bool `yaml:"resolve_users"`
+
+	// AwayStatusEmojis are the Slack status emojis (ex: :palm_tree:) of users who are out of office. Users must be resolved
+	AwayStatusEmojis []string `yaml:"away_status_emojis"`
+
+	// ShowIgnored ends the channel message with the number of ignored pull requests per reason (ex: 3 pull requests ignored: 2 WIP, 1 no reviewers)
+	ShowIgnored bool `yaml:"show_ignored"`
+
+	// Sort, ChannelGroupBy and UserGroupBy override the team's sort and grouping for the channel message and the individual messages
+	Sort           string `yaml:"sort"`
+	ChannelGroupBy string `yaml:"channel_group_by"`
+	UserGroupBy    string `yaml:"user_group_by"`
+
+	Templates MessageTemplates `yaml:"templates"`
+
 	DebugUser string `yaml:"debug_user"`
 }
 
+// GetRolloverPeriod returns the period during which a channel message is reused. Defaults to 24h
+func (config *SlackConfig) GetRolloverPeriod() time.Duration {
+	if config.RolloverPeriod <= 0 {
+		return 24 * time.Hour
+	}
+	return config.RolloverPeriod
+}
+
+// MessageTemplates represents the Go templates (text/template) used to render messages.
+// Empty templates are not overridden
+type MessageTemplates struct {
+	Header           string `yaml:"header"`
+	Continued        string `yaml:"continued"`
+	Repository       string `yaml:"repository"`
+	Group            string `yaml:"group"`
+	ReadyToMerge     string `yaml:"ready_to_merge"`
+	ReadyToReview    string `yaml:"ready_to_review"`
+	ChangesRequested string `yaml:"changes_requested"`
+	PullRequest      string `yaml:"pull_request"`
+	Escalation       string `yaml:"escalation"`
+	Ignored          string `yaml:"ignored"`
+}
+
+// Merge returns the templates with all the non-empty templates of the given overrides applied
+func (templates MessageTemplates) Merge(overrides MessageTemplates) MessageTemplates {
+	var override = func(value *string, overrideValue string) {
+		if overrideValue != "" {
+			*value = overrideValue
+		}
+	}
+	override(&templates.Header, overrides.Header)
+	override(&templates.Continued, overrides.Continued)
+	override(&templates.Repository, overrides.Repository)
+	override(&templates.Group, overrides.Group)
+	override(&templates.ReadyToMerge, overrides.ReadyToMerge)
+	override(&templates.ReadyToReview, overrides.ReadyToReview)
+	override(&templates.ChangesRequested, overrides.ChangesRequested)
+	override(&templates.PullRequest, overrides.PullRequest)
+	override(&templates.Escalation, overrides.Escalation)
+	override(&templates.Ignored, overrides.Ignored)
+	return templates
+}
+
 // User represents a team member's configuration
 type User struct {
 	Name           string `yaml:"name"`
+	Email          string `yaml:"email"`
 	BitbucketUUID  string `yaml:"bitbucket_uuid"`
 	GithubUsername string `yaml:"github_username"`
 	SlackUsername  string `yaml:"slack_username"`
+
+	// Schedule overrides the team's schedule for this user
+	Schedule `yaml:",inline"`
+
+	// OutOfOffice and OutOfOfficeCalendar (path or URL of an iCalendar file) are the periods during which the user is absent.
+	// Absent users are not messaged individually and they are not waited on as reviewers
+	OutOfOffice         []DateRange `yaml:"out_of_office"`
+	OutOfOfficeCalendar string      `yaml:"out_of_office_calendar"`
+
+	// absences are the events loaded from the out of office calendar
+	absences []calendar.Event
 }
 
 // GetNumberOfNeededApprovals returns the number of approvals needed for a pull request to be considered accepted.
@@ -64,6 +236,183 @@ func (config *TeamConfig) GetNumberOfNeededApprovals() int {
 	return config.NumberOfApprovals
 }
 
+// NeedsPullRequestSizes returns true if pull requests are sorted by size in one of the messages. Hosts may need additional calls to get the size
+func (config *TeamConfig) NeedsPullRequestSizes() bool {
+	if config.Messaging.Sort == SortBySize || config.Messaging.Slack.Sort == SortBySize {
+		return true
+	}
+	for _, report := range config.Messaging.Reports {
+		if report.Sort == SortBySize {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserSchedule returns the given user's schedule. Fields that are not set for the user are taken from the team
+func (config *TeamConfig) GetUserSchedule(user User) Schedule {
+	return user.Schedule.Merge(config.Schedule)
+}
+
+// GetAge returns the time elapsed since the given time, excluding holidays. When AgeInBusinessHours is set, only the team's working hours are counted
+func (config *TeamConfig) GetAge(since, now time.Time) time.Duration {
+	if since.IsZero() {
+		return now.Sub(since)
+	}
+	measure := now.Sub
+	if config.AgeInBusinessHours {
+		measure = func(from time.Time) time.Duration { return config.Schedule.BusinessDuration(from, now) }
+	}
+	age := measure(since)
+	for _, holiday := range config.getHolidays() {
+		start, end := holiday.Start, holiday.End
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(now) {
+			end = now
+		}
+		if end.After(start) {
+			// The time measured from the start of the holiday minus the time measured from its end is the holiday's duration
+			age -= measure(start) - measure(end)
+		}
+	}
+	return age
+}
+
+// IsHoliday returns true if the given time is during one of the team's holidays
+func (config *TeamConfig) IsHoliday(at time.Time) bool {
+	return calendar.IsDuring(config.getHolidays(), at)
+}
+
+// IsDigestDue returns true if all pull requests should be sent in delta mode: it is the digest day, in the team's time zone,
+// and the last digest was not sent on the same day
+func (config *TeamConfig) IsDigestDue(lastDigest, now time.Time) bool {
+	digestDay, ok := parseWeekday(config.Messaging.Delta.DigestDay)
+	if !ok {
+		return false
+	}
+	location := config.Schedule.location()
+	now, lastDigest = now.In(location), lastDigest.In(location)
+	if now.Weekday() != digestDay {
+		return false
+	}
+	return lastDigest.Year() != now.Year() || lastDigest.YearDay() != now.YearDay()
+}
+
+// getHolidays returns the configured and loaded holidays in the team's time zone, without overlaps
+func (config *TeamConfig) getHolidays() []calendar.Event {
+	holidays := append([]calendar.Event{}, config.holidays...)
+	for _, dateRange := range config.Holidays {
+		if holiday, err := dateRange.event(config.Schedule.location()); err == nil {
+			holidays = append(holidays, holiday)
+		}
+	}
+	return calendar.Merge(holidays)
+}
+
+// Validate returns an error if the team's configuration is invalid
+func (config *TeamConfig) Validate() error {
+	if err := config.Schedule.Validate(); err != nil {
+		return fmt.Errorf("Invalid schedule for the %s team: %v", config.Name, err)
+	}
+	for _, dateRange := range config.Holidays {
+		if _, err := dateRange.event(time.UTC); err != nil {
+			return fmt.Errorf("Invalid holiday for the %s team: %v", config.Name, err)
+		}
+	}
+	if config.Cron != "" {
+		if _, err := cron.ParseStandard(config.Cron); err != nil {
+			return fmt.Errorf("Invalid cron for the %s team: %v", config.Name, err)
+		}
+	}
+	if digestDay := config.Messaging.Delta.DigestDay; digestDay != "" {
+		if _, ok := parseWeekday(digestDay); !ok {
+			return fmt.Errorf("Invalid digest day %q for the %s team", digestDay, config.Name)
+		}
+	}
+	bitbucketConfig := config.Hosts.Bitbucket
+	hasBitbucketRepositories := len(bitbucketConfig.Repositories)+len(bitbucketConfig.Projects) > 0
+	if config.NeedsPullRequestSizes() && hasBitbucketRepositories && len(config.Hosts.Github.Repositories) == 0 {
+		return fmt.Errorf("Invalid sort for the %s team: pull requests can't be sorted by size, their size is only known on Github", config.Name)
+	}
+	escalationDelays := map[time.Duration]bool{}
+	for _, rule := range config.Escalations {
+		if rule.After <= 0 || escalationDelays[rule.After] {
+			return fmt.Errorf("Invalid escalations for the %s team: each escalation must have a different and positive delay (after)", config.Name)
+		}
+		escalationDelays[rule.After] = true
+		if !rule.MessageReviewers && rule.GetChannel(config.Messaging.Slack.Channel) == "" {
+			return fmt.Errorf("Invalid escalation after %v for the %s team: it must message the reviewers or post in a channel", rule.After, config.Name)
+		}
+	}
+	for _, user := range config.Users {
+		if err := user.Schedule.Validate(); err != nil {
+			return fmt.Errorf("Invalid schedule for %s: %v", user.Name, err)
+		}
+		for _, dateRange := range user.OutOfOffice {
+			if _, err := dateRange.event(time.UTC); err != nil {
+				return fmt.Errorf("Invalid out of office period for %s: %v", user.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// NextRun returns the first time matching the team's cron expression after the given time, in the team's time zone.
+// It is zero if the team has no cron expression
+func (config *TeamConfig) NextRun(after time.Time) time.Time {
+	if config.Cron == "" {
+		return time.Time{}
+	}
+	schedule, err := cron.ParseStandard(config.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(after.In(config.Schedule.location()))
+}
+
+// IsUserAbsent returns true if the given user is out of office at the given time. Dates are read in the user's time zone
+func (config *TeamConfig) IsUserAbsent(user User, at time.Time) bool {
+	for _, teamMember := range config.Users {
+		if teamMember.Name == user.Name {
+			// Users found by hosts may not have the configured absences
+			user = teamMember
+			break
+		}
+	}
+	location := config.GetUserSchedule(user).location()
+	for _, dateRange := range user.OutOfOffice {
+		if event, err := dateRange.event(location); err == nil && event.Contains(at) {
+			return true
+		}
+	}
+	return calendar.IsDuring(user.absences, at)
+}
+
+// loadCalendars reads the team's holiday calendar and the out of office calendars of the team's users
+func (config *TeamConfig) loadCalendars() error {
+	if config.HolidayCalendar != "" {
+		holidays, err := calendar.Load(config.HolidayCalendar, config.Schedule.location())
+		if err != nil {
+			return fmt.Errorf("Unable to load the holiday calendar of the %s team: %v", config.Name, err)
+		}
+		config.holidays = holidays
+	}
+	for index := range config.Users {
+		user := &config.Users[index]
+		if user.OutOfOfficeCalendar == "" {
+			continue
+		}
+		absences, err := calendar.Load(user.OutOfOfficeCalendar, config.GetUserSchedule(*user).location())
+		if err != nil {
+			return fmt.Errorf("Unable to load the out of office calendar of %s: %v", user.Name, err)
+		}
+		user.absences = absences
+	}
+	return nil
+}
+
 // IsBitbucketConfigured returns true if all necessary configurations are set to handle Bitbucket
 func (config *TeamConfig) IsBitbucketConfigured() bool {
 	bitbucketConfig := config.Hosts.Bitbucket
@@ -104,4 +453,7 @@ func (config *TeamConfig) setEnvironmentConfig(envConfig *EnvironmentConfig) {
 	if slackConfig.Token == "" {
 		slackConfig.Token = envConfig.SlackToken
 	}
+	if slackConfig.SigningSecret == "" {
+		slackConfig.SigningSecret = envConfig.SlackSigningSecret
+	}
 }
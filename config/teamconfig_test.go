@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -61,3 +62,156 @@ func TestGetNumberOfNeededApprovals(t *testing.T) {
 	config = &TeamConfig{NumberOfApprovals: 2}
 	assert.Equal(t, 2, config.GetNumberOfNeededApprovals())
 }
+
+func TestMergeMessageTemplates(t *testing.T) {
+	t.Parallel()
+
+	templates := MessageTemplates{Header: "header", PullRequest: "pr"}
+	merged := templates.Merge(MessageTemplates{Header: "new header", Repository: "repo"})
+	assert.Equal(t, MessageTemplates{Header: "new header", Repository: "repo", PullRequest: "pr"}, merged)
+	assert.Equal(t, "header", templates.Header) // The original is not modified
+}
+
+func TestGetRolloverPeriod(t *testing.T) {
+	t.Parallel()
+
+	config := &SlackConfig{}
+	assert.Equal(t, 24*time.Hour, config.GetRolloverPeriod())
+
+	config = &SlackConfig{RolloverPeriod: time.Hour}
+	assert.Equal(t, time.Hour, config.GetRolloverPeriod())
+}
+
+func TestGetAge(t *testing.T) {
+	t.Parallel()
+
+	friday := time.Date(2019, 7, 19, 16, 0, 0, 0, time.UTC)
+	monday := time.Date(2019, 7, 22, 10, 0, 0, 0, time.UTC)
+	config := &TeamConfig{}
+	config.WorkingDays = []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+	assert.Equal(t, monday.Sub(friday), config.GetAge(friday, monday))
+
+	config.AgeInBusinessHours = true
+	assert.Equal(t, 18*time.Hour, config.GetAge(friday, monday))
+}
+
+func TestGetAgeWithHolidays(t *testing.T) {
+	t.Parallel()
+
+	montreal, _ := time.LoadLocation("America/Montreal")
+	config := &TeamConfig{
+		Name:     "my-team",
+		Schedule: Schedule{Timezone: "America/Montreal"},
+		// The same holiday configured twice is only excluded once
+		Holidays: []DateRange{{From: "2019-07-01"}, {From: "2019-07-01", To: "2019-07-01"}},
+	}
+	friday := time.Date(2019, 6, 28, 16, 0, 0, 0, montreal)
+	tuesday := time.Date(2019, 7, 2, 10, 0, 0, 0, montreal)
+	assert.True(t, config.IsHoliday(time.Date(2019, 7, 1, 9, 0, 0, 0, montreal)))
+	assert.False(t, config.IsHoliday(tuesday))
+	assert.Equal(t, tuesday.Sub(friday)-24*time.Hour, config.GetAge(friday, tuesday))
+
+	// The holiday is partially elapsed
+	mondayNoon := time.Date(2019, 7, 1, 12, 0, 0, 0, montreal)
+	assert.Equal(t, 2*24*time.Hour+8*time.Hour, config.GetAge(friday, mondayNoon))
+
+	config.AgeInBusinessHours = true
+	config.WorkingDays = []string{"mon", "tue", "wed", "thu", "fri"}
+	assert.Equal(t, 18*time.Hour, config.GetAge(friday, tuesday))
+
+	config.Holidays = []DateRange{{From: "2019-07-02", To: "2019-07-01"}}
+	assert.EqualError(t, config.Validate(), "Invalid holiday for the my-team team: The period ends (2019-07-01) before it starts (2019-07-02)")
+}
+
+func TestIsUserAbsent(t *testing.T) {
+	t.Parallel()
+
+	config := &TeamConfig{
+		Schedule: Schedule{Timezone: "America/Montreal"},
+		Users: []User{
+			{Name: "user1", OutOfOffice: []DateRange{{From: "2019-07-15", To: "2019-07-19"}, {From: "2019-07-24"}}},
+			{Name: "user2"},
+		},
+	}
+	montreal, _ := time.LoadLocation("America/Montreal")
+	user1 := User{Name: "user1"} // Absences are taken from the team's configuration
+
+	assert.False(t, config.IsUserAbsent(user1, time.Date(2019, 7, 14, 23, 59, 0, 0, montreal)))
+	assert.True(t, config.IsUserAbsent(user1, time.Date(2019, 7, 15, 0, 0, 0, 0, montreal)))
+	assert.True(t, config.IsUserAbsent(user1, time.Date(2019, 7, 19, 23, 59, 0, 0, montreal)))
+	assert.False(t, config.IsUserAbsent(user1, time.Date(2019, 7, 20, 0, 0, 0, 0, montreal)))
+	assert.True(t, config.IsUserAbsent(user1, time.Date(2019, 7, 24, 12, 0, 0, 0, montreal)))
+	assert.False(t, config.IsUserAbsent(user1, time.Date(2019, 7, 25, 0, 0, 0, 0, montreal)))
+	assert.False(t, config.IsUserAbsent(User{Name: "user2"}, time.Date(2019, 7, 16, 12, 0, 0, 0, montreal)))
+
+	config.Users[0].OutOfOffice = []DateRange{{From: "2019-07-19", To: "2019-07-15"}}
+	assert.EqualError(t, config.Validate(), "Invalid out of office period for user1: The period ends (2019-07-15) before it starts (2019-07-19)")
+}
+
+func TestValidateEscalations(t *testing.T) {
+	t.Parallel()
+
+	config := &TeamConfig{Name: "my-team", Escalations: []EscalationRule{
+		{After: 48 * time.Hour, MessageReviewers: true},
+		{After: 96 * time.Hour, Mentions: []string{"Tech Lead"}},
+	}}
+	assert.EqualError(t, config.Validate(), "Invalid escalation after 96h0m0s for the my-team team: it must message the reviewers or post in a channel")
+
+	config.Messaging.Slack.Channel = "#my-channel"
+	assert.Nil(t, config.Validate())
+	assert.Equal(t, "#my-channel", config.Escalations[1].GetChannel(config.Messaging.Slack.Channel))
+	assert.Equal(t, "", config.Escalations[0].GetChannel(config.Messaging.Slack.Channel))
+
+	config.Escalations = append(config.Escalations, EscalationRule{After: 48 * time.Hour, Channel: "#managers"})
+	assert.EqualError(t, config.Validate(), "Invalid escalations for the my-team team: each escalation must have a different and positive delay (after)")
+}
+
+func TestValidateSortBySize(t *testing.T) {
+	t.Parallel()
+
+	config := &TeamConfig{Name: "my-team"}
+	config.Hosts.Bitbucket.Repositories = []string{"owner/repo"}
+	config.Messaging.Reports = []ReportConfig{{Sort: SortBySize}}
+	assert.EqualError(t, config.Validate(), "Invalid sort for the my-team team: pull requests can't be sorted by size, their size is only known on Github")
+
+	config.Hosts.Github.Repositories = []string{"owner/repo"}
+	assert.Nil(t, config.Validate())
+}
+
+func TestIsDigestDue(t *testing.T) {
+	t.Parallel()
+
+	montreal, _ := time.LoadLocation("America/Montreal")
+	monday := time.Date(2019, 7, 22, 9, 0, 0, 0, montreal)
+	config := &TeamConfig{Name: "my-team", Schedule: Schedule{Timezone: "America/Montreal"}}
+	assert.False(t, config.IsDigestDue(time.Time{}, monday))
+
+	config.Messaging.Delta.DigestDay = "monday"
+	assert.Nil(t, config.Validate())
+	assert.True(t, config.IsDigestDue(time.Time{}, monday))
+	assert.True(t, config.IsDigestDue(monday.AddDate(0, 0, -7), monday))
+	assert.False(t, config.IsDigestDue(monday.Add(-time.Hour), monday)) // Already sent today
+	assert.False(t, config.IsDigestDue(time.Time{}, monday.AddDate(0, 0, 1)))
+	// Monday 01:00 in UTC is still sunday in Montreal
+	assert.False(t, config.IsDigestDue(time.Time{}, time.Date(2019, 7, 22, 1, 0, 0, 0, time.UTC)))
+
+	config.Messaging.Delta.DigestDay = "someday"
+	assert.EqualError(t, config.Validate(), `Invalid digest day "someday" for the my-team team`)
+}
+
+func TestNextRun(t *testing.T) {
+	t.Parallel()
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	config := &TeamConfig{Name: "my-team", Schedule: Schedule{Timezone: "Asia/Tokyo"}}
+	now := time.Date(2019, 7, 22, 12, 0, 0, 0, time.UTC)
+	assert.True(t, config.NextRun(now).IsZero())
+
+	config.Cron = "0 9 * * MON-FRI"
+	assert.Nil(t, config.Validate())
+	// 9am in Tokyo, the next day
+	assert.True(t, time.Date(2019, 7, 23, 9, 0, 0, 0, tokyo).Equal(config.NextRun(now)))
+
+	config.Cron = "0 9 * *"
+	assert.EqualError(t, config.Validate(), `Invalid cron for the my-team team: expected exactly 5 fields, found 4: [0 9 * *]`)
+}
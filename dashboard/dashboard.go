@@ -0,0 +1,199 @@
+package dashboard
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/api"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+	log "github.com/sirupsen/logrus"
+)
+
+// categories are the sections of a team's dashboard, in order
+var categories = []struct {
+	name  string
+	title string
+}{
+	{hosts.ReadyToMergeCategory, "Ready to merge"},
+	{hosts.ReadyToReviewCategory, "In need of approvers"},
+	{hosts.ChangesRequestedCategory, "Waiting on their author (changes requested)"},
+	{api.IgnoredCategory, "Ignored"},
+}
+
+// pageData is the data rendered by the dashboard template
+type pageData struct {
+	Teams []teamData
+	// Team is the selected team. All teams are shown if it is empty
+	Team     string
+	User     string
+	Category string
+}
+
+type teamData struct {
+	Name       string
+	FetchedAt  time.Time
+	Categories []categoryData
+}
+
+type categoryData struct {
+	Name         string
+	Title        string
+	PullRequests []pullRequestRow
+}
+
+type pullRequestRow struct {
+	*api.PullRequest
+	Repository     string
+	RepositoryLink string
+	Host           string
+	Age            time.Duration
+	StaleFor       time.Duration
+}
+
+var pageTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"humanizeDuration": utilities.HumanizeDuration,
+	"categoryTitle": func(name string) string {
+		for _, category := range categories {
+			if category.name == name {
+				return category.title
+			}
+		}
+		return name
+	},
+	"categoryNames": func() []string {
+		names := []string{}
+		for _, category := range categories {
+			names = append(names, category.name)
+		}
+		return names
+	},
+}).Parse(pageHTML))
+
+// NewHandler returns the handler of the dashboard. It serves all teams on /dashboard and a single team on /dashboard/<team>.
+// The pull requests can be filtered by user (?user=<name>) and by category (?category=ready_to_review)
+func NewHandler(snapshots *api.Snapshots) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		data := pageData{
+			Team:     strings.Trim(strings.TrimPrefix(request.URL.Path, "/dashboard"), "/"),
+			User:     query.Get("user"),
+			Category: query.Get("category"),
+			Teams:    []teamData{},
+		}
+
+		teamSnapshots := snapshots.List()
+		if data.Team != "" {
+			snapshot := snapshots.Get(data.Team)
+			if snapshot == nil {
+				http.Error(writer, "Unknown team or the team has not been run yet", http.StatusNotFound)
+				return
+			}
+			teamSnapshots = []*api.TeamSnapshot{snapshot}
+		}
+		now := time.Now()
+		for _, snapshot := range teamSnapshots {
+			data.Teams = append(data.Teams, newTeamData(snapshot.Filter(data.User, "", data.Category), now))
+		}
+
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := pageTemplate.Execute(writer, data); err != nil {
+			log.WithError(err).Errorln("Unable to render the dashboard")
+		}
+	})
+}
+
+// newTeamData groups the team's pull requests by category, the oldest first
+func newTeamData(snapshot *api.TeamSnapshot, now time.Time) teamData {
+	rowsPerCategory := map[string][]pullRequestRow{}
+	for _, repository := range snapshot.Repositories {
+		for _, pullRequest := range repository.PullRequests {
+			row := pullRequestRow{
+				PullRequest:    pullRequest,
+				Repository:     repository.Name,
+				RepositoryLink: repository.Link,
+				Host:           repository.Host,
+			}
+			if !pullRequest.CreateTime.IsZero() {
+				row.Age = now.Sub(pullRequest.CreateTime)
+			}
+			if !pullRequest.UpdateTime.IsZero() {
+				row.StaleFor = now.Sub(pullRequest.UpdateTime)
+			}
+			rowsPerCategory[pullRequest.Category] = append(rowsPerCategory[pullRequest.Category], row)
+		}
+	}
+
+	team := teamData{Name: snapshot.Team, FetchedAt: snapshot.FetchedAt}
+	for _, category := range categories {
+		rows := rowsPerCategory[category.name]
+		if len(rows) == 0 {
+			continue
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Age > rows[j].Age })
+		team.Categories = append(team.Categories, categoryData{Name: category.name, Title: category.title, PullRequests: rows})
+	}
+	return team
+}
+
+const pageHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ with .Team }}{{ . }} - {{ end }}Pull requests</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.4em 0.8em; border-bottom: 1px solid #e1e4e8; vertical-align: top; }
+th { background: #f6f8fa; }
+a { color: #0366d6; text-decoration: none; }
+.muted { color: #6a737d; font-size: 0.9em; }
+.approved { color: #28a745; }
+.changes { color: #d73a49; }
+.absent { color: #6a737d; text-decoration: line-through; }
+form { margin-bottom: 2em; }
+</style>
+</head>
+<body>
+<h1>{{ if .Team }}{{ .Team }}{{ else }}All teams{{ end }}</h1>
+<form method="get">
+  <label>User <input type="text" name="user" value="{{ .User }}" placeholder="Name or username"></label>
+  <label>Category
+    <select name="category">
+      <option value="">All</option>
+      {{ $selected := .Category }}{{ range categoryNames }}<option value="{{ . }}"{{ if eq . $selected }} selected{{ end }}>{{ categoryTitle . }}</option>{{ end }}
+    </select>
+  </label>
+  <input type="submit" value="Filter">
+</form>
+{{ range .Teams }}
+<h2><a href="/dashboard/{{ .Name }}">{{ .Name }}</a> <span class="muted">fetched {{ .FetchedAt.Format "2006-01-02 15:04 MST" }}</span></h2>
+{{ range .Categories }}
+<h3>{{ .Title }} ({{ len .PullRequests }})</h3>
+<table>
+  <tr><th>Pull request</th><th>Repository</th><th>Author</th><th>Age</th><th>Updated</th><th>Approvals</th><th>Reviewers</th><th>{{ if eq .Name "ignored" }}Reason{{ else }}Claimed by{{ end }}</th></tr>
+  {{ range .PullRequests }}
+  <tr>
+    <td><a href="{{ .Link }}">{{ .Title }}</a></td>
+    <td><a href="{{ .RepositoryLink }}">{{ .Repository }}</a> <span class="muted">{{ .Host }}</span></td>
+    <td><a href="?user={{ .Author.Name }}">{{ .Author.Name }}</a></td>
+    <td>{{ if .Age }}{{ humanizeDuration .Age }}{{ end }}</td>
+    <td>{{ if .StaleFor }}{{ humanizeDuration .StaleFor }} ago{{ end }}</td>
+    <td>{{ .Approvals }}/{{ .NeededApprovals }}</td>
+    <td>{{ range $index, $reviewer := .Reviewers }}{{ if $index }}, {{ end }}<a href="?user={{ .Name }}" class="{{ if .Approved }}approved{{ else if .RequestedChanges }}changes{{ else if .Absent }}absent{{ end }}">{{ .Name }}</a>{{ end }}</td>
+    <td>{{ if eq .Category "ignored" }}{{ .Explanation }}{{ else }}{{ .ClaimedBy }}{{ end }}</td>
+  </tr>
+  {{ end }}
+</table>
+{{ else }}
+<p class="muted">No pull requests</p>
+{{ end }}
+{{ else }}
+<p class="muted">No team has been run yet</p>
+{{ end }}
+</body>
+</html>
+`
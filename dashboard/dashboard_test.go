@@ -0,0 +1,98 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/api"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSnapshots() *api.Snapshots {
+	now := time.Now()
+	snapshots := api.NewSnapshots()
+	snapshots.Set(&api.TeamSnapshot{
+		Team:      "my-team",
+		FetchedAt: now,
+		Repositories: []*api.Repository{
+			{Name: "repo", Link: "repo.com", Host: "Github", PullRequests: []*api.PullRequest{
+				{
+					Title: "young", Link: "young.com", Author: api.User{Name: "author"}, CreateTime: now.Add(-time.Hour),
+					Category: hosts.ReadyToReviewCategory, NeededApprovals: 2,
+				},
+				{
+					Title: "old <script>", Link: "old.com", Author: api.User{Name: "author"}, CreateTime: now.Add(-72 * time.Hour),
+					Category: hosts.ReadyToReviewCategory, Approvals: 1, NeededApprovals: 2,
+					Reviewers: []*api.Reviewer{{User: api.User{Name: "reviewer"}, Approved: true, TeamMember: true}},
+				},
+				{
+					Title: "wip", Link: "wip.com", Author: api.User{Name: "reviewer"}, CreateTime: now.Add(-time.Hour),
+					Category: api.IgnoredCategory, Reason: hosts.WIPReason, Explanation: "Marked WIP",
+				},
+			}},
+		},
+	})
+	snapshots.Set(&api.TeamSnapshot{Team: "other-team", FetchedAt: now, Repositories: []*api.Repository{}})
+	return snapshots
+}
+
+func TestNewTeamData(t *testing.T) {
+	t.Parallel()
+
+	snapshot := newTestSnapshots().Get("my-team")
+	team := newTeamData(snapshot, snapshot.FetchedAt)
+	assert.Equal(t, "my-team", team.Name)
+	assert.Len(t, team.Categories, 2)
+
+	readyToReview := team.Categories[0]
+	assert.Equal(t, hosts.ReadyToReviewCategory, readyToReview.Name)
+	assert.Len(t, readyToReview.PullRequests, 2)
+	assert.Equal(t, "old <script>", readyToReview.PullRequests[0].Title) // The oldest first
+	assert.Equal(t, 72*time.Hour, readyToReview.PullRequests[0].Age)
+	assert.Equal(t, "repo", readyToReview.PullRequests[0].Repository)
+	assert.Equal(t, "Github", readyToReview.PullRequests[0].Host)
+
+	assert.Equal(t, api.IgnoredCategory, team.Categories[1].Name)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := NewHandler(newTestSnapshots())
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+
+	recorder := get("/dashboard")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	body := recorder.Body.String()
+	assert.Contains(t, body, `<a href="/dashboard/my-team">my-team</a>`)
+	assert.Contains(t, body, `<a href="/dashboard/other-team">other-team</a>`)
+	assert.Contains(t, body, "In need of approvers (2)")
+	assert.Contains(t, body, `<a href="old.com">old &lt;script&gt;</a>`) // The titles are escaped
+	assert.Contains(t, body, "3 days")
+	assert.Contains(t, body, "1/2")
+	assert.Contains(t, body, `class="approved">reviewer</a>`)
+	assert.Contains(t, body, "Marked WIP")
+
+	body = get("/dashboard/my-team?category=ignored").Body.String()
+	assert.NotContains(t, body, "other-team")
+	assert.NotContains(t, body, "old.com")
+	assert.Contains(t, body, "wip.com")
+	assert.Contains(t, body, `<option value="ignored" selected>`)
+
+	body = get("/dashboard/my-team?user=author").Body.String()
+	assert.Contains(t, body, "old.com")
+	assert.NotContains(t, body, "wip.com")
+
+	body = get("/dashboard/other-team").Body.String()
+	assert.Contains(t, body, "No pull requests")
+
+	assert.Equal(t, http.StatusNotFound, get("/dashboard/unknown-team").Code)
+}
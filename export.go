@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+)
+
+// Output formats of the export command
+const (
+	csvExportFormat       = "csv"
+	jsonLinesExportFormat = "jsonl"
+)
+
+// exportColumns are the header of the CSV export. They match the JSON fields of exportRow
+var exportColumns = []string{"team", "repository", "host", "title", "link", "author", "created", "updated", "reviewers", "approvals",
+	"needed_approvals", "category", "reason", "waiting_hours"}
+
+// exportRow is a pull request written by the export command
+type exportRow struct {
+	Team       string `json:"team"`
+	Repository string `json:"repository"`
+	Host       string `json:"host"`
+	Title      string `json:"title"`
+	Link       string `json:"link"`
+	Author     string `json:"author"`
+	// Created and Updated are null (empty in CSV) when the host doesn't give them
+	Created *time.Time `json:"created"`
+	Updated *time.Time `json:"updated"`
+	// Reviewers are the names of all reviewers and Approvals the number of team members who approved
+	Reviewers       []string `json:"reviewers"`
+	Approvals       int      `json:"approvals"`
+	NeededApprovals int      `json:"needed_approvals"`
+	// Category is ready_to_merge, ready_to_review, changes_requested or ignored and Reason is the reason of the verdict
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+	// WaitingHours is the time spent waiting in the current category: since the last update for pull requests ready to merge
+	// (like the categorizer) and since the creation for the others. It is measured like in the messages: holidays are excluded
+	// and only working hours are counted if the team has age_in_business_hours set
+	WaitingHours float64 `json:"waiting_hours"`
+}
+
+// newExportRows returns a row for each open pull request of the given repositories
+func newExportRows(team *config.TeamConfig, repositories []hosts.Repository, now time.Time) []*exportRow {
+	rows := []*exportRow{}
+	for _, repository := range repositories {
+		host := ""
+		if repository.GetHost() != nil {
+			host = repository.GetHost().GetName()
+		}
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		for _, category := range []struct {
+			name         string
+			pullRequests []*hosts.PullRequest
+		}{
+			{hosts.ReadyToMergeCategory, readyToMerge},
+			{hosts.ReadyToReviewCategory, readyToReview},
+			{hosts.ChangesRequestedCategory, changesRequested},
+			{hosts.IgnoredCategory, repository.GetIgnoredPullRequests()},
+		} {
+			for _, pullRequest := range category.pullRequests {
+				row := &exportRow{
+					Team:            team.Name,
+					Repository:      repository.GetName(),
+					Host:            host,
+					Title:           pullRequest.Title,
+					Link:            pullRequest.Link,
+					Author:          getUserName(pullRequest.Author),
+					Created:         optionalTime(pullRequest.CreateTime),
+					Updated:         optionalTime(pullRequest.UpdateTime),
+					Reviewers:       []string{},
+					NeededApprovals: team.GetNumberOfNeededApprovals(),
+					Category:        category.name,
+				}
+				if pullRequest.Verdict != nil {
+					row.Reason = pullRequest.Verdict.Reason
+				}
+				for _, reviewer := range pullRequest.Reviewers {
+					row.Reviewers = append(row.Reviewers, getUserName(reviewer.User))
+					if reviewer.User.Name != "" && reviewer.Approved {
+						row.Approvals++
+					}
+				}
+				waitingSince := pullRequest.CreateTime
+				if category.name == hosts.ReadyToMergeCategory {
+					waitingSince = pullRequest.UpdateTime
+				}
+				if !waitingSince.IsZero() {
+					row.WaitingHours = math.Round(team.GetAge(waitingSince, now).Hours()*100) / 100
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	return &value
+}
+
+// getUserName returns the name of team members, and the host's identifier of other users
+func getUserName(user config.User) string {
+	return utilities.FirstNonEmpty(user.Name, user.GithubUsername, user.BitbucketUUID)
+}
+
+// csvRecord returns the row's values in the order of the export columns. Times are in RFC 3339 and reviewers are separated by semicolons
+func (row *exportRow) csvRecord() []string {
+	formatTime := func(value *time.Time) string {
+		if value == nil {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	}
+	return []string{
+		row.Team, row.Repository, row.Host, row.Title, row.Link, row.Author, formatTime(row.Created), formatTime(row.Updated),
+		strings.Join(row.Reviewers, ";"), strconv.Itoa(row.Approvals), strconv.Itoa(row.NeededApprovals), row.Category, row.Reason,
+		strconv.FormatFloat(row.WaitingHours, 'f', 2, 64),
+	}
+}
+
+func writeExportRows(writer io.Writer, format string, rows []*exportRow) error {
+	if format == jsonLinesExportFormat {
+		encoder := json.NewEncoder(writer)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write(exportColumns)
+	for _, row := range rows {
+		csvWriter.Write(row.csvRecord())
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// exportCommand writes a row per open pull request of the teams
+func exportCommand(context *commandContext, arguments []string) error {
+	now := time.Now()
+	rows := []*exportRow{}
+	err := fetchRepositories(context, func(team *config.TeamConfig, repositories []hosts.Repository) {
+		rows = append(rows, newExportRows(team, repositories, now)...)
+	})
+	if err != nil {
+		return err
+	}
+	return writeExportRows(context.stdout, context.options.output, rows)
+}
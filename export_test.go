@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func newExportTestRows(t *testing.T, now time.Time) []*exportRow {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHost := hosts.NewMockHost(ctrl)
+	mockHost.EXPECT().GetName().Return("Github").AnyTimes()
+	mockRepository := hosts.NewMockRepository(ctrl)
+	mockRepository.EXPECT().GetHost().Return(mockHost).AnyTimes()
+	mockRepository.EXPECT().GetName().Return("repo").AnyTimes()
+	mockRepository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*hosts.PullRequest{{
+			Title: "Approved", Link: "pr0.com", Author: config.User{Name: "Jane"}, CreateTime: now.Add(-48 * time.Hour), UpdateTime: now.Add(-3 * time.Hour),
+			Reviewers: []*hosts.Reviewer{{User: config.User{Name: "John"}, Approved: true}, {User: config.User{Name: "Jack"}, Approved: true}},
+			Verdict:   &hosts.Verdict{Category: hosts.ReadyToMergeCategory, Reason: hosts.ApprovedReason},
+		}},
+		[]*hosts.PullRequest{{
+			Title: "Add a feature, with commas", Link: "pr1.com", Author: config.User{Name: "John"},
+			CreateTime: now.Add(-36 * time.Hour), UpdateTime: now.Add(-time.Hour),
+			Reviewers: []*hosts.Reviewer{
+				{User: config.User{Name: "Jane"}, Approved: true},
+				{User: config.User{GithubUsername: "outsider"}, Approved: true},
+			},
+			Verdict: &hosts.Verdict{Category: hosts.ReadyToReviewCategory, Reason: hosts.NeedsApprovalsReason},
+		}},
+		[]*hosts.PullRequest{},
+	).AnyTimes()
+	mockRepository.EXPECT().GetIgnoredPullRequests().Return([]*hosts.PullRequest{{
+		Title: "WIP", Link: "pr2.com", Author: config.User{GithubUsername: "outsider"}, CreateTime: now.Add(-30 * time.Minute),
+		Verdict: &hosts.Verdict{Category: hosts.IgnoredCategory, Reason: hosts.WIPReason},
+	}}).AnyTimes()
+
+	team := &config.TeamConfig{Name: "my-team", NumberOfApprovals: 2}
+	return newExportRows(team, []hosts.Repository{mockRepository}, now)
+}
+
+func TestNewExportRows(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	rows := newExportTestRows(t, now)
+	assert.Len(t, rows, 3)
+	created, updated := now.Add(-36*time.Hour), now.Add(-time.Hour)
+	assert.Equal(t, &exportRow{
+		Team: "my-team", Repository: "repo", Host: "Github", Title: "Add a feature, with commas", Link: "pr1.com", Author: "John",
+		Created: &created, Updated: &updated, Reviewers: []string{"Jane", "outsider"},
+		Approvals: 1, NeededApprovals: 2, Category: hosts.ReadyToReviewCategory, Reason: hosts.NeedsApprovalsReason, WaitingHours: 36,
+	}, rows[1])
+
+	// Pull requests ready to merge wait since their last update
+	assert.Equal(t, hosts.ReadyToMergeCategory, rows[0].Category)
+	assert.Equal(t, hosts.ApprovedReason, rows[0].Reason)
+	assert.Equal(t, 3.0, rows[0].WaitingHours)
+
+	assert.Equal(t, "outsider", rows[2].Author)
+	assert.Equal(t, hosts.IgnoredCategory, rows[2].Category)
+	assert.Equal(t, hosts.WIPReason, rows[2].Reason)
+	assert.Nil(t, rows[2].Updated)
+	assert.Equal(t, 0.5, rows[2].WaitingHours)
+}
+
+func TestWriteExportRows(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2019, 7, 22, 12, 0, 0, 0, time.UTC)
+	rows := newExportTestRows(t, now)
+
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, writeExportRows(buffer, csvExportFormat, rows))
+	lines := strings.Split(buffer.String(), "\n")
+	assert.Equal(t, "team,repository,host,title,link,author,created,updated,reviewers,approvals,needed_approvals,category,reason,waiting_hours", lines[0])
+	assert.Equal(t, "my-team,repo,Github,Approved,pr0.com,Jane,2019-07-20T12:00:00Z,2019-07-22T09:00:00Z,John;Jack,2,2,ready_to_merge,approved,3.00", lines[1])
+	assert.Equal(t, `my-team,repo,Github,"Add a feature, with commas",pr1.com,John,2019-07-21T00:00:00Z,2019-07-22T11:00:00Z,Jane;outsider,1,2,ready_to_review,needs_approvals,36.00`, lines[2])
+	assert.Equal(t, "my-team,repo,Github,WIP,pr2.com,outsider,2019-07-22T11:30:00Z,,,0,2,ignored,wip,0.50", lines[3])
+
+	buffer = &bytes.Buffer{}
+	assert.Nil(t, writeExportRows(buffer, jsonLinesExportFormat, rows))
+	lines = strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, `{"team":"my-team","repository":"repo","host":"Github","title":"Add a feature, with commas","link":"pr1.com","author":"John",`+
+		`"created":"2019-07-21T00:00:00Z","updated":"2019-07-22T11:00:00Z","reviewers":["Jane","outsider"],"approvals":1,"needed_approvals":2,`+
+		`"category":"ready_to_review","reason":"needs_approvals","waiting_hours":36}`, lines[1])
+	// Missing times are null, like they are empty in CSV
+	assert.Equal(t, `{"team":"my-team","repository":"repo","host":"Github","title":"WIP","link":"pr2.com","author":"outsider",`+
+		`"created":"2019-07-22T11:30:00Z","updated":null,"reviewers":[],"approvals":0,"needed_approvals":2,`+
+		`"category":"ignored","reason":"wip","waiting_hours":0.5}`, lines[2])
+}
+
+func TestExportCommandWithInvalidOutput(t *testing.T) {
+	assert.EqualError(t, execute([]string{"export", "-output", "xlsx"}, ioutil.Discard, ioutil.Discard), `Invalid output format "xlsx". It must be csv or jsonl`)
+	assert.EqualError(t, execute([]string{"export", "-output", "json"}, ioutil.Discard, ioutil.Discard), `Invalid output format "json". It must be csv or jsonl`)
+}
@@ -4,21 +4,18 @@ import (
 	"fmt"
 	"path/filepath"
 	reflect "reflect"
-	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	"github.com/julienduchesne/pull-request-reminder/utilities"
 	"github.com/ktrysmt/go-bitbucket"
 	"github.com/matryer/try"
 	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/text/transform"
-	"golang.org/x/text/unicode/norm"
 )
 
 type bitbucketPullRequest struct {
@@ -93,6 +90,8 @@ func (pr *bitbucketPullRequest) ToGenericPullRequest(users map[string]config.Use
 	return genericPullRequest
 }
 
+const bitbucketHostName = "Bitbucket"
+
 type bitbucketClient interface {
 	GetPullRequests(args ...string) (interface{}, error)
 	GetRepositories(args ...string) (interface{}, error)
@@ -120,6 +119,7 @@ func (wrapper *bitbucketClientWrapper) GetTeamMembers(args ...string) (interface
 
 type bitbucketCloud struct {
 	config          *config.TeamConfig
+	store           state.Store
 	client          bitbucketClient
 	repositoryNames []string
 	projects        []string
@@ -127,10 +127,11 @@ type bitbucketCloud struct {
 	users           map[string]config.User
 }
 
-func newBitbucketCloud(config *config.TeamConfig) *bitbucketCloud {
+func newBitbucketCloud(config *config.TeamConfig, store state.Store) *bitbucketCloud {
 	bitbucketConfig := config.Hosts.Bitbucket
 	return &bitbucketCloud{
 		config:          config,
+		store:           store,
 		client:          &bitbucketClientWrapper{client: bitbucket.NewBasicAuth(bitbucketConfig.Username, bitbucketConfig.Password)},
 		repositoryNames: bitbucketConfig.Repositories,
 		projects:        bitbucketConfig.Projects,
@@ -143,8 +144,12 @@ func (host *bitbucketCloud) GetConfig() *config.TeamConfig {
 	return host.config
 }
 
+func (host *bitbucketCloud) GetStore() state.Store {
+	return host.store
+}
+
 func (host *bitbucketCloud) GetName() string {
-	return "Bitbucket"
+	return bitbucketHostName
 }
 
 func (host *bitbucketCloud) GetUsers() (map[string]config.User, error) {
@@ -170,7 +175,7 @@ func (host *bitbucketCloud) GetUsers() (map[string]config.User, error) {
 			// If a team member's name matches with a user missing a UUID, use the team member's UUID
 			if user.BitbucketUUID == "" && findUsersInTeam {
 				for _, member := range teamMembers {
-					if normalizeName(member.DisplayName) == normalizeName(user.Name) || normalizeName(member.Nickname) == normalizeName(user.Name) {
+					if utilities.NormalizeName(member.DisplayName) == utilities.NormalizeName(user.Name) || utilities.NormalizeName(member.Nickname) == utilities.NormalizeName(user.Name) {
 						if user.BitbucketUUID != "" {
 							return nil, fmt.Errorf("User %s has multiple matches for bitbucket users. Please set the UUID directly", user.Name)
 						}
@@ -295,7 +300,9 @@ func (host *bitbucketCloud) callAPI(value interface{}, fn func(args ...string) (
 		response interface{}
 	)
 	if err := try.Do(func(attempt int) (bool, error) {
+		start := time.Now()
 		response, err = fn(args...)
+		observeAPICall(bitbucketHostName, start, err)
 		if err != nil {
 			log.Warnf("Failed to call %s. Waiting 10 seconds", functionName)
 			secondsToSleep, _ := strconv.Atoi(utilities.GetEnv("BITBUCKET_RETRY_DELAY", "10"))
@@ -310,13 +317,3 @@ func (host *bitbucketCloud) callAPI(value interface{}, fn func(args ...string) (
 	}
 	return nil
 }
-
-func isMn(r rune) bool {
-	return unicode.Is(unicode.Mn, r) // Mn: nonspacing marks
-}
-
-func normalizeName(name string) string {
-	transformChain := transform.Chain(norm.NFD, transform.RemoveFunc(isMn), norm.NFC)
-	result, _, _ := transform.String(transformChain, name)
-	return strings.ToLower(regexp.MustCompile("[^A-Za-z]").ReplaceAllString(result, ""))
-}
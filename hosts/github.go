@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v25/github"
 	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
 
+const githubHostName = "Github"
+
 type githubClient interface {
+	GetPullRequest(owner string, repo string, number int) (*github.PullRequest, *github.Response, error)
 	ListPullRequests(owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
 	ListReviews(owner, repo string, number int, opt *github.ListOptions) ([]*github.PullRequestReview, *github.Response, error)
 }
@@ -21,21 +26,35 @@ type githubClientWrapper struct {
 	ctx    context.Context
 }
 
+func (wrapper *githubClientWrapper) GetPullRequest(owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	start := time.Now()
+	pullRequest, response, err := wrapper.client.PullRequests.Get(wrapper.ctx, owner, repo, number)
+	observeAPICall(githubHostName, start, err)
+	return pullRequest, response, err
+}
+
 func (wrapper *githubClientWrapper) ListPullRequests(owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
-	return wrapper.client.PullRequests.List(wrapper.ctx, owner, repo, opt)
+	start := time.Now()
+	pullRequests, response, err := wrapper.client.PullRequests.List(wrapper.ctx, owner, repo, opt)
+	observeAPICall(githubHostName, start, err)
+	return pullRequests, response, err
 }
 
 func (wrapper *githubClientWrapper) ListReviews(owner, repo string, number int, opt *github.ListOptions) ([]*github.PullRequestReview, *github.Response, error) {
-	return wrapper.client.PullRequests.ListReviews(wrapper.ctx, owner, repo, number, opt)
+	start := time.Now()
+	reviews, response, err := wrapper.client.PullRequests.ListReviews(wrapper.ctx, owner, repo, number, opt)
+	observeAPICall(githubHostName, start, err)
+	return reviews, response, err
 }
 
 type githubHost struct {
 	config          *config.TeamConfig
+	store           state.Store
 	client          githubClient
 	repositoryNames []string
 }
 
-func newGithubHost(config *config.TeamConfig) *githubHost {
+func newGithubHost(config *config.TeamConfig, store state.Store) *githubHost {
 	githubConfig := config.Hosts.Github
 	ctx := context.Background()
 	ts := oauth2.StaticTokenSource(
@@ -45,6 +64,7 @@ func newGithubHost(config *config.TeamConfig) *githubHost {
 
 	return &githubHost{
 		config: config,
+		store:  store,
 		client: &githubClientWrapper{
 			client: github.NewClient(tc),
 			ctx:    ctx,
@@ -74,6 +94,15 @@ func (host *githubHost) getPullRequests(owner, repoSlug string, users map[string
 			UpdateTime:  *githubPullRequest.UpdatedAt,
 		}
 
+		if host.config.NeedsPullRequestSizes() {
+			// The size is not returned when listing pull requests
+			fullPullRequest, _, err := host.client.GetPullRequest(owner, repoSlug, *githubPullRequest.Number)
+			if err != nil {
+				return nil, fmt.Errorf("Error fetching the pull request with ID %v from %s/%s in Github: %v", *githubPullRequest.Number, owner, repoSlug, err)
+			}
+			pullRequest.Size = fullPullRequest.GetAdditions() + fullPullRequest.GetDeletions()
+		}
+
 		allGithubReviews := []*github.PullRequestReview{}
 		currentPage, lastPage := 1, 1
 		for currentPage <= lastPage {
@@ -87,19 +116,29 @@ func (host *githubHost) getPullRequests(owner, repoSlug string, users map[string
 		}
 
 		reviewerMap := map[string]*Reviewer{}
+		decidedReviewers := map[string]bool{}
 		for i := len(allGithubReviews) - 1; i >= 0; i-- {
 			review := allGithubReviews[i]
 			reviewUser := *review.User.Login
 			if reviewUser == pullRequest.Author.GithubUsername {
 				continue // Ignore reviews by author
 			}
-			if reviewer, ok := reviewerMap[reviewUser]; ok && (reviewer.Approved || reviewer.RequestedChanges) {
-				continue // Already handled
+			if _, ok := reviewerMap[reviewUser]; !ok {
+				reviewerMap[reviewUser] = &Reviewer{User: users[reviewUser]}
+			}
+			if decidedReviewers[reviewUser] {
+				continue // Already handled by a more recent review
 			}
-			reviewerMap[reviewUser] = &Reviewer{
-				User:             users[reviewUser],
-				Approved:         *review.State == "APPROVED",
-				RequestedChanges: *review.State == "CHANGES_REQUESTED",
+			// Comments don't change the reviewer's state. The latest approval, change request or dismissal does
+			switch *review.State {
+			case "APPROVED":
+				reviewerMap[reviewUser].Approved = true
+				decidedReviewers[reviewUser] = true
+			case "CHANGES_REQUESTED":
+				reviewerMap[reviewUser].RequestedChanges = true
+				decidedReviewers[reviewUser] = true
+			case "DISMISSED":
+				decidedReviewers[reviewUser] = true
 			}
 		}
 
@@ -117,8 +156,12 @@ func (host *githubHost) GetConfig() *config.TeamConfig {
 	return host.config
 }
 
+func (host *githubHost) GetStore() state.Store {
+	return host.store
+}
+
 func (host *githubHost) GetName() string {
-	return "Github"
+	return githubHostName
 }
 
 func (host *githubHost) GetUsers() (map[string]config.User, error) {
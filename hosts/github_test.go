@@ -24,6 +24,7 @@ func TestGetGithubRepositories(t *testing.T) {
 			},
 		},
 	}
+	host.config.Messaging.Sort = config.SortBySize
 
 	repositories, err := host.GetRepositories()
 
@@ -34,18 +35,21 @@ func TestGetGithubRepositories(t *testing.T) {
 	assert.Equal(t, host, repository.GetHost())
 
 	assert.True(t, repository.HasPullRequestsToDisplay())
-	pullRequestsToMerge, pullRequestsToReview := repository.GetPullRequestsToDisplay()
+	pullRequestsToMerge, pullRequestsToReview, pullRequestsWithChangesRequested := repository.GetPullRequestsToDisplay()
 	assert.Len(t, pullRequestsToMerge, 1)
 	assert.Len(t, pullRequestsToReview, 0)
+	assert.Len(t, pullRequestsWithChangesRequested, 0)
 
 	pullRequest := pullRequestsToMerge[0]
 	assert.True(t, pullRequest.IsApproved(host.config.GetGithubUsers(), 1))
 	assert.False(t, pullRequest.IsApproved(host.config.GetGithubUsers(), 2)) // only one approval
 	assert.False(t, pullRequest.IsWIP())
 	assert.True(t, pullRequest.IsFromOneOfUsers(host.config.GetGithubUsers()))
-	assert.Len(t, pullRequest.TeamReviewers(host.config.GetGithubUsers()), 2) // jdoe2 and jdoe3
+	assert.Len(t, pullRequest.TeamReviewers(host.config.GetGithubUsers()), 2)      // jdoe2 and jdoe3
+	assert.False(t, pullRequest.HasRequestedChanges(host.config.GetGithubUsers())) // jdoe3's change requests were dismissed
 	assert.Equal(t, "jdoe1", pullRequest.Author.GithubUsername)
 	assert.Equal(t, "Auto update", pullRequest.Title)
+	assert.Equal(t, 15, pullRequest.Size)
 	assert.Equal(t, "https://github.com/coveooss/tgf/pull/79", pullRequest.Link) // directly from the response
 }
 
@@ -62,6 +66,11 @@ func TestGetGithubRepositoriesErrors(t *testing.T) {
 			client:      &mockGithubClient{errorOnListPullRequests: true},
 			expectError: "Caught an error while describing pull requests: Error fetching pull requests from jdoe/test in Github: list PR error",
 		},
+		{
+			name:        "get PR error",
+			client:      &mockGithubClient{errorOnGetPullRequest: true},
+			expectError: "Caught an error while describing pull requests: Error fetching the pull request with ID 79 from jdoe/test in Github: get PR error",
+		},
 		{
 			name:        "list reviews error",
 			client:      &mockGithubClient{errorOnListReviews: true},
@@ -78,6 +87,7 @@ func TestGetGithubRepositoriesErrors(t *testing.T) {
 					Users: []config.User{},
 				},
 			}
+			host.config.Messaging.Sort = config.SortBySize
 			_, err := host.GetRepositories()
 			assert.EqualError(t, err, tt.expectError)
 		})
@@ -85,10 +95,18 @@ func TestGetGithubRepositoriesErrors(t *testing.T) {
 }
 
 type mockGithubClient struct {
+	errorOnGetPullRequest   bool
 	errorOnListPullRequests bool
 	errorOnListReviews      bool
 }
 
+func (client *mockGithubClient) GetPullRequest(owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	if client.errorOnGetPullRequest {
+		return nil, nil, fmt.Errorf("get PR error")
+	}
+	return &github.PullRequest{Number: github.Int(number), Additions: github.Int(10), Deletions: github.Int(5)}, nil, nil
+}
+
 func (client *mockGithubClient) ListPullRequests(owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
 	if client.errorOnListPullRequests {
 		return nil, nil, fmt.Errorf("list PR error")
@@ -7,14 +7,24 @@ import (
 	"time"
 
 	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	log "github.com/sirupsen/logrus"
 )
 
+// Categories of the pull requests needing action
+const (
+	ReadyToMergeCategory     = "ready_to_merge"
+	ReadyToReviewCategory    = "ready_to_review"
+	ChangesRequestedCategory = "changes_requested"
+)
+
 // Reviewer represents a user that approves, requests changes or has not reviewed yet
 type Reviewer struct {
 	Approved         bool
 	RequestedChanges bool
 	User             config.User
+	// Absent is true if the reviewer is out of office. Absent reviewers who have not reviewed yet are not waited on
+	Absent bool
 }
 
 // PullRequest represent a pull (or merge) request on a SCM provider
@@ -26,6 +36,13 @@ type PullRequest struct {
 	Title       string
 	CreateTime  time.Time
 	UpdateTime  time.Time
+	// Size is the number of changed lines (additions and deletions). It is 0 when it is unknown
+	Size int
+
+	// State contains what users chose to do with the pull request from messages. It is set when the repository is created
+	State *state.PullRequestState
+	// Verdict explains why the pull request is displayed in its category or ignored. It is set when the repository is created
+	Verdict *Verdict
 }
 
 // IsApproved returns true if the pull request is approved and ready to merge
@@ -39,6 +56,16 @@ func (pr *PullRequest) IsApproved(team map[string]config.User, numberOfApprovals
 	return approvalsGotten >= numberOfApprovals
 }
 
+// HasRequestedChanges returns true if one of the team's reviewers requested changes. The pull request is then waiting on its author
+func (pr *PullRequest) HasRequestedChanges(team map[string]config.User) bool {
+	for _, reviewer := range pr.TeamReviewers(team) {
+		if reviewer.RequestedChanges {
+			return true
+		}
+	}
+	return false
+}
+
 // IsFromOneOfUsers returns true if the pull request was submitted by one of the given users
 func (pr *PullRequest) IsFromOneOfUsers(team map[string]config.User) bool {
 	for _, teamMember := range team {
@@ -60,8 +87,45 @@ func (pr *PullRequest) IsWIP() bool {
 	return false
 }
 
-// TeamReviewers returns all the reviewers that are in the given list of usernames (the team)
+// TeamReviewers returns all the reviewers that are in the given list of usernames (the team).
+// Absent reviewers are only returned if they already approved or requested changes
 func (pr *PullRequest) TeamReviewers(team map[string]config.User) []*Reviewer {
+	reviewers := []*Reviewer{}
+	for _, reviewer := range pr.allTeamReviewers(team) {
+		if !reviewer.Absent || reviewer.Approved || reviewer.RequestedChanges {
+			reviewers = append(reviewers, reviewer)
+		}
+	}
+	return reviewers
+}
+
+// AbsentTeamReviewers returns the team's reviewers who are out of office and have not reviewed the pull request yet
+func (pr *PullRequest) AbsentTeamReviewers(team map[string]config.User) []*Reviewer {
+	reviewers := []*Reviewer{}
+	for _, reviewer := range pr.allTeamReviewers(team) {
+		if reviewer.Absent && !reviewer.Approved && !reviewer.RequestedChanges {
+			reviewers = append(reviewers, reviewer)
+		}
+	}
+	return reviewers
+}
+
+// NeedsReassignment returns true if the pull request is only waiting on absent reviewers
+func (pr *PullRequest) NeedsReassignment() bool {
+	hasAbsentReviewers := false
+	for _, reviewer := range pr.Reviewers {
+		if reviewer.User.Name == "" || reviewer.Approved || reviewer.RequestedChanges {
+			continue // Not a team member or already reviewed
+		}
+		if !reviewer.Absent {
+			return false
+		}
+		hasAbsentReviewers = true
+	}
+	return hasAbsentReviewers
+}
+
+func (pr *PullRequest) allTeamReviewers(team map[string]config.User) []*Reviewer {
 	reviewers := []*Reviewer{}
 	for _, reviewer := range pr.Reviewers {
 		for _, teamMember := range team {
@@ -78,7 +142,8 @@ type Repository interface {
 	GetHost() Host
 	GetLink() string
 	GetName() string
-	GetPullRequestsToDisplay() (readyToMerge []*PullRequest, readyToReview []*PullRequest)
+	GetPullRequestsToDisplay() (readyToMerge []*PullRequest, readyToReview []*PullRequest, changesRequested []*PullRequest)
+	GetIgnoredPullRequests() []*PullRequest
 	HasPullRequestsToDisplay() bool
 }
 
@@ -89,9 +154,12 @@ type RepositoryImpl struct {
 	Name string
 
 	OpenPullRequests []*PullRequest
+
+	// The open pull requests by category, computed once when the repository is created
+	readyToMerge, readyToReview, changesRequested, ignored []*PullRequest
 }
 
-// NewRepository creates a RepositoryImpl instance
+// NewRepository creates a RepositoryImpl instance and gives a verdict on each of its open pull requests
 func NewRepository(host Host, name, link string, openPullRequests []*PullRequest) *RepositoryImpl {
 	repository := &RepositoryImpl{
 		Link:             link,
@@ -99,6 +167,7 @@ func NewRepository(host Host, name, link string, openPullRequests []*PullRequest
 		Host:             host,
 		OpenPullRequests: openPullRequests,
 	}
+	repository.readyToMerge, repository.readyToReview, repository.changesRequested, repository.ignored = repository.categorizePullRequests()
 	return repository
 }
 
@@ -117,56 +186,145 @@ func (repository *RepositoryImpl) GetName() string {
 	return repository.Name
 }
 
-// GetPullRequestsToDisplay returns all pull requests that are either waiting for approvals or ready to merge
-func (repository *RepositoryImpl) GetPullRequestsToDisplay() (readyToMerge []*PullRequest, readyToReview []*PullRequest) {
+// GetPullRequestsToDisplay returns all pull requests that are either waiting for approvals, ready to merge or waiting on their author (changes requested)
+func (repository *RepositoryImpl) GetPullRequestsToDisplay() (readyToMerge []*PullRequest, readyToReview []*PullRequest, changesRequested []*PullRequest) {
+	return repository.readyToMerge, repository.readyToReview, repository.changesRequested
+}
+
+// GetIgnoredPullRequests returns the open pull requests that don't need action from the team. Their verdict explains why they are ignored
+func (repository *RepositoryImpl) GetIgnoredPullRequests() []*PullRequest {
+	return repository.ignored
+}
+
+func (repository *RepositoryImpl) categorizePullRequests() (readyToMerge, readyToReview, changesRequested, ignored []*PullRequest) {
 	config := repository.GetHost().GetConfig()
+	store := repository.GetHost().GetStore()
 	hostUsers, _ := repository.GetHost().GetUsers()
 
-	readyToMerge, readyToReview = []*PullRequest{}, []*PullRequest{}
+	now := time.Now()
+	readyToMerge, readyToReview, changesRequested, ignored = []*PullRequest{}, []*PullRequest{}, []*PullRequest{}, []*PullRequest{}
 	for _, pullRequest := range repository.OpenPullRequests {
 
-		var logIgnoredPullRequest = func(message string) {
-			log.Infof("%s: %s (%s) ignored because %s", repository.Name, pullRequest.Title, pullRequest.Link, message)
+		var ignorePullRequest = func(verdict *Verdict) {
+			log.Infof("%s: %s (%s) ignored because %s", repository.Name, pullRequest.Title, pullRequest.Link, verdict.Explanation)
+			pullRequest.Verdict = verdict
+			ignored = append(ignored, pullRequest)
+		}
+
+		pullRequest.State = &state.PullRequestState{}
+		if store != nil {
+			pullRequestState, err := state.GetPullRequestState(store, pullRequest.Link)
+			if err != nil {
+				log.WithError(err).Warningf("%s: Unable to read the state of %s (%s)", repository.Name, pullRequest.Title, pullRequest.Link)
+			} else {
+				pullRequest.State = pullRequestState
+			}
 		}
 
+		if pullRequest.State.IsSnoozed(now) {
+			ignorePullRequest(newSnoozedVerdict(pullRequest.State.SnoozedUntil))
+			continue
+		}
 		if pullRequest.IsWIP() {
-			logIgnoredPullRequest("Marked WIP")
+			ignorePullRequest(newIgnoredVerdict(WIPReason))
 			continue
 		}
-		if len(pullRequest.TeamReviewers(hostUsers)) == 0 {
-			logIgnoredPullRequest("No reviewers")
+		for _, reviewer := range pullRequest.Reviewers {
+			if reviewer.User.Name != "" && config.IsUserAbsent(reviewer.User, now) {
+				reviewer.Absent = true
+			}
+		}
+		if len(pullRequest.allTeamReviewers(hostUsers)) == 0 {
+			ignorePullRequest(newIgnoredVerdict(NoReviewersReason))
 			continue
 		}
-		if pullRequest.CreateTime.After(time.Now().Add(-config.AgeBeforeNotifying)) {
-			logIgnoredPullRequest(fmt.Sprintf("Not old enough. It hasn't been created for %v", config.AgeBeforeNotifying))
+		if config.GetAge(pullRequest.CreateTime, now) < config.AgeBeforeNotifying {
+			ignorePullRequest(newTooRecentVerdict(config.AgeBeforeNotifying))
 			continue
 		}
 
-		if pullRequest.IsApproved(hostUsers, config.GetNumberOfNeededApprovals()) {
+		if pullRequest.HasRequestedChanges(hostUsers) {
+			if !config.ReviewPRsFromNonMembers && !pullRequest.IsFromOneOfUsers(hostUsers) {
+				ignorePullRequest(newIgnoredVerdict(NotFromTeamReason))
+				continue
+			}
+			pullRequest.Verdict = newVerdict(ChangesRequestedCategory, ChangesRequestedReason)
+			changesRequested = append(changesRequested, pullRequest)
+		} else if pullRequest.IsApproved(hostUsers, config.GetNumberOfNeededApprovals()) {
 			if !pullRequest.IsFromOneOfUsers(hostUsers) {
-				logIgnoredPullRequest("Not from one of the team's users")
+				ignorePullRequest(newIgnoredVerdict(NotFromTeamReason))
 				continue
 			}
-			if pullRequest.UpdateTime.After(time.Now().Add(-config.AgeBeforeNotifying)) {
-				logIgnoredPullRequest(fmt.Sprintf("Merge not overdue, hasn't been stale for %v", config.AgeBeforeNotifying))
+			if config.GetAge(pullRequest.UpdateTime, now) < config.AgeBeforeNotifying {
+				ignorePullRequest(newMergeNotOverdueVerdict(config.AgeBeforeNotifying))
 				continue
 			}
+			pullRequest.Verdict = newVerdict(ReadyToMergeCategory, ApprovedReason)
 			readyToMerge = append(readyToMerge, pullRequest)
 		} else {
 			if !config.ReviewPRsFromNonMembers && !pullRequest.IsFromOneOfUsers(hostUsers) {
-				logIgnoredPullRequest("Not from one of the team's users")
+				ignorePullRequest(newIgnoredVerdict(NotFromTeamReason))
 				continue
 			}
+			pullRequest.Verdict = newVerdict(ReadyToReviewCategory, NeedsApprovalsReason)
 			readyToReview = append(readyToReview, pullRequest)
 		}
 	}
 	return
 }
 
-// HasPullRequestsToDisplay returns true if at least one of the pull requests needs action by the team (ready to merge or needs approval)
+// RecordFirstSeen records, in the state, when the pull requests needing action in the given repositories were first found needing action
+func RecordFirstSeen(store state.Store, repositories []Repository, now time.Time) error {
+	for _, repository := range repositories {
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		for _, pullRequests := range [][]*PullRequest{readyToMerge, readyToReview, changesRequested} {
+			for _, pullRequest := range pullRequests {
+				if pullRequest.State != nil && !pullRequest.State.FirstSeen.IsZero() {
+					continue
+				}
+				pullRequestState, err := state.UpdatePullRequestState(store, pullRequest.Link, func(pullRequestState *state.PullRequestState) {
+					pullRequestState.FirstSeen = now
+				})
+				if err != nil {
+					return fmt.Errorf("Unable to record when %s (%s) was first seen: %v", pullRequest.Title, pullRequest.Link, err)
+				}
+				pullRequest.State = pullRequestState
+			}
+		}
+	}
+	return nil
+}
+
+// GetOpenPullRequestLinks returns the links of all open pull requests of the given repositories, including the ignored ones
+func GetOpenPullRequestLinks(repositories []Repository) map[string]bool {
+	links := map[string]bool{}
+	for _, repository := range repositories {
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		for _, pullRequests := range [][]*PullRequest{readyToMerge, readyToReview, changesRequested, repository.GetIgnoredPullRequests()} {
+			for _, pullRequest := range pullRequests {
+				links[pullRequest.Link] = true
+			}
+		}
+	}
+	return links
+}
+
+// PruneClosedPullRequests removes, from the state, the pull requests of the given repositories that are not open anymore
+func PruneClosedPullRequests(store state.Store, repositories []Repository) error {
+	repositoryLinks := []string{}
+	for _, repository := range repositories {
+		repositoryLinks = append(repositoryLinks, repository.GetLink())
+	}
+	if err := state.PrunePullRequestStates(store, repositoryLinks, GetOpenPullRequestLinks(repositories)); err != nil {
+		return fmt.Errorf("Unable to remove the closed pull requests from the state: %v", err)
+	}
+	return nil
+}
+
+// HasPullRequestsToDisplay returns true if at least one of the pull requests needs action by the team (ready to merge, needs approval or changes requested)
 func (repository *RepositoryImpl) HasPullRequestsToDisplay() bool {
-	readyToMerge, readyToReview := repository.GetPullRequestsToDisplay()
-	return len(readyToMerge)+len(readyToReview) > 0
+	readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+	return len(readyToMerge)+len(readyToReview)+len(changesRequested) > 0
 }
 
 // Host represents a SCM provider
@@ -174,19 +332,31 @@ type Host interface {
 	GetConfig() *config.TeamConfig
 	GetName() string
 	GetRepositories() ([]Repository, error)
+	GetStore() state.Store
 	GetUsers() (map[string]config.User, error)
 }
 
+// GetRepositoriesNeedingAction returns the repositories with pull requests to display
+func GetRepositoriesNeedingAction(repositories []Repository) []Repository {
+	repositoriesNeedingAction := []Repository{}
+	for _, repository := range repositories {
+		if repository.HasPullRequestsToDisplay() {
+			repositoriesNeedingAction = append(repositoriesNeedingAction, repository)
+		}
+	}
+	return repositoriesNeedingAction
+}
+
 // GetHosts returns all configured Hosts (SCM providers)
-func GetHosts(config *config.TeamConfig) []Host {
+func GetHosts(config *config.TeamConfig, store state.Store) []Host {
 	hosts := []Host{}
 	if config.IsBitbucketConfigured() {
-		hosts = append(hosts, newBitbucketCloud(config))
+		hosts = append(hosts, newBitbucketCloud(config, store))
 	} else {
 		log.Infoln("Bitbucket is not configured")
 	}
 	if config.IsGithubConfigured() {
-		hosts = append(hosts, newGithubHost(config))
+		hosts = append(hosts, newGithubHost(config, store))
 	} else {
 		log.Infoln("Github is not configured")
 	}
@@ -7,6 +7,7 @@ package hosts
 import (
 	gomock "github.com/golang/mock/gomock"
 	config "github.com/julienduchesne/pull-request-reminder/config"
+	state "github.com/julienduchesne/pull-request-reminder/state"
 	reflect "reflect"
 )
 
@@ -76,12 +77,13 @@ func (mr *MockRepositoryMockRecorder) GetName() *gomock.Call {
 }
 
 // GetPullRequestsToDisplay mocks base method
-func (m *MockRepository) GetPullRequestsToDisplay() ([]*PullRequest, []*PullRequest) {
+func (m *MockRepository) GetPullRequestsToDisplay() ([]*PullRequest, []*PullRequest, []*PullRequest) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetPullRequestsToDisplay")
 	ret0, _ := ret[0].([]*PullRequest)
 	ret1, _ := ret[1].([]*PullRequest)
-	return ret0, ret1
+	ret2, _ := ret[2].([]*PullRequest)
+	return ret0, ret1, ret2
 }
 
 // GetPullRequestsToDisplay indicates an expected call of GetPullRequestsToDisplay
@@ -90,6 +92,20 @@ func (mr *MockRepositoryMockRecorder) GetPullRequestsToDisplay() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequestsToDisplay", reflect.TypeOf((*MockRepository)(nil).GetPullRequestsToDisplay))
 }
 
+// GetIgnoredPullRequests mocks base method
+func (m *MockRepository) GetIgnoredPullRequests() []*PullRequest {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIgnoredPullRequests")
+	ret0, _ := ret[0].([]*PullRequest)
+	return ret0
+}
+
+// GetIgnoredPullRequests indicates an expected call of GetIgnoredPullRequests
+func (mr *MockRepositoryMockRecorder) GetIgnoredPullRequests() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIgnoredPullRequests", reflect.TypeOf((*MockRepository)(nil).GetIgnoredPullRequests))
+}
+
 // HasPullRequestsToDisplay mocks base method
 func (m *MockRepository) HasPullRequestsToDisplay() bool {
 	m.ctrl.T.Helper()
@@ -170,6 +186,20 @@ func (mr *MockHostMockRecorder) GetRepositories() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepositories", reflect.TypeOf((*MockHost)(nil).GetRepositories))
 }
 
+// GetStore mocks base method
+func (m *MockHost) GetStore() state.Store {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStore")
+	ret0, _ := ret[0].(state.Store)
+	return ret0
+}
+
+// GetStore indicates an expected call of GetStore
+func (mr *MockHostMockRecorder) GetStore() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStore", reflect.TypeOf((*MockHost)(nil).GetStore))
+}
+
 // GetUsers mocks base method
 func (m *MockHost) GetUsers() (map[string]config.User, error) {
 	m.ctrl.T.Helper()
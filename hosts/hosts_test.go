@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,8 +22,14 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 		pullRequest             *PullRequest
 		readyToMerge            bool
 		readyToReview           bool
+		changesRequested        bool
 		numberOfNeededApprovals int
 		reviewPRsFromNonMembers bool
+		ageInBusinessHours      bool
+		needsReassignment       bool
+		holidays                []config.DateRange
+		pullRequestState        *state.PullRequestState
+		ignoredReason           string
 	}{
 		{
 			name: "Not Approved PR",
@@ -54,7 +61,8 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 			readyToReview: false,
 		},
 		{
-			name: "Author not from team",
+			name:          "Author not from team",
+			ignoredReason: NotFromTeamReason,
 			pullRequest: &PullRequest{Title: "User not from team", Author: config.User{Name: "otheruser"}, Reviewers: []*Reviewer{
 				{Approved: false, User: config.User{Name: "user1"}},
 				{Approved: false, User: config.User{Name: "user2"}},
@@ -73,7 +81,8 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 			reviewPRsFromNonMembers: true,
 		},
 		{
-			name: "Approved PR not from team",
+			name:          "Approved PR not from team",
+			ignoredReason: NotFromTeamReason,
 			pullRequest: &PullRequest{Title: "Approved", Author: config.User{Name: "otheruser"}, Reviewers: []*Reviewer{
 				{Approved: true, User: config.User{Name: "user1"}},
 				{Approved: false, User: config.User{Name: "user2"}},
@@ -82,7 +91,8 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 			readyToReview: false,
 		},
 		{
-			name: "Work in progress",
+			name:          "Work in progress",
+			ignoredReason: WIPReason,
 			pullRequest: &PullRequest{Title: "[WIP] My Title", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
 				{Approved: false, User: config.User{Name: "user1"}},
 				{Approved: false, User: config.User{Name: "user2"}},
@@ -92,12 +102,14 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 		},
 		{
 			name:          "No Reviewers",
+			ignoredReason: NoReviewersReason,
 			pullRequest:   &PullRequest{Title: "No Reviewers", Author: config.User{Name: "user1"}},
 			readyToMerge:  false,
 			readyToReview: false,
 		},
 		{
-			name: "Not created long enough ago",
+			name:          "Not created long enough ago",
+			ignoredReason: TooRecentReason,
 			pullRequest: &PullRequest{Title: "Not approved but too young", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
 				{Approved: false, User: config.User{Name: "user1"}},
 				{Approved: false, User: config.User{Name: "user2"}},
@@ -108,7 +120,8 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 			readyToReview: false,
 		},
 		{
-			name: "Not approved long enough ago (have to wait 24h after update before annoying with merge notification)",
+			name:          "Not approved long enough ago (have to wait 24h after update before annoying with merge notification)",
+			ignoredReason: MergeNotOverdueReason,
 			pullRequest: &PullRequest{Title: "Approved", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
 				{Approved: true, User: config.User{Name: "user1"}},
 				{Approved: false, User: config.User{Name: "user2"}},
@@ -128,30 +141,182 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 			readyToReview:           true,
 			numberOfNeededApprovals: 2,
 		},
+		{
+			name: "Changes requested",
+			pullRequest: &PullRequest{Title: "Changes requested", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{RequestedChanges: true, User: config.User{Name: "user2"}},
+			}},
+			readyToMerge:     false,
+			readyToReview:    false,
+			changesRequested: true,
+		},
+		{
+			name: "Changes requested on an approved PR",
+			pullRequest: &PullRequest{Title: "Changes requested", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: true, User: config.User{Name: "user1"}},
+				{RequestedChanges: true, User: config.User{Name: "user2"}},
+			}},
+			readyToMerge:     false,
+			readyToReview:    false,
+			changesRequested: true,
+		},
+		{
+			name: "Changes requested by other user (not in team)",
+			pullRequest: &PullRequest{Title: "Changes requested by otheruser", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{RequestedChanges: true, User: config.User{Name: "otheruser"}},
+				{Approved: false, User: config.User{Name: "user2"}},
+			}},
+			readyToMerge:     false,
+			readyToReview:    true,
+			changesRequested: false,
+		},
+		{
+			name:          "Changes requested on a PR not from team",
+			ignoredReason: NotFromTeamReason,
+			pullRequest: &PullRequest{Title: "Changes requested", Author: config.User{Name: "otheruser"}, Reviewers: []*Reviewer{
+				{RequestedChanges: true, User: config.User{Name: "user2"}},
+			}},
+			readyToMerge:     false,
+			readyToReview:    false,
+			changesRequested: false,
+		},
+		{
+			name:          "Not old enough in business hours",
+			ignoredReason: TooRecentReason,
+			pullRequest: &PullRequest{Title: "Opened during the weekend", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: false, User: config.User{Name: "user2"}},
+			},
+				CreateTime: time.Now().Add(-validAge),
+			},
+			ageInBusinessHours: true,
+			readyToMerge:       false,
+			readyToReview:      false,
+		},
+		{
+			name:          "Not old enough because of holidays",
+			ignoredReason: TooRecentReason,
+			pullRequest: &PullRequest{Title: "Opened before the holidays", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: false, User: config.User{Name: "user2"}},
+			},
+				CreateTime: time.Now().Add(-validAge),
+			},
+			holidays:      []config.DateRange{{From: time.Now().AddDate(0, 0, -2).Format("2006-01-02"), To: time.Now().Format("2006-01-02")}},
+			readyToMerge:  false,
+			readyToReview: false,
+		},
+		{
+			name: "Waiting on an absent reviewer",
+			pullRequest: &PullRequest{Title: "Absent reviewer", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: false, User: config.User{Name: "user3"}},
+			}},
+			readyToReview:     true,
+			needsReassignment: true,
+		},
+		{
+			name: "Waiting on absent and present reviewers",
+			pullRequest: &PullRequest{Title: "Absent and present reviewers", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: false, User: config.User{Name: "user2"}},
+				{Approved: false, User: config.User{Name: "user3"}},
+			}},
+			readyToReview: true,
+		},
+		{
+			name: "Approved by an absent reviewer",
+			pullRequest: &PullRequest{Title: "Approved by an absent reviewer", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: true, User: config.User{Name: "user3"}},
+			}},
+			readyToMerge: true,
+		},
+		{
+			name:          "Snoozed",
+			ignoredReason: SnoozedReason,
+			pullRequest: &PullRequest{Title: "Snoozed", Link: "snoozed.com", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: false, User: config.User{Name: "user2"}},
+			}},
+			pullRequestState: &state.PullRequestState{SnoozedUntil: time.Now().Add(time.Hour)},
+			readyToMerge:     false,
+			readyToReview:    false,
+		},
+		{
+			name: "Snooze expired",
+			pullRequest: &PullRequest{Title: "Snooze expired", Link: "snoozed.com", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+				{Approved: false, User: config.User{Name: "user2"}},
+			}},
+			pullRequestState: &state.PullRequestState{SnoozedUntil: time.Now().Add(-time.Hour), ClaimedBy: "user2"},
+			readyToMerge:     false,
+			readyToReview:    true,
+		},
 	}
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			repository := NewRepository(&bitbucketCloud{
-				config: &config.TeamConfig{
-					AgeBeforeNotifying:      maxAge,
-					ReviewPRsFromNonMembers: tt.reviewPRsFromNonMembers,
-					NumberOfApprovals:       tt.numberOfNeededApprovals,
-					Users: []config.User{
-						{Name: "user1", BitbucketUUID: "user1"},
-						{Name: "user2", BitbucketUUID: "user2"},
-					},
+			store := state.NewMemoryStore()
+			if tt.pullRequestState != nil {
+				state.SetPullRequestState(store, tt.pullRequest.Link, tt.pullRequestState)
+			}
+			teamConfig := &config.TeamConfig{
+				AgeBeforeNotifying:      maxAge,
+				ReviewPRsFromNonMembers: tt.reviewPRsFromNonMembers,
+				NumberOfApprovals:       tt.numberOfNeededApprovals,
+				AgeInBusinessHours:      tt.ageInBusinessHours,
+				Holidays:                tt.holidays,
+				Users: []config.User{
+					{Name: "user1", BitbucketUUID: "user1"},
+					{Name: "user2", BitbucketUUID: "user2"},
+					{Name: "user3", BitbucketUUID: "user3", OutOfOffice: []config.DateRange{{
+						From: time.Now().AddDate(0, 0, -1).Format("2006-01-02"),
+						To:   time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+					}}},
 				},
+			}
+			if tt.ageInBusinessHours {
+				teamConfig.QuietHours = "00:01-00:00" // Only 1 minute per day
+			}
+			repository := NewRepository(&bitbucketCloud{
+				store:  store,
+				config: teamConfig,
 			},
 				"repo-name", "http://example.com",
 				[]*PullRequest{tt.pullRequest})
-			if tt.readyToMerge || tt.readyToReview {
+			if tt.readyToMerge || tt.readyToReview || tt.changesRequested {
 				assert.True(t, repository.HasPullRequestsToDisplay())
 			}
 
-			readyToMerge, readyToReview := repository.GetPullRequestsToDisplay()
+			readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
 			assert.Equal(t, tt.readyToMerge, len(readyToMerge) == 1, "The pull request should or should not have been ready to merge")
 			assert.Equal(t, tt.readyToReview, len(readyToReview) == 1, "The pull request should or should not have been ready to review")
+			assert.Equal(t, tt.changesRequested, len(changesRequested) == 1, "The pull request should or should not have been waiting on its author")
+			assert.Equal(t, tt.needsReassignment, tt.pullRequest.NeedsReassignment())
+			ignored := repository.GetIgnoredPullRequests()
+			if tt.readyToMerge || tt.readyToReview || tt.changesRequested {
+				assert.Empty(t, ignored)
+			} else if assert.Len(t, ignored, 1) {
+				assert.Equal(t, tt.pullRequest, ignored[0])
+				assert.True(t, ignored[0].Verdict.IsIgnored())
+				assert.Equal(t, tt.ignoredReason, ignored[0].Verdict.Reason)
+				assert.NotEmpty(t, ignored[0].Verdict.Explanation)
+			}
+			for category, pullRequests := range map[string][]*PullRequest{
+				ReadyToMergeCategory:     readyToMerge,
+				ReadyToReviewCategory:    readyToReview,
+				ChangesRequestedCategory: changesRequested,
+			} {
+				for _, pullRequest := range pullRequests {
+					assert.Equal(t, category, pullRequest.Verdict.Category)
+					assert.NotEmpty(t, pullRequest.Verdict.Explanation)
+				}
+			}
+			// Categorizing the pull requests doesn't write to the state, the run records when they were first seen
+			pullRequestState, _ := state.GetPullRequestState(store, tt.pullRequest.Link)
+			assert.True(t, pullRequestState.FirstSeen.IsZero())
+			assert.Nil(t, RecordFirstSeen(store, []Repository{repository}, time.Now()))
+			if tt.readyToMerge || tt.readyToReview || tt.changesRequested {
+				pullRequestState, _ := state.GetPullRequestState(store, tt.pullRequest.Link)
+				assert.False(t, pullRequestState.FirstSeen.IsZero(), "The first time the pull request was seen should have been recorded")
+			}
+			if tt.pullRequestState != nil {
+				assert.Equal(t, tt.pullRequestState.ClaimedBy, tt.pullRequest.State.ClaimedBy)
+			}
 
 			assert.Equal(t, "repo-name", repository.GetName())
 			assert.Equal(t, "http://example.com", repository.GetLink())
@@ -159,6 +324,24 @@ func TestGetPullRequestsToDisplay(t *testing.T) {
 	}
 }
 
+func TestPullRequestsAreCategorizedOnce(t *testing.T) {
+	t.Parallel()
+
+	store := state.NewMemoryStore()
+	pullRequest := &PullRequest{Title: "Not approved", Link: "http://example.com/1", Author: config.User{Name: "user1"}, Reviewers: []*Reviewer{
+		{User: config.User{Name: "user2"}},
+	}}
+	teamConfig := &config.TeamConfig{Users: []config.User{{Name: "user1", BitbucketUUID: "user1"}, {Name: "user2", BitbucketUUID: "user2"}}}
+	repository := NewRepository(&bitbucketCloud{store: store, config: teamConfig}, "repo-name", "http://example.com", []*PullRequest{pullRequest})
+
+	// Snoozing the pull request during the run doesn't change the verdicts given when the repository was created
+	state.SetPullRequestState(store, pullRequest.Link, &state.PullRequestState{SnoozedUntil: time.Now().Add(time.Hour)})
+	_, readyToReview, _ := repository.GetPullRequestsToDisplay()
+	assert.Equal(t, []*PullRequest{pullRequest}, readyToReview)
+	assert.Empty(t, repository.GetIgnoredPullRequests())
+	assert.False(t, pullRequest.State.IsSnoozed(time.Now()))
+}
+
 func TestGetHosts(t *testing.T) {
 	t.Parallel()
 
@@ -198,7 +381,7 @@ func TestGetHosts(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			gottenHosts := GetHosts(tt.config)
+			gottenHosts := GetHosts(tt.config, nil)
 			for _, hostType := range tt.expectedHosts {
 				hasType := false
 				for _, host := range gottenHosts {
@@ -216,10 +399,12 @@ func TestGetHosts(t *testing.T) {
 func TestGetHostName(t *testing.T) {
 	t.Parallel()
 
-	hosts := GetHosts(getTeamConfig(true, true))
+	store := state.NewMemoryStore()
+	hosts := GetHosts(getTeamConfig(true, true), store)
 	names := []string{}
 	for _, host := range hosts {
 		names = append(names, host.GetName())
+		assert.Equal(t, store, host.GetStore())
 	}
 	assert.Equal(t, []string{"Bitbucket", "Github"}, names)
 }
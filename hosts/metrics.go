@@ -0,0 +1,101 @@
+package hosts
+
+import (
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	apiCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "prr_host_api_calls_total",
+		Help: "Number of calls to the hosts' APIs",
+	}, []string{"host"})
+	apiErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "prr_host_api_errors_total",
+		Help: "Number of failed calls to the hosts' APIs",
+	}, []string{"host"})
+	apiCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "prr_host_api_call_duration_seconds",
+		Help:    "Duration of the calls to the hosts' APIs",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"host"})
+
+	openPullRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "prr_pull_requests",
+		Help: "Number of pull requests needing action, per repository and category",
+	}, []string{"team", "repository", "category"})
+	pullRequestAge = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "prr_pull_request_age_seconds",
+		Help:    "Age of the pull requests needing action, since their creation",
+		Buckets: []float64{3600, 4 * 3600, 24 * 3600, 2 * 24 * 3600, 7 * 24 * 3600, 14 * 24 * 3600, 30 * 24 * 3600},
+	}, []string{"team", "category"})
+	pendingReviews = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "prr_pending_reviews",
+		Help: "Number of pull requests in need of approvers that each team reviewer has not reviewed yet",
+	}, []string{"team", "reviewer"})
+
+	// teamSeries are the series of the pull request metrics recorded for each team, so that they can be deleted when
+	// the team's metrics are replaced. Teams are handled one at a time, the map is not accessed concurrently
+	teamSeries = map[string][]recordedSeries{}
+)
+
+// recordedSeries is a series of one of the pull request metrics
+type recordedSeries struct {
+	vec    interface{ Delete(prometheus.Labels) bool }
+	labels prometheus.Labels
+}
+
+// observeAPICall records a call to a host's API that started at the given time
+func observeAPICall(host string, start time.Time, err error) {
+	apiCalls.WithLabelValues(host).Inc()
+	apiCallDuration.WithLabelValues(host).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiErrors.WithLabelValues(host).Inc()
+	}
+}
+
+// RecordPullRequestMetrics replaces the team's pull request metrics with the pull requests needing action of the given repositories
+func RecordPullRequestMetrics(team *config.TeamConfig, repositories []Repository, now time.Time) {
+	for _, series := range teamSeries[team.Name] {
+		series.vec.Delete(series.labels)
+	}
+	recorded := []recordedSeries{}
+	record := func(vec interface{ Delete(prometheus.Labels) bool }, labels prometheus.Labels) prometheus.Labels {
+		recorded = append(recorded, recordedSeries{vec: vec, labels: labels})
+		return labels
+	}
+
+	teamUsers := map[string]config.User{}
+	for _, user := range team.Users {
+		teamUsers[user.Name] = user
+	}
+	for _, repository := range repositories {
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		for category, pullRequests := range map[string][]*PullRequest{
+			ReadyToMergeCategory:     readyToMerge,
+			ReadyToReviewCategory:    readyToReview,
+			ChangesRequestedCategory: changesRequested,
+		} {
+			labels := prometheus.Labels{"team": team.Name, "repository": repository.GetName(), "category": category}
+			openPullRequests.With(record(openPullRequests, labels)).Add(float64(len(pullRequests)))
+			for _, pullRequest := range pullRequests {
+				if !pullRequest.CreateTime.IsZero() {
+					labels := prometheus.Labels{"team": team.Name, "category": category}
+					pullRequestAge.With(record(pullRequestAge, labels)).Observe(now.Sub(pullRequest.CreateTime).Seconds())
+				}
+			}
+		}
+		for _, pullRequest := range readyToReview {
+			for _, reviewer := range pullRequest.TeamReviewers(teamUsers) {
+				if !reviewer.Approved && !reviewer.RequestedChanges {
+					labels := prometheus.Labels{"team": team.Name, "reviewer": reviewer.User.Name}
+					pendingReviews.With(record(pendingReviews, labels)).Inc()
+				}
+			}
+		}
+	}
+	teamSeries[team.Name] = recorded
+}
@@ -0,0 +1,71 @@
+package hosts
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrapeMetrics returns the metrics exposed on /metrics, in the Prometheus text format
+func scrapeMetrics() string {
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestRecordPullRequestMetrics(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	reviewer := config.User{Name: "reviewer"}
+	team := &config.TeamConfig{Name: "metrics-team", Users: []config.User{reviewer}}
+	repository := NewMockRepository(ctrl)
+	repository.EXPECT().GetName().Return("metrics-repo").AnyTimes()
+	repository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*PullRequest{{Title: "approved", CreateTime: now.Add(-30 * time.Minute)}},
+		[]*PullRequest{
+			{Title: "pr1", CreateTime: now.Add(-3 * 24 * time.Hour), Reviewers: []*Reviewer{{User: reviewer}}},
+			{Title: "pr2", CreateTime: now.Add(-3 * 24 * time.Hour), Reviewers: []*Reviewer{{User: reviewer}, {User: config.User{Name: "not-in-team"}}}},
+		},
+		[]*PullRequest{},
+	).AnyTimes()
+
+	RecordPullRequestMetrics(team, []Repository{repository}, now)
+	// Recording again replaces the team's metrics
+	RecordPullRequestMetrics(team, []Repository{repository}, now)
+
+	output := scrapeMetrics()
+	assert.Contains(t, output, `prr_pull_requests{category="ready_to_review",repository="metrics-repo",team="metrics-team"} 2`)
+	assert.Contains(t, output, `prr_pull_requests{category="ready_to_merge",repository="metrics-repo",team="metrics-team"} 1`)
+	assert.Contains(t, output, `prr_pull_requests{category="changes_requested",repository="metrics-repo",team="metrics-team"} 0`)
+	assert.Contains(t, output, `prr_pull_request_age_seconds_bucket{category="ready_to_review",team="metrics-team",le="172800"} 0`)
+	assert.Contains(t, output, `prr_pull_request_age_seconds_bucket{category="ready_to_review",team="metrics-team",le="604800"} 2`)
+	assert.Contains(t, output, `prr_pending_reviews{reviewer="reviewer",team="metrics-team"} 2`)
+	assert.NotContains(t, output, `not-in-team`)
+
+	// The series of the pull requests that don't need action anymore are removed
+	RecordPullRequestMetrics(team, []Repository{}, now)
+	output = scrapeMetrics()
+	assert.NotContains(t, output, `team="metrics-team"`)
+}
+
+func TestObserveAPICall(t *testing.T) {
+	t.Parallel()
+
+	observeAPICall("test-host", time.Now(), nil)
+	observeAPICall("test-host", time.Now(), errors.New("failed"))
+
+	output := scrapeMetrics()
+	assert.Contains(t, output, `prr_host_api_calls_total{host="test-host"} 2`)
+	assert.Contains(t, output, `prr_host_api_errors_total{host="test-host"} 1`)
+	assert.Contains(t, output, `prr_host_api_call_duration_seconds_count{host="test-host"} 2`)
+}
@@ -0,0 +1,78 @@
+package hosts
+
+import (
+	"fmt"
+	"time"
+)
+
+// IgnoredCategory is the category of the pull requests that don't need action from the team
+const IgnoredCategory = "ignored"
+
+// Reasons of the verdicts
+const (
+	ApprovedReason         = "approved"
+	NeedsApprovalsReason   = "needs_approvals"
+	ChangesRequestedReason = "changes_requested"
+
+	// Reasons why pull requests are ignored
+	SnoozedReason         = "snoozed"
+	WIPReason             = "wip"
+	NoReviewersReason     = "no_reviewers"
+	TooRecentReason       = "too_recent"
+	NotFromTeamReason     = "not_from_team"
+	MergeNotOverdueReason = "merge_not_overdue"
+)
+
+// IgnoredReasons are the reasons why pull requests are ignored, in the order they are checked
+var IgnoredReasons = []string{SnoozedReason, WIPReason, NoReviewersReason, TooRecentReason, NotFromTeamReason, MergeNotOverdueReason}
+
+// Verdict explains why a pull request is in its category or why it is ignored
+type Verdict struct {
+	// Category is ready_to_merge, ready_to_review, changes_requested or ignored
+	Category string
+	// Reason is one of the reasons above (ex: wip)
+	Reason string
+	// Explanation is the reason in a human readable form (ex: Marked WIP)
+	Explanation string
+}
+
+// IsIgnored returns true if the pull request doesn't need action from the team
+func (verdict *Verdict) IsIgnored() bool {
+	return verdict.Category == IgnoredCategory
+}
+
+// explanations are the explanations of the reasons that don't depend on the team's configuration
+var explanations = map[string]string{
+	ApprovedReason:         "Enough approvals from the team",
+	NeedsApprovalsReason:   "Not enough approvals from the team",
+	ChangesRequestedReason: "A reviewer of the team requested changes",
+	WIPReason:              "Marked WIP",
+	NoReviewersReason:      "No reviewers",
+	NotFromTeamReason:      "Not from one of the team's users",
+}
+
+func newVerdict(category, reason string) *Verdict {
+	return &Verdict{Category: category, Reason: reason, Explanation: explanations[reason]}
+}
+
+func newIgnoredVerdict(reason string) *Verdict {
+	return newVerdict(IgnoredCategory, reason)
+}
+
+func newSnoozedVerdict(snoozedUntil time.Time) *Verdict {
+	verdict := newIgnoredVerdict(SnoozedReason)
+	verdict.Explanation = fmt.Sprintf("Snoozed until %v", snoozedUntil)
+	return verdict
+}
+
+func newTooRecentVerdict(ageBeforeNotifying time.Duration) *Verdict {
+	verdict := newIgnoredVerdict(TooRecentReason)
+	verdict.Explanation = fmt.Sprintf("Not old enough. It hasn't been created for %v", ageBeforeNotifying)
+	return verdict
+}
+
+func newMergeNotOverdueVerdict(ageBeforeNotifying time.Duration) *Verdict {
+	verdict := newIgnoredVerdict(MergeNotOverdueReason)
+	verdict.Explanation = fmt.Sprintf("Merge not overdue, hasn't been stale for %v", ageBeforeNotifying)
+	return verdict
+}
@@ -1,53 +1,175 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
+	"github.com/julienduchesne/pull-request-reminder/api"
 	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/dashboard"
 	"github.com/julienduchesne/pull-request-reminder/hosts"
 	"github.com/julienduchesne/pull-request-reminder/messages"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	configReader, err := config.NewReader()
+	if err := execute(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		log.WithError(err).Fatalln("Error while running the command")
+	}
+}
+
+// newServeMux returns the handler of the endpoints. The configuration and the store are given by current on each request,
+// so that the interactions are handled with the configuration reloaded by the daemon
+func newServeMux(current func() (*config.GlobalConfig, state.Store), status *status, snapshots *api.Snapshots) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slack/interactions", func(writer http.ResponseWriter, request *http.Request) {
+		globalConfig, store := current()
+		newSlackInteractionHandler(globalConfig, store).ServeHTTP(writer, request)
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", status.healthzHandler)
+	mux.HandleFunc("/readyz", status.readyzHandler)
+	mux.HandleFunc("/status", status.statusHandler)
+	return mux
+}
+
+// newInternalServeMux returns the handler of the API and the dashboard. They serve the pull requests of all teams without
+// authentication, which is why they are on their own listener that must not be reachable publicly
+func newInternalServeMux(snapshots *api.Snapshots) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api.NewHandler(snapshots))
+	dashboardHandler := dashboard.NewHandler(snapshots)
+	mux.Handle("/dashboard", dashboardHandler)
+	mux.Handle("/dashboard/", dashboardHandler)
+	return mux
+}
+
+// newSlackInteractionHandler returns the handler of the Slack interactions, verified with the signing secrets of all teams
+func newSlackInteractionHandler(globalConfig *config.GlobalConfig, store state.Store) http.Handler {
+	signingSecrets := []string{}
+	for _, team := range globalConfig.Teams {
+		if team.Messaging.Slack.SigningSecret != "" {
+			signingSecrets = append(signingSecrets, team.Messaging.Slack.SigningSecret)
+		}
+	}
+	return messages.NewSlackInteractionHandler(utilities.Unique(signingSecrets), store)
+}
+
+// runTeam notifies the team about its pull requests needing action, records the run and keeps the snapshot of its pull requests.
+// In dry-run mode, the messages are rendered instead of being sent. The state is saved once, at the end of the run, even if the run
+// failed so that what was sent before the error is not sent again
+func runTeam(team *config.TeamConfig, store state.Store, snapshots *api.Snapshots, dryRun *messages.DryRun) error {
+	err := notifyTeam(team, store, snapshots, dryRun)
+	if flushErr := store.Flush(); flushErr != nil {
+		if err != nil {
+			log.WithError(flushErr).Errorf("Error while saving the state of the %s team", team.Name)
+			return err
+		}
+		return fmt.Errorf("Error while saving the state: %v", flushErr)
+	}
+	return err
+}
+
+func notifyTeam(team *config.TeamConfig, store state.Store, snapshots *api.Snapshots, dryRun *messages.DryRun) error {
+	now := time.Now()
+	if team.IsHoliday(now) {
+		log.Infof("Skipping the %s team since today is a holiday", team.Name)
+		return nil
+	}
+	handlers, err := messages.GetHandlers(team, store, dryRun)
 	if err != nil {
-		log.WithError(err).Fatalln("Error while initializing the configuration reader")
+		return fmt.Errorf("Error while initializing the message handlers: %v", err)
 	}
-	config, err := configReader.ReadConfig()
+	allRepositories, err := getRepositories(hosts.GetHosts(team, store))
 	if err != nil {
-		log.WithError(err).Fatalln("Error while reading the configuration")
+		return err
 	}
-	for _, team := range config.Teams {
-		repositories := getRepositoriesNeedingAction(hosts.GetHosts(team))
-		if err = handleRepositories(messages.GetHandlers(team), repositories); err != nil {
-			log.WithError(err).Fatalln("Error while handling messages")
-		}
+	if err = hosts.RecordFirstSeen(store, allRepositories, now); err != nil {
+		return err
 	}
+	snapshots.Set(api.NewTeamSnapshot(team, allRepositories, now))
+	hosts.RecordPullRequestMetrics(team, hosts.GetRepositoriesNeedingAction(allRepositories), now)
+	if err = handleRepositories(handlers, allRepositories); err != nil {
+		return fmt.Errorf("Error while handling messages: %v", err)
+	}
+	if err = hosts.PruneClosedPullRequests(store, allRepositories); err != nil {
+		return err
+	}
+	if err = recordRun(store, team, now); err != nil {
+		return fmt.Errorf("Error while recording the run: %v", err)
+	}
+	return nil
+}
+
+// hostErrors are the errors of the hosts whose repositories could not be fetched, by host name
+type hostErrors map[string]error
+
+func (errors hostErrors) Error() string {
+	messages := []string{}
+	for hostName, err := range errors {
+		messages = append(messages, fmt.Sprintf("Error while fetching repositories from %s: %v", hostName, err))
+	}
+	sort.Strings(messages)
+	return strings.Join(messages, ", ")
 }
 
-func getRepositoriesNeedingAction(teamHosts []hosts.Host) []hosts.Repository {
-	repositoriesNeedingAction := []hosts.Repository{}
+// getRepositories returns the repositories of all hosts. All hosts are fetched even if some of them fail,
+// the returned error is then a hostErrors
+func getRepositories(teamHosts []hosts.Host) ([]hosts.Repository, error) {
+	allRepositories := []hosts.Repository{}
+	errors := hostErrors{}
 	for _, host := range teamHosts {
 		repositories, err := host.GetRepositories()
 		if err != nil {
-			log.WithError(err).Fatalf("Error while fetching repositories from %s", host.GetName())
-		}
-		for _, repository := range repositories {
-			if repository.HasPullRequestsToDisplay() {
-				repositoriesNeedingAction = append(repositoriesNeedingAction, repository)
-			}
+			errors[host.GetName()] = err
+			continue
 		}
+		allRepositories = append(allRepositories, repositories...)
+	}
+	if len(errors) > 0 {
+		return nil, errors
 	}
-	return repositoriesNeedingAction
+	return allRepositories, nil
 }
 
+// handleRepositories notifies the handlers of the repositories. They are notified even if no pull requests need action,
+// each handler decides whether it has something to send: reports are rewritten so that they don't list merged pull requests
 func handleRepositories(handlers []messages.MessageHandler, repositories []hosts.Repository) error {
-	if len(repositories) > 0 {
-		for _, handler := range handlers {
-			if err := handler.Notify(repositories); err != nil {
-				return err
-			}
+	for _, handler := range handlers {
+		if err := handler.Notify(repositories); err != nil {
+			return err
 		}
 	}
 	return nil
 }
+
+// recordRun records when the team was last handled and, in delta mode, when the digest of all pull requests was last sent
+func recordRun(store state.Store, team *config.TeamConfig, now time.Time) error {
+	teamState, err := state.GetTeamState(store, team.Name)
+	if err != nil {
+		return err
+	}
+	if team.Messaging.Delta.Enabled && team.IsDigestDue(teamState.LastDigest, now) {
+		teamState.LastDigest = now
+	}
+	teamState.LastRun = now
+	return state.SetTeamState(store, team.Name, teamState)
+}
+
+// newReadOnlyStore returns a store reading the state at the given path. The state is not modified: the values written
+// during a dry run or by the commands that only print pull requests are kept in memory
+func newReadOnlyStore(path string) (state.Store, error) {
+	store, err := state.NewStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return state.NewOverlayStore(store), nil
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 
+	"github.com/julienduchesne/pull-request-reminder/api"
+	"github.com/julienduchesne/pull-request-reminder/config"
 	"github.com/julienduchesne/pull-request-reminder/hosts"
 	"github.com/julienduchesne/pull-request-reminder/messages"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,18 +25,11 @@ const (
 	testRepositoryWithoutPRsName = "BadRepository"
 )
 
-func TestCallMainWithMinimalConfig(t *testing.T) {
-	tempDir := os.TempDir()
-	configPath := path.Join(tempDir, "config_file")
+func TestRunWithMinimalConfig(t *testing.T) {
+	configPath := path.Join(os.TempDir(), "config_file")
 	ioutil.WriteFile(configPath, []byte("{}"), 0644)
 
-	if oldValue := os.Getenv("PRR_CONFIG"); oldValue != "" {
-		defer os.Setenv("PRR_CONFIG", oldValue)
-	} else {
-		defer os.Unsetenv("PRR_CONFIG")
-	}
-	os.Setenv("PRR_CONFIG", configPath)
-	main()
+	assert.Nil(t, execute([]string{"-config", configPath}, ioutil.Discard, ioutil.Discard))
 }
 
 func TestGetRepositories(t *testing.T) {
@@ -47,21 +47,155 @@ func TestGetRepositories(t *testing.T) {
 	mockHost := hosts.NewMockHost(ctrl)
 	mockHost.EXPECT().GetRepositories().Return([]hosts.Repository{mockRepository, mockRepositoryWithoutPRs}, nil)
 
-	repositories := getRepositoriesNeedingAction([]hosts.Host{mockHost})
+	repositories, err := getRepositories([]hosts.Host{mockHost})
+	assert.Nil(t, err)
+	assert.Len(t, repositories, 2)
+	repositories = hosts.GetRepositoriesNeedingAction(repositories)
 	assert.Len(t, repositories, 1)
 	assert.Equal(t, testRepositoryName, repositories[0].GetName())
 }
 
+func TestGetRepositoriesWithHostErrors(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := hosts.NewMockRepository(ctrl)
+	workingHost := hosts.NewMockHost(ctrl)
+	workingHost.EXPECT().GetRepositories().Return([]hosts.Repository{mockRepository}, nil)
+	failingHost := hosts.NewMockHost(ctrl)
+	failingHost.EXPECT().GetName().Return("Github").AnyTimes()
+	failingHost.EXPECT().GetRepositories().Return(nil, errors.New("Bad credentials"))
+
+	// All hosts are fetched
+	repositories, err := getRepositories([]hosts.Host{failingHost, workingHost})
+	assert.Nil(t, repositories)
+	assert.EqualError(t, err, "Error while fetching repositories from Github: Bad credentials")
+	assert.Len(t, err.(hostErrors), 1)
+}
+
 func TestHandleRepositories(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	testRepository := &hosts.RepositoryImpl{Name: testRepositoryName}
-	repositories := []hosts.Repository{testRepository}
+	testRepository := hosts.NewMockRepository(ctrl)
+	testRepository.EXPECT().HasPullRequestsToDisplay().Return(true).AnyTimes()
+	testRepository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*hosts.PullRequest{}, []*hosts.PullRequest{{Title: "pr1", Link: "link1.com"}}, []*hosts.PullRequest{},
+	).AnyTimes()
+	testRepositoryWithoutPRs := hosts.NewMockRepository(ctrl)
+	testRepositoryWithoutPRs.EXPECT().HasPullRequestsToDisplay().Return(false).AnyTimes()
+	testRepositoryWithoutPRs.EXPECT().GetPullRequestsToDisplay().Return([]*hosts.PullRequest{}, []*hosts.PullRequest{}, []*hosts.PullRequest{}).AnyTimes()
 
+	// Handlers are given all repositories, for the ignored pull requests, and they are notified even if no pull request needs action
+	repositories := []hosts.Repository{testRepository, testRepositoryWithoutPRs}
 	mockMessageHandler := messages.NewMockMessageHandler(ctrl)
+	mockMessageHandler.EXPECT().Notify([]hosts.Repository{testRepositoryWithoutPRs}).Times(1)
 	mockMessageHandler.EXPECT().Notify(repositories).Times(1)
 
-	handleRepositories([]messages.MessageHandler{mockMessageHandler}, repositories)
+	assert.Nil(t, handleRepositories([]messages.MessageHandler{mockMessageHandler}, []hosts.Repository{testRepositoryWithoutPRs}))
+	assert.Nil(t, handleRepositories([]messages.MessageHandler{mockMessageHandler}, repositories))
+}
+
+func TestRecordRun(t *testing.T) {
+	t.Parallel()
+
+	store := state.NewMemoryStore()
+	team := &config.TeamConfig{Name: "my-team"}
+	now := time.Now()
+	assert.Nil(t, recordRun(store, team, now))
+	teamState, _ := state.GetTeamState(store, "my-team")
+	assert.True(t, now.Equal(teamState.LastRun))
+	assert.True(t, teamState.LastDigest.IsZero())
+
+	team.Messaging.Delta.Enabled = true
+	team.Messaging.Delta.DigestDay = now.UTC().Weekday().String()
+	assert.Nil(t, recordRun(store, team, now))
+	teamState, _ = state.GetTeamState(store, "my-team")
+	assert.True(t, now.Equal(teamState.LastDigest))
+}
+
+func TestRunTeamSavesTheState(t *testing.T) {
+	directory, err := ioutil.TempDir("", "run-team")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	statePath := path.Join(directory, "state")
+
+	store, err := state.NewStore(statePath)
+	assert.Nil(t, err)
+	assert.Nil(t, runTeam(&config.TeamConfig{Name: "my-team"}, store, api.NewSnapshots(), nil))
+
+	// The state written during the run is saved at its end
+	otherStore, err := state.NewStore(statePath)
+	assert.Nil(t, err)
+	teamState, err := state.GetTeamState(otherStore, "my-team")
+	assert.Nil(t, err)
+	assert.False(t, teamState.LastRun.IsZero())
+}
+
+func TestNewServeMux(t *testing.T) {
+	t.Parallel()
+
+	globalConfig := &config.GlobalConfig{Teams: []*config.TeamConfig{{}}}
+	globalConfig.Teams[0].Messaging.Slack.SigningSecret = "secret"
+	store := state.NewMemoryStore()
+	mux := newServeMux(func() (*config.GlobalConfig, state.Store) { return globalConfig, store }, newStatus(), api.NewSnapshots())
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/slack/interactions", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code) // The request is not signed
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// The API and the dashboard are only served on the internal listener
+	for _, url := range []string{"/api/teams", "/dashboard", "/unknown"} {
+		recorder = httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, url)
+	}
+}
+
+func TestNewInternalServeMux(t *testing.T) {
+	t.Parallel()
+
+	mux := newInternalServeMux(api.NewSnapshots())
+	for url, expectedCode := range map[string]int{
+		"/api/teams":          http.StatusOK,
+		"/dashboard":          http.StatusOK,
+		"/slack/interactions": http.StatusNotFound,
+		"/metrics":            http.StatusNotFound,
+	} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, expectedCode, recorder.Code, url)
+	}
+}
+
+func TestNewReadOnlyStore(t *testing.T) {
+	directory, err := ioutil.TempDir("", "read-only-store")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	statePath := path.Join(directory, "state")
+
+	store, err := state.NewStore(statePath)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Set("key", "saved"))
+	assert.Nil(t, store.Flush())
+
+	readOnlyStore, err := newReadOnlyStore(statePath)
+	assert.Nil(t, err)
+	assert.Nil(t, readOnlyStore.Set("key", "changed"))
+	assert.Nil(t, readOnlyStore.Set("other-key", "added"))
+	assert.Nil(t, readOnlyStore.Flush())
+
+	var value string
+	found, err := store.Get("key", &value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, "saved", value)
+	found, _ = store.Get("other-key", &value)
+	assert.False(t, found, "The commands that only print pull requests must not write the state")
 }
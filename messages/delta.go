@@ -0,0 +1,85 @@
+package messages
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// deltaFilter keeps, in delta mode, the pull requests that changed since they were last notified to a destination
+type deltaFilter struct {
+	enabled         bool
+	lastRun         time.Time
+	staleThresholds []time.Duration
+	now             time.Time
+}
+
+// newDeltaFilter returns the team's delta filter. All pull requests are kept when delta mode is disabled or when the digest is due
+func newDeltaFilter(teamConfig *config.TeamConfig, store state.Store, now time.Time) *deltaFilter {
+	delta := teamConfig.Messaging.Delta
+	if !delta.Enabled {
+		return &deltaFilter{}
+	}
+	teamState, err := state.GetTeamState(store, teamConfig.Name)
+	if err != nil {
+		log.WithError(err).Warningf("Unable to read the last run of the %s team. All pull requests are sent", teamConfig.Name)
+		return &deltaFilter{}
+	}
+	if teamConfig.IsDigestDue(teamState.LastDigest, now) {
+		log.Infof("Sending the digest of all pull requests of the %s team", teamConfig.Name)
+		return &deltaFilter{}
+	}
+	return &deltaFilter{enabled: true, lastRun: teamState.LastRun, staleThresholds: delta.StaleThresholds, now: now}
+}
+
+// filter returns the pull requests that changed since they were last notified to the given destination
+func (filter *deltaFilter) filter(destination string, pullRequests []categorizedPullRequest) []categorizedPullRequest {
+	if !filter.enabled {
+		return pullRequests
+	}
+	changedPullRequests := []categorizedPullRequest{}
+	for _, pullRequest := range pullRequests {
+		if filter.isChanged(destination, pullRequest) {
+			changedPullRequests = append(changedPullRequests, pullRequest)
+		}
+	}
+	return changedPullRequests
+}
+
+// isChanged returns true if the pull request was not notified to the destination during the last run, if it changed category
+// or if it has been stale for longer than one of the thresholds since it was last notified
+func (filter *deltaFilter) isChanged(destination string, pullRequest categorizedPullRequest) bool {
+	if pullRequest.pullRequest.State == nil {
+		return true
+	}
+	notification := pullRequest.pullRequest.State.GetNotification(destination)
+	if notification.Time.IsZero() || notification.Time.Before(filter.lastRun) {
+		return true
+	}
+	if notification.Category != pullRequest.category {
+		return true
+	}
+	updateTime := pullRequest.pullRequest.UpdateTime
+	for _, threshold := range filter.staleThresholds {
+		if notification.Time.Sub(updateTime) < threshold && filter.now.Sub(updateTime) >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// recordNotifications records, in the state, that the pull requests were notified to the destination in their current category.
+// It is called once the destination knows about all of them: its message was sent or none of them changed
+func recordNotifications(store state.Store, destination string, pullRequests []categorizedPullRequest, now time.Time) error {
+	for _, pullRequest := range pullRequests {
+		if _, err := state.UpdatePullRequestState(store, pullRequest.pullRequest.Link, func(pullRequestState *state.PullRequestState) {
+			pullRequestState.SetNotification(destination, pullRequest.category, now)
+		}); err != nil {
+			return fmt.Errorf("Unable to record when %s (%s) was notified: %v", pullRequest.pullRequest.Title, pullRequest.pullRequest.Link, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterChangedPullRequests(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	lastRun := now.Add(-time.Hour)
+	newPullRequest := func(title, category string, pullRequestState *state.PullRequestState, updateTime time.Time) categorizedPullRequest {
+		return categorizedPullRequest{
+			category:    category,
+			pullRequest: &hosts.PullRequest{Title: title, Link: title + ".com", UpdateTime: updateTime, State: pullRequestState},
+		}
+	}
+	notified := func(category string) *state.PullRequestState {
+		pullRequestState := &state.PullRequestState{}
+		pullRequestState.SetNotification("#channel", category, lastRun)
+		return pullRequestState
+	}
+	notInLastRun := &state.PullRequestState{}
+	notInLastRun.SetNotification("#channel", readyToReviewCategory, lastRun.Add(-24*time.Hour))
+	pullRequests := []categorizedPullRequest{
+		newPullRequest("new", readyToReviewCategory, nil, now),
+		newPullRequest("not-in-last-run", readyToReviewCategory, notInLastRun, now),
+		newPullRequest("unchanged", readyToReviewCategory, notified(readyToReviewCategory), now.Add(-2*time.Hour)),
+		newPullRequest("approved", readyToMergeCategory, notified(readyToReviewCategory), now.Add(-2*time.Hour)),
+		newPullRequest("stale", readyToReviewCategory, notified(readyToReviewCategory), now.Add(-24*time.Hour-30*time.Minute)),
+		newPullRequest("already-stale", readyToReviewCategory, notified(readyToReviewCategory), now.Add(-50*time.Hour)),
+	}
+
+	store := state.NewMemoryStore()
+	state.SetTeamState(store, "my-team", &state.TeamState{LastRun: lastRun, LastDigest: now})
+	teamConfig := &config.TeamConfig{Name: "my-team"}
+
+	// Delta mode disabled
+	assert.Len(t, newDeltaFilter(teamConfig, store, now).filter("#channel", pullRequests), len(pullRequests))
+
+	teamConfig.Messaging.Delta = config.DeltaConfig{Enabled: true, StaleThresholds: []time.Duration{24 * time.Hour}}
+	filter := newDeltaFilter(teamConfig, store, now)
+	titles := []string{}
+	for _, pullRequest := range filter.filter("#channel", pullRequests) {
+		titles = append(titles, pullRequest.pullRequest.Title)
+	}
+	assert.Equal(t, []string{"new", "not-in-last-run", "approved", "stale"}, titles)
+	// Notifications are per destination, none of the pull requests were notified to this user
+	assert.Len(t, filter.filter("U123", pullRequests), len(pullRequests))
+
+	// The digest includes all pull requests
+	teamConfig.Messaging.Delta.DigestDay = now.UTC().Weekday().String()
+	state.SetTeamState(store, "my-team", &state.TeamState{LastRun: lastRun, LastDigest: now.AddDate(0, 0, -7)})
+	assert.Len(t, newDeltaFilter(teamConfig, store, now).filter("#channel", pullRequests), len(pullRequests))
+}
+
+func TestRecordNotifications(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	store := state.NewMemoryStore()
+	pullRequests := []categorizedPullRequest{{category: readyToMergeCategory, pullRequest: &hosts.PullRequest{Link: "pr.com"}}}
+	assert.Nil(t, recordNotifications(store, "#channel", pullRequests, now))
+	pullRequestState, _ := state.GetPullRequestState(store, "pr.com")
+	assert.True(t, now.Equal(pullRequestState.GetNotification("#channel").Time))
+	assert.Equal(t, readyToMergeCategory, pullRequestState.GetNotification("#channel").Category)
+	assert.Equal(t, state.Notification{}, pullRequestState.GetNotification("U123"))
+}
@@ -0,0 +1,43 @@
+package messages
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// DryRun renders the messages of the handlers without sending them. Each rendered payload is written to the writer,
+// or to its own file in the directory if it is set
+type DryRun struct {
+	Writer    io.Writer
+	Directory string
+
+	mutex sync.Mutex
+	count int
+}
+
+var unsafeFileNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// write outputs the payload of a message. The file name is built from the team, the handler and the message's number
+func (dryRun *DryRun) write(team, handler, extension string, payload []byte) error {
+	dryRun.mutex.Lock()
+	defer dryRun.mutex.Unlock()
+	dryRun.count++
+
+	if dryRun.Directory == "" {
+		_, err := fmt.Fprintf(dryRun.Writer, "%s\n", payload)
+		return err
+	}
+	if err := os.MkdirAll(dryRun.Directory, 0755); err != nil {
+		return fmt.Errorf("Unable to create the dry-run directory: %v", err)
+	}
+	fileName := unsafeFileNameCharacters.ReplaceAllString(fmt.Sprintf("%03d-%s-%s", dryRun.count, team, handler), "_") + "." + extension
+	if err := ioutil.WriteFile(filepath.Join(dryRun.Directory, fileName), payload, 0644); err != nil {
+		return fmt.Errorf("Unable to write the dry-run payload: %v", err)
+	}
+	return nil
+}
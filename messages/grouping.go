@@ -0,0 +1,158 @@
+package messages
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+)
+
+// Ways to group pull requests in messages
+const (
+	groupByRepository = "repository"
+	groupByAuthor     = "author"
+	groupByReviewer   = "reviewer"
+	groupByHost       = "host"
+)
+
+// categories are the pull request categories, in the order they are displayed
+var categories = []string{readyToMergeCategory, readyToReviewCategory, changesRequestedCategory}
+
+// categorizedPullRequest is a pull request to display along with its repository and its category
+type categorizedPullRequest struct {
+	repository  hosts.Repository
+	category    string
+	pullRequest *hosts.PullRequest
+}
+
+// pullRequestGroup is a list of pull requests displayed under the same title
+type pullRequestGroup struct {
+	// repository is only set when pull requests are grouped by repository
+	repository   hosts.Repository
+	name         string
+	pullRequests []categorizedPullRequest
+}
+
+// inCategory returns the group's pull requests that are in the given category
+func (group pullRequestGroup) inCategory(category string) []categorizedPullRequest {
+	pullRequests := []categorizedPullRequest{}
+	for _, pullRequest := range group.pullRequests {
+		if pullRequest.category == category {
+			pullRequests = append(pullRequests, pullRequest)
+		}
+	}
+	return pullRequests
+}
+
+func validateSort(sortBy string) error {
+	switch sortBy {
+	case "", config.SortByAge, config.SortByStaleness, config.SortByApprovals, config.SortBySize:
+		return nil
+	}
+	return fmt.Errorf("Invalid sort %q. Valid sorts are: %s, %s, %s and %s", sortBy, config.SortByAge, config.SortByStaleness, config.SortByApprovals, config.SortBySize)
+}
+
+func validateGroupBy(groupBy string) error {
+	switch groupBy {
+	case "", groupByRepository, groupByAuthor, groupByReviewer, groupByHost:
+		return nil
+	}
+	return fmt.Errorf("Invalid grouping %q. Valid groupings are: %s, %s, %s and %s", groupBy, groupByRepository, groupByAuthor, groupByReviewer, groupByHost)
+}
+
+// getCategorizedPullRequests returns the pull requests to display of all the given repositories, in the order they were returned by the hosts
+func getCategorizedPullRequests(repositories []hosts.Repository) []categorizedPullRequest {
+	pullRequests := []categorizedPullRequest{}
+	for _, repository := range repositories {
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		for index, categoryPullRequests := range [][]*hosts.PullRequest{readyToMerge, readyToReview, changesRequested} {
+			for _, pullRequest := range categoryPullRequests {
+				pullRequests = append(pullRequests, categorizedPullRequest{repository: repository, category: categories[index], pullRequest: pullRequest})
+			}
+		}
+	}
+	return pullRequests
+}
+
+// sortPullRequests sorts the given pull requests in place. When no sort is given, the hosts' order is kept
+func sortPullRequests(pullRequests []categorizedPullRequest, sortBy string) {
+	var less func(first, second *hosts.PullRequest) bool
+	switch sortBy {
+	case config.SortByAge:
+		less = func(first, second *hosts.PullRequest) bool { return first.CreateTime.Before(second.CreateTime) }
+	case config.SortByStaleness:
+		less = func(first, second *hosts.PullRequest) bool { return first.UpdateTime.Before(second.UpdateTime) }
+	case config.SortByApprovals:
+		less = func(first, second *hosts.PullRequest) bool { return countApprovals(first) < countApprovals(second) }
+	case config.SortBySize:
+		// Smallest first, unknown sizes last
+		less = func(first, second *hosts.PullRequest) bool {
+			return first.Size > 0 && (second.Size == 0 || first.Size < second.Size)
+		}
+	default:
+		return
+	}
+	sort.SliceStable(pullRequests, func(i, j int) bool {
+		return less(pullRequests[i].pullRequest, pullRequests[j].pullRequest)
+	})
+}
+
+// countApprovals returns the number of team members who approved the pull request
+func countApprovals(pullRequest *hosts.PullRequest) int {
+	approvals := 0
+	for _, reviewer := range pullRequest.Reviewers {
+		if reviewer.User.Name != "" && reviewer.Approved {
+			approvals++
+		}
+	}
+	return approvals
+}
+
+// groupPullRequests groups the given pull requests, keeping their order. Groups are ordered by their first pull request.
+// When grouping by reviewer, pull requests in need of approvers are added to the group of each of their pending reviewers
+// (according to isPending) while the other pull requests are added to their author's group since they are the ones expected to act
+func groupPullRequests(pullRequests []categorizedPullRequest, groupBy string, isPending func(*hosts.PullRequest, *hosts.Reviewer) bool) []pullRequestGroup {
+	groups := []pullRequestGroup{}
+	groupIndexes := map[string]int{}
+	var addToGroup = func(key string, group pullRequestGroup, pullRequest categorizedPullRequest) {
+		index, ok := groupIndexes[key]
+		if !ok {
+			index = len(groups)
+			groupIndexes[key] = index
+			groups = append(groups, group)
+		}
+		groups[index].pullRequests = append(groups[index].pullRequests, pullRequest)
+	}
+
+	for _, pullRequest := range pullRequests {
+		switch groupBy {
+		case groupByAuthor:
+			name := pullRequest.pullRequest.Author.Name
+			addToGroup(name, pullRequestGroup{name: name}, pullRequest)
+		case groupByReviewer:
+			names := []string{}
+			if pullRequest.category == readyToReviewCategory {
+				for _, reviewer := range pullRequest.pullRequest.Reviewers {
+					if reviewer.User.Name != "" && !reviewer.Approved && isPending(pullRequest.pullRequest, reviewer) {
+						names = append(names, reviewer.User.Name)
+					}
+				}
+				if len(names) == 0 {
+					names = append(names, "") // No pending reviewers
+				}
+			} else {
+				names = append(names, pullRequest.pullRequest.Author.Name)
+			}
+			for _, name := range names {
+				addToGroup(name, pullRequestGroup{name: name}, pullRequest)
+			}
+		case groupByHost:
+			name := pullRequest.repository.GetHost().GetName()
+			addToGroup(name, pullRequestGroup{name: name}, pullRequest)
+		default:
+			addToGroup(pullRequest.repository.GetLink(), pullRequestGroup{repository: pullRequest.repository, name: pullRequest.repository.GetName()}, pullRequest)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,132 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortPullRequests(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	first := &hosts.PullRequest{
+		Title:      "first",
+		CreateTime: now.Add(-2 * time.Hour),
+		UpdateTime: now.Add(-1 * time.Hour),
+		Size:       0,
+		Reviewers:  []*hosts.Reviewer{{Approved: true, User: config.User{Name: "user1"}}},
+	}
+	second := &hosts.PullRequest{
+		Title:      "second",
+		CreateTime: now.Add(-3 * time.Hour),
+		UpdateTime: now.Add(-2 * time.Hour),
+		Size:       100,
+		Reviewers:  []*hosts.Reviewer{{Approved: true, User: config.User{Name: "user1"}}, {Approved: true, User: config.User{Name: "user2"}}},
+	}
+	third := &hosts.PullRequest{
+		Title:      "third",
+		CreateTime: now.Add(-1 * time.Hour),
+		UpdateTime: now.Add(-3 * time.Hour),
+		Size:       10,
+		Reviewers:  []*hosts.Reviewer{{Approved: true, User: config.User{}}}, // Not a team member
+	}
+
+	cases := []struct {
+		sortBy   string
+		expected []string
+	}{
+		{"", []string{"first", "second", "third"}},
+		{config.SortByAge, []string{"second", "first", "third"}},
+		{config.SortByStaleness, []string{"third", "second", "first"}},
+		{config.SortByApprovals, []string{"third", "first", "second"}},
+		{config.SortBySize, []string{"third", "second", "first"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			pullRequests := []categorizedPullRequest{{pullRequest: first}, {pullRequest: second}, {pullRequest: third}}
+			sortPullRequests(pullRequests, tt.sortBy)
+			titles := []string{}
+			for _, pullRequest := range pullRequests {
+				titles = append(titles, pullRequest.pullRequest.Title)
+			}
+			assert.Equal(t, tt.expected, titles)
+		})
+	}
+}
+
+func TestGroupPullRequests(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user1, user2 := config.User{Name: "user1"}, config.User{Name: "user2"}
+	firstRepository := newMockRepository(ctrl,
+		[]*hosts.PullRequest{{Title: "merge", Author: user1}},
+		[]*hosts.PullRequest{
+			{Title: "review", Author: user1, Reviewers: []*hosts.Reviewer{{User: user2}, {User: config.User{Name: "skipper"}}}},
+			{Title: "approved by all", Author: user2, Reviewers: []*hosts.Reviewer{{User: user1, Approved: true}}},
+		},
+		[]*hosts.PullRequest{},
+	)
+	secondRepository := hosts.NewMockRepository(ctrl)
+	secondRepository.EXPECT().GetHost().Return(firstRepository.GetHost()).AnyTimes()
+	secondRepository.EXPECT().GetLink().Return("other-repo.com").AnyTimes()
+	secondRepository.EXPECT().GetName().Return("other-repo").AnyTimes()
+	secondRepository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*hosts.PullRequest{},
+		[]*hosts.PullRequest{},
+		[]*hosts.PullRequest{{Title: "changes", Author: user2, Reviewers: []*hosts.Reviewer{{User: user1, RequestedChanges: true}}}},
+	).AnyTimes()
+	pullRequests := getCategorizedPullRequests([]hosts.Repository{firstRepository, secondRepository})
+	assert.Len(t, pullRequests, 4)
+
+	var isPending = func(pullRequest *hosts.PullRequest, reviewer *hosts.Reviewer) bool {
+		return reviewer.User.Name != "skipper"
+	}
+	var summarize = func(groups []pullRequestGroup) map[string][]string {
+		summary := map[string][]string{}
+		for _, group := range groups {
+			for _, pullRequest := range group.pullRequests {
+				summary[group.name] = append(summary[group.name], pullRequest.pullRequest.Title)
+			}
+		}
+		return summary
+	}
+
+	byRepository := groupPullRequests(pullRequests, groupByRepository, isPending)
+	assert.Len(t, byRepository, 2)
+	assert.Equal(t, firstRepository, byRepository[0].repository)
+	assert.Len(t, byRepository[0].inCategory(readyToReviewCategory), 2)
+	assert.Len(t, byRepository[1].inCategory(changesRequestedCategory), 1)
+
+	byHost := groupPullRequests(pullRequests, groupByHost, isPending)
+	assert.Equal(t, map[string][]string{"mock": {"merge", "review", "approved by all", "changes"}}, summarize(byHost))
+	assert.Nil(t, byHost[0].repository)
+
+	assert.Equal(t, map[string][]string{
+		"user1": {"merge", "review"},
+		"user2": {"approved by all", "changes"},
+	}, summarize(groupPullRequests(pullRequests, groupByAuthor, isPending)))
+
+	assert.Equal(t, map[string][]string{
+		"user1": {"merge"},
+		"user2": {"review", "changes"},
+		"":      {"approved by all"},
+	}, summarize(groupPullRequests(pullRequests, groupByReviewer, isPending)))
+}
+
+func TestValidateSortAndGroupBy(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, validateSort(""))
+	assert.Nil(t, validateSort(config.SortBySize))
+	assert.EqualError(t, validateSort("newest"), "Invalid sort \"newest\". Valid sorts are: oldest, stale, approvals and size")
+	assert.Nil(t, validateGroupBy(groupByReviewer))
+	assert.EqualError(t, validateGroupBy("team"), "Invalid grouping \"team\". Valid groupings are: repository, author, reviewer and host")
+}
@@ -0,0 +1,38 @@
+package messages
+
+import (
+	"sort"
+
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+)
+
+// ignoredReasonLabels are the texts of the reasons why pull requests are ignored, given to the ignored template
+var ignoredReasonLabels = map[string]string{
+	hosts.SnoozedReason:         "snoozed",
+	hosts.WIPReason:             "WIP",
+	hosts.NoReviewersReason:     "no reviewers",
+	hosts.TooRecentReason:       "too recent",
+	hosts.NotFromTeamReason:     "not from the team",
+	hosts.MergeNotOverdueReason: "merge not overdue",
+}
+
+// newIgnoredData counts the ignored pull requests of the given repositories per reason
+func newIgnoredData(team string, repositories []hosts.Repository) ignoredData {
+	countPerReason := map[string]int{}
+	data := ignoredData{Team: team, Reasons: []ignoredReasonData{}}
+	for _, repository := range repositories {
+		for _, pullRequest := range repository.GetIgnoredPullRequests() {
+			if pullRequest.Verdict != nil {
+				countPerReason[pullRequest.Verdict.Reason]++
+				data.Count++
+			}
+		}
+	}
+	for _, reason := range hosts.IgnoredReasons {
+		if count := countPerReason[reason]; count > 0 {
+			data.Reasons = append(data.Reasons, ignoredReasonData{Reason: reason, Label: ignoredReasonLabels[reason], Count: count})
+		}
+	}
+	sort.SliceStable(data.Reasons, func(i, j int) bool { return data.Reasons[i].Count > data.Reasons[j].Count })
+	return data
+}
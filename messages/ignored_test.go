@@ -0,0 +1,64 @@
+package messages
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIgnoredTestRepository(ctrl *gomock.Controller, reasons ...string) *hosts.MockRepository {
+	ignored := []*hosts.PullRequest{}
+	for _, reason := range reasons {
+		ignored = append(ignored, &hosts.PullRequest{Verdict: &hosts.Verdict{Category: hosts.IgnoredCategory, Reason: reason}})
+	}
+	repository := hosts.NewMockRepository(ctrl)
+	repository.EXPECT().GetIgnoredPullRequests().Return(ignored).AnyTimes()
+	return repository
+}
+
+func TestNewIgnoredData(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repositories := []hosts.Repository{
+		newIgnoredTestRepository(ctrl, hosts.NoReviewersReason, hosts.WIPReason),
+		newIgnoredTestRepository(ctrl),
+		newIgnoredTestRepository(ctrl, hosts.WIPReason, hosts.SnoozedReason),
+	}
+	data := newIgnoredData("my-team", repositories)
+	assert.Equal(t, ignoredData{Team: "my-team", Count: 4, Reasons: []ignoredReasonData{
+		{Reason: hosts.WIPReason, Label: "WIP", Count: 2},
+		{Reason: hosts.SnoozedReason, Label: "snoozed", Count: 1},
+		{Reason: hosts.NoReviewersReason, Label: "no reviewers", Count: 1},
+	}}, data)
+}
+
+func TestWithIgnoredSection(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := newTestSlackMessageHandler(t)
+	message := slackMessage{{blocks: []slack.Block{newSlackTextSection("plain_text", "Header", false)}}}
+
+	withSection, err := handler.withIgnoredSection(message, []hosts.Repository{newIgnoredTestRepository(ctrl)})
+	assert.Nil(t, err)
+	assert.Equal(t, message, withSection)
+
+	withSection, err = handler.withIgnoredSection(message, []hosts.Repository{
+		newIgnoredTestRepository(ctrl, hosts.WIPReason, hosts.NoReviewersReason, hosts.WIPReason),
+	})
+	assert.Nil(t, err)
+	blocks := withSection.blocks()
+	assert.Len(t, blocks, 3)
+	assert.IsType(t, &slack.DividerBlock{}, blocks[1])
+	assert.Equal(t, "3 pull requests ignored: 2 WIP, 1 no reviewers", blocks[2].(*slack.SectionBlock).Text.Text)
+
+	withSection, err = handler.withIgnoredSection(message, []hosts.Repository{newIgnoredTestRepository(ctrl, hosts.TooRecentReason)})
+	assert.Nil(t, err)
+	assert.Equal(t, "1 pull request ignored: 1 too recent", withSection.blocks()[2].(*slack.SectionBlock).Text.Text)
+}
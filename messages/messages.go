@@ -3,16 +3,39 @@ package messages
 import (
 	"github.com/julienduchesne/pull-request-reminder/config"
 	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// messagesSent counts the messages sent (posted or updated) by each handler
+var messagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "prr_messages_sent_total",
+	Help: "Number of messages sent or updated, per handler",
+}, []string{"handler"})
+
 // MessageHandler is the interface that wraps the Notify method.
-// This method sends a message concerning the pull requests to a messaging provider
+// This method sends a message concerning the pull requests to a messaging provider. It is given all the team's repositories,
+// including those without pull requests to display, so that handlers can report the ignored pull requests.
+// It is called on every run, even when no pull requests need action: the handler decides whether it has something to send
 type MessageHandler interface {
 	Notify([]hosts.Repository) error
 }
 
-// GetHandlers returns all available and configured MessageHandler instances
-func GetHandlers(config *config.TeamConfig) []MessageHandler {
-	handlers := []MessageHandler{newSlackMessageHandler(config)}
-	return handlers
+// GetHandlers returns all available and configured MessageHandler instances.
+// If dryRun is set, the handlers write their messages to its output instead of sending them
+func GetHandlers(config *config.TeamConfig, store state.Store, dryRun *DryRun) ([]MessageHandler, error) {
+	slackHandler, err := newSlackMessageHandler(config, store, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	handlers := []MessageHandler{slackHandler}
+	for _, reportConfig := range config.Messaging.Reports {
+		reportHandler, err := newReportMessageHandler(config, reportConfig, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, reportHandler)
+	}
+	return handlers, nil
 }
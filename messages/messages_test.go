@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,8 +20,11 @@ func TestGetSlackMessageHandler(t *testing.T) {
 		DebugUser:                "@admin",
 	}
 
+	handlers, err := GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.Nil(t, err)
+
 	hasType := false
-	for _, handler := range GetHandlers(teamConfig) {
+	for _, handler := range handlers {
 
 		if slackHandler, ok := handler.(*slackMessageHandler); ok {
 			hasType = true
@@ -28,7 +32,74 @@ func TestGetSlackMessageHandler(t *testing.T) {
 			assert.True(t, slackHandler.messageUsers)
 			assert.Equal(t, "@admin", slackHandler.debugUser)
 			assert.NotNil(t, slackHandler.client)
+			assert.NotNil(t, slackHandler.templates)
 		}
 	}
 	assert.True(t, hasType, "There should be a handler of type: %v", reflect.TypeOf(&slackMessageHandler{}))
 }
+
+func TestGetHandlersWithInvalidTemplate(t *testing.T) {
+	t.Parallel()
+
+	teamConfig := &config.TeamConfig{}
+	teamConfig.Messaging.Templates.Header = "{{ .Team "
+
+	_, err := GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.EqualError(t, err, "Unable to parse the header template: template: header:1: unclosed action")
+}
+
+func TestGetHandlersWithInvalidChannelMessageMode(t *testing.T) {
+	t.Parallel()
+
+	teamConfig := &config.TeamConfig{}
+	teamConfig.Messaging.Slack.ChannelMessageMode = "edit"
+
+	_, err := GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.EqualError(t, err, `Invalid Slack channel message mode "edit". Valid modes are: new, update and thread`)
+}
+
+func TestGetHandlersWithSortAndGrouping(t *testing.T) {
+	t.Parallel()
+
+	teamConfig := &config.TeamConfig{}
+	teamConfig.Messaging.Sort = config.SortByAge
+	teamConfig.Messaging.GroupBy = groupByAuthor
+	teamConfig.Messaging.Slack.ChannelGroupBy = groupByReviewer
+
+	handlers, err := GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.Nil(t, err)
+	slackHandler := handlers[0].(*slackMessageHandler)
+	assert.Equal(t, config.SortByAge, slackHandler.sort)
+	assert.Equal(t, groupByReviewer, slackHandler.channelGroupBy)
+	assert.Equal(t, groupByAuthor, slackHandler.userGroupBy)
+
+	teamConfig.Messaging.Slack.UserGroupBy = "team"
+	_, err = GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.EqualError(t, err, `Invalid grouping "team". Valid groupings are: repository, author, reviewer and host`)
+}
+
+func TestGetReportMessageHandlers(t *testing.T) {
+	t.Parallel()
+
+	teamConfig := &config.TeamConfig{Name: "my-team"}
+	teamConfig.Messaging.Sort = config.SortByAge
+	teamConfig.Messaging.Reports = []config.ReportConfig{
+		{Format: "terminal"},
+		{Format: "html", Path: "report.html", Sort: config.SortByStaleness},
+	}
+
+	handlers, err := GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.Nil(t, err)
+	assert.Len(t, handlers, 3)
+	terminalHandler := handlers[1].(*reportMessageHandler)
+	assert.Equal(t, "terminal", terminalHandler.format)
+	assert.Equal(t, config.SortByAge, terminalHandler.sort)
+	htmlHandler := handlers[2].(*reportMessageHandler)
+	assert.Equal(t, "report.html", htmlHandler.path)
+	assert.Equal(t, config.SortByStaleness, htmlHandler.sort)
+	assert.False(t, htmlHandler.color) // Reports written to files are not colorized
+
+	teamConfig.Messaging.Reports = []config.ReportConfig{{Format: "pdf"}}
+	_, err = GetHandlers(teamConfig, state.NewMemoryStore(), nil)
+	assert.EqualError(t, err, `Invalid report format "pdf". Valid formats are: terminal, markdown and html`)
+}
@@ -0,0 +1,362 @@
+package messages
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+)
+
+const reportHandlerName = "report"
+
+// Formats of the reports
+const (
+	terminalReportFormat = "terminal"
+	markdownReportFormat = "markdown"
+	htmlReportFormat     = "html"
+)
+
+// reportExtensions are the extensions of the files written by the dry run, per format
+var reportExtensions = map[string]string{
+	terminalReportFormat: "txt",
+	markdownReportFormat: "md",
+	htmlReportFormat:     "html",
+}
+
+// reportCategories are the sections of the reports, in order
+var reportCategories = []struct {
+	category string
+	title    string
+	color    string
+}{
+	{readyToMergeCategory, "Ready to merge", ansiGreen},
+	{readyToReviewCategory, "In need of approvers", ansiYellow},
+	{changesRequestedCategory, "Waiting on their author (changes requested)", ansiRed},
+}
+
+var reportIgnoredTemplate = template.Must(template.New("ignored").Funcs(templateFuncs).Parse(defaultTemplates.Ignored))
+
+// reportData is given to the report renderers
+type reportData struct {
+	Team        string
+	GeneratedAt time.Time
+	// Categories only contain the categories with pull requests
+	Categories []reportCategoryData
+	// Ignored is the number of ignored pull requests per reason (ex: 3 pull requests ignored: 2 WIP, 1 no reviewers). It is empty if none were ignored
+	Ignored string
+}
+
+type reportCategoryData struct {
+	Category     string
+	Title        string
+	PullRequests []pullRequestData
+}
+
+// reportMessageHandler writes a summary of the pull requests as a colorized table for the terminal, a Markdown document or an HTML page
+type reportMessageHandler struct {
+	teamName        string
+	format          string
+	path            string
+	sort            string
+	neededApprovals int
+
+	// color enables the colors of the terminal report. They are only used when printing to a terminal
+	color  bool
+	stdout io.Writer
+	dryRun *DryRun
+}
+
+func newReportMessageHandler(teamConfig *config.TeamConfig, reportConfig config.ReportConfig, dryRun *DryRun) (*reportMessageHandler, error) {
+	switch reportConfig.Format {
+	case terminalReportFormat, markdownReportFormat, htmlReportFormat:
+	default:
+		return nil, fmt.Errorf("Invalid report format %q. Valid formats are: %s, %s and %s", reportConfig.Format, terminalReportFormat, markdownReportFormat, htmlReportFormat)
+	}
+	sort := utilities.FirstNonEmpty(reportConfig.Sort, teamConfig.Messaging.Sort)
+	if err := validateSort(sort); err != nil {
+		return nil, err
+	}
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &reportMessageHandler{
+		teamName:        teamConfig.Name,
+		format:          reportConfig.Format,
+		path:            reportConfig.Path,
+		sort:            sort,
+		neededApprovals: teamConfig.GetNumberOfNeededApprovals(),
+		color:           reportConfig.Path == "" && dryRun == nil && !noColor && isTerminal(os.Stdout),
+		stdout:          os.Stdout,
+		dryRun:          dryRun,
+	}, nil
+}
+
+// isTerminal returns whether the file is a terminal. Colors would clutter pipes and CI logs
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Notify writes the report of the pull requests needing action
+func (handler *reportMessageHandler) Notify(repositories []hosts.Repository) error {
+	report, err := handler.render(handler.newReportData(repositories, time.Now()))
+	if err != nil {
+		return err
+	}
+	if err := handler.write(report); err != nil {
+		return err
+	}
+	messagesSent.WithLabelValues(reportHandlerName).Inc()
+	return nil
+}
+
+func (handler *reportMessageHandler) newReportData(repositories []hosts.Repository, now time.Time) reportData {
+	pullRequests := getCategorizedPullRequests(hosts.GetRepositoriesNeedingAction(repositories))
+	sortPullRequests(pullRequests, handler.sort)
+
+	data := reportData{Team: handler.teamName, GeneratedAt: now, Categories: []reportCategoryData{}}
+	for _, reportCategory := range reportCategories {
+		categoryData := reportCategoryData{Category: reportCategory.category, Title: reportCategory.title, PullRequests: []pullRequestData{}}
+		for _, pullRequest := range pullRequests {
+			if pullRequest.category == reportCategory.category {
+				repository := newRepositoryData(pullRequest.repository)
+				categoryData.PullRequests = append(categoryData.PullRequests, newPullRequestData(repository, pullRequest.category, false, handler.neededApprovals, pullRequest.pullRequest, isReviewerAbsent))
+			}
+		}
+		if len(categoryData.PullRequests) > 0 {
+			data.Categories = append(data.Categories, categoryData)
+		}
+	}
+	if ignored := newIgnoredData(handler.teamName, repositories); ignored.Count > 0 {
+		// The default template is used since the team's templates are written for chat messages
+		data.Ignored, _ = render(reportIgnoredTemplate, ignored)
+	}
+	return data
+}
+
+func (handler *reportMessageHandler) render(data reportData) ([]byte, error) {
+	switch handler.format {
+	case markdownReportFormat:
+		return renderMarkdownReport(data), nil
+	case htmlReportFormat:
+		buffer := &bytes.Buffer{}
+		if err := htmlReportTemplate.Execute(buffer, data); err != nil {
+			return nil, fmt.Errorf("Unable to render the HTML report: %v", err)
+		}
+		return buffer.Bytes(), nil
+	}
+	return renderTerminalReport(data, handler.color), nil
+}
+
+func (handler *reportMessageHandler) write(report []byte) error {
+	if handler.dryRun != nil {
+		return handler.dryRun.write(handler.teamName, reportHandlerName+"-"+handler.format, reportExtensions[handler.format], report)
+	}
+	if handler.path == "" {
+		_, err := handler.stdout.Write(report)
+		return err
+	}
+	if err := ioutil.WriteFile(handler.path, report, 0644); err != nil {
+		return fmt.Errorf("Unable to write the %s report of the %s team: %v", handler.format, handler.teamName, err)
+	}
+	return nil
+}
+
+// describeReviewers lists the team reviewers by review status (ex: approved: john; pending: jane)
+func describeReviewers(pullRequest pullRequestData) string {
+	parts := []string{}
+	for _, status := range []struct {
+		label string
+		names []string
+	}{
+		{"approved", pullRequest.ApprovedBy},
+		{"changes requested", pullRequest.RequestedChangesBy},
+		{"pending", pullRequest.PendingReviewers},
+		{"absent", pullRequest.AbsentReviewers},
+	} {
+		if len(status.names) > 0 {
+			parts = append(parts, status.label+": "+strings.Join(status.names, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ANSI escape codes used to colorize the terminal report
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiFaint  = "\x1b[2m"
+)
+
+// terminalCell is a cell of the terminal report's tables. Its color is not counted in the width of the column
+type terminalCell struct {
+	text  string
+	color string
+}
+
+// maxTerminalTitleLength is the number of characters after which titles are truncated in the terminal report
+const maxTerminalTitleLength = 60
+
+func renderTerminalReport(data reportData, color bool) []byte {
+	buffer := &bytes.Buffer{}
+	colorize := func(text, code string) string {
+		if !color || code == "" {
+			return text
+		}
+		return code + text + ansiReset
+	}
+
+	fmt.Fprintln(buffer, colorize(fmt.Sprintf("Pull requests of %s", data.Team), ansiBold))
+	if len(data.Categories) == 0 {
+		fmt.Fprintln(buffer, "\nNo pull requests need action")
+	}
+	for _, category := range data.Categories {
+		categoryColor := ""
+		for _, reportCategory := range reportCategories {
+			if reportCategory.category == category.Category {
+				categoryColor = reportCategory.color
+			}
+		}
+		fmt.Fprintf(buffer, "\n%s\n", colorize(fmt.Sprintf("%s (%d)", category.Title, len(category.PullRequests)), ansiBold+categoryColor))
+
+		rows := [][]terminalCell{{{"REPOSITORY", ansiFaint}, {"PULL REQUEST", ansiFaint}, {"AUTHOR", ansiFaint}, {"AGE", ansiFaint}, {"APPROVALS", ansiFaint}, {"REVIEWERS", ansiFaint}, {"LINK", ansiFaint}}}
+		for _, pullRequest := range category.PullRequests {
+			title := pullRequest.Title
+			if utf8.RuneCountInString(title) > maxTerminalTitleLength {
+				title = string([]rune(title)[:maxTerminalTitleLength-3]) + "..."
+			}
+			approvalsColor := ansiYellow
+			if pullRequest.Approvals >= pullRequest.NeededApprovals {
+				approvalsColor = ansiGreen
+			}
+			rows = append(rows, []terminalCell{
+				{pullRequest.Repository.Name, ""},
+				{title, ""},
+				{pullRequest.Author.Name, ""},
+				{utilities.HumanizeDuration(pullRequest.Age), ""},
+				{fmt.Sprintf("%d/%d", pullRequest.Approvals, pullRequest.NeededApprovals), approvalsColor},
+				{describeReviewers(pullRequest), ""},
+				{pullRequest.Link, ansiFaint},
+			})
+		}
+		writeTerminalTable(buffer, rows, colorize)
+	}
+	if data.Ignored != "" {
+		fmt.Fprintf(buffer, "\n%s\n", colorize(data.Ignored, ansiFaint))
+	}
+	return buffer.Bytes()
+}
+
+// writeTerminalTable pads the cells to align the columns. The widths are computed without the colors,
+// which is why text/tabwriter is not used
+func writeTerminalTable(writer io.Writer, rows [][]terminalCell, colorize func(text, code string) string) {
+	widths := []int{}
+	for _, row := range rows {
+		for index, cell := range row {
+			if index >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if width := utf8.RuneCountInString(cell.text); width > widths[index] {
+				widths[index] = width
+			}
+		}
+	}
+	for _, row := range rows {
+		line := ""
+		for index, cell := range row {
+			line += colorize(cell.text, cell.color)
+			if index < len(row)-1 {
+				line += strings.Repeat(" ", widths[index]-utf8.RuneCountInString(cell.text)+2)
+			}
+		}
+		fmt.Fprintln(writer, line)
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "\n", " ")
+
+// markdownLinkEscaper percent-encodes the characters ending a link's destination or a table cell
+var markdownLinkEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20", "|", "%7C", "<", "%3C", ">", "%3E", "\n", "")
+
+func renderMarkdownReport(data reportData) []byte {
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "# Pull requests of %s\n\n", markdownEscaper.Replace(data.Team))
+	fmt.Fprintf(buffer, "_Generated on %s_\n", data.GeneratedAt.Format("2006-01-02 15:04 MST"))
+	if len(data.Categories) == 0 {
+		fmt.Fprintln(buffer, "\nNo pull requests need action.")
+	}
+	for _, category := range data.Categories {
+		fmt.Fprintf(buffer, "\n## %s (%d)\n\n", category.Title, len(category.PullRequests))
+		fmt.Fprintln(buffer, "| Repository | Pull request | Author | Age | Approvals | Reviewers |")
+		fmt.Fprintln(buffer, "| --- | --- | --- | --- | --- | --- |")
+		for _, pullRequest := range category.PullRequests {
+			fmt.Fprintf(buffer, "| [%s](%s) | [%s](%s) | %s | %s | %d/%d | %s |\n",
+				markdownEscaper.Replace(pullRequest.Repository.Name), markdownLinkEscaper.Replace(pullRequest.Repository.Link),
+				markdownEscaper.Replace(pullRequest.Title), markdownLinkEscaper.Replace(pullRequest.Link),
+				markdownEscaper.Replace(pullRequest.Author.Name),
+				utilities.HumanizeDuration(pullRequest.Age),
+				pullRequest.Approvals, pullRequest.NeededApprovals,
+				markdownEscaper.Replace(describeReviewers(pullRequest)),
+			)
+		}
+	}
+	if data.Ignored != "" {
+		fmt.Fprintf(buffer, "\n%s\n", markdownEscaper.Replace(data.Ignored))
+	}
+	return buffer.Bytes()
+}
+
+var htmlReportTemplate = htmltemplate.Must(htmltemplate.New("report").Funcs(htmltemplate.FuncMap{
+	"describeReviewers": describeReviewers,
+	"humanizeDuration":  utilities.HumanizeDuration,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Pull requests of {{ .Team }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #24292e; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.4em 0.8em; border-bottom: 1px solid #e1e4e8; }
+th { background: #f6f8fa; }
+.generated, .ignored { color: #6a737d; }
+.ready_to_merge { color: #22863a; }
+.ready_to_review { color: #b08800; }
+.changes_requested { color: #cb2431; }
+.enough { color: #22863a; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Pull requests of {{ .Team }}</h1>
+<p class="generated">Generated on {{ .GeneratedAt.Format "2006-01-02 15:04 MST" }}</p>
+{{ range .Categories }}
+<h2 class="{{ .Category }}">{{ .Title }} ({{ len .PullRequests }})</h2>
+<table>
+<tr><th>Repository</th><th>Pull request</th><th>Author</th><th>Age</th><th>Approvals</th><th>Reviewers</th></tr>
+{{ range .PullRequests }}<tr>
+<td><a href="{{ .Repository.Link }}">{{ .Repository.Name }}</a></td>
+<td><a href="{{ .Link }}">{{ .Title }}</a></td>
+<td>{{ .Author.Name }}</td>
+<td>{{ humanizeDuration .Age }}</td>
+<td{{ if ge .Approvals .NeededApprovals }} class="enough"{{ end }}>{{ .Approvals }}/{{ .NeededApprovals }}</td>
+<td>{{ describeReviewers . }}</td>
+</tr>
+{{ end }}</table>
+{{ else }}
+<p>No pull requests need action.</p>
+{{ end }}
+{{ with .Ignored }}<p class="ignored">{{ . }}</p>{{ end }}
+</body>
+</html>
+`))
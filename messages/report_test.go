@@ -0,0 +1,148 @@
+package messages
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReportTestRepositories(ctrl *gomock.Controller) []hosts.Repository {
+	mockHost := hosts.NewMockHost(ctrl)
+	mockHost.EXPECT().GetName().Return("Github").AnyTimes()
+
+	now := time.Now()
+	mockRepository := newIgnoredTestRepository(ctrl, hosts.WIPReason)
+	mockRepository.EXPECT().GetHost().Return(mockHost).AnyTimes()
+	mockRepository.EXPECT().GetLink().Return("repo.com").AnyTimes()
+	mockRepository.EXPECT().GetName().Return("repo").AnyTimes()
+	mockRepository.EXPECT().HasPullRequestsToDisplay().Return(true).AnyTimes()
+	mockRepository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*hosts.PullRequest{
+			{
+				Title: "merge me", Link: "merge.com", Author: config.User{Name: "author"}, CreateTime: now.Add(-2 * time.Hour),
+				Reviewers: []*hosts.Reviewer{{User: config.User{Name: "reviewer"}, Approved: true}},
+			},
+		},
+		[]*hosts.PullRequest{
+			{Title: "young", Link: "young.com", Author: config.User{Name: "author"}, CreateTime: now.Add(-time.Hour)},
+			{
+				Title: "old | [pipes]", Link: "old.com/(pull) 1", Author: config.User{Name: "author"}, CreateTime: now.Add(-72 * time.Hour),
+				Reviewers: []*hosts.Reviewer{{User: config.User{Name: "reviewer"}}, {User: config.User{Name: "other"}, Absent: true}},
+			},
+		},
+		[]*hosts.PullRequest{}).AnyTimes()
+	return []hosts.Repository{mockRepository}
+}
+
+func newTestReportMessageHandler(format string) (*reportMessageHandler, *bytes.Buffer) {
+	stdout := &bytes.Buffer{}
+	return &reportMessageHandler{
+		teamName:        "my-team",
+		format:          format,
+		sort:            config.SortByAge,
+		neededApprovals: 1,
+		stdout:          stdout,
+	}, stdout
+}
+
+func TestNewReportData(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler, _ := newTestReportMessageHandler(terminalReportFormat)
+	data := handler.newReportData(newReportTestRepositories(ctrl), time.Now())
+	assert.Equal(t, "my-team", data.Team)
+	assert.Len(t, data.Categories, 2) // There are no pull requests with changes requested
+	assert.Equal(t, "Ready to merge", data.Categories[0].Title)
+	assert.Equal(t, "In need of approvers", data.Categories[1].Title)
+	assert.Equal(t, "old | [pipes]", data.Categories[1].PullRequests[0].Title) // Sorted by age
+	assert.Equal(t, "pending: reviewer; absent: other", describeReviewers(data.Categories[1].PullRequests[0]))
+	assert.Equal(t, "1 pull request ignored: 1 WIP", data.Ignored)
+}
+
+func TestNotifyTerminalReport(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler, stdout := newTestReportMessageHandler(terminalReportFormat)
+	assert.Nil(t, handler.Notify(newReportTestRepositories(ctrl)))
+	lines := strings.Split(stdout.String(), "\n")
+	assert.Equal(t, "Pull requests of my-team", lines[0])
+	assert.Equal(t, "Ready to merge (1)", lines[2])
+	assert.Equal(t, "REPOSITORY  PULL REQUEST  AUTHOR  AGE      APPROVALS  REVIEWERS           LINK", lines[3])
+	assert.Equal(t, "repo        merge me      author  2 hours  1/1        approved: reviewer  merge.com", lines[4])
+	assert.Equal(t, "In need of approvers (2)", lines[6])
+	assert.NotContains(t, stdout.String(), "\x1b[")
+
+	handler, stdout = newTestReportMessageHandler(terminalReportFormat)
+	handler.color = true
+	assert.Nil(t, handler.Notify(newReportTestRepositories(ctrl)))
+	assert.Contains(t, stdout.String(), ansiBold+ansiGreen+"Ready to merge (1)"+ansiReset)
+	assert.Contains(t, stdout.String(), ansiGreen+"1/1"+ansiReset+"        ") // Colors don't change the alignment
+}
+
+func TestIsTerminal(t *testing.T) {
+	t.Parallel()
+	file, err := ioutil.TempFile("", "report")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	assert.False(t, isTerminal(file))
+}
+
+func TestNotifyMarkdownReport(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler, _ := newTestReportMessageHandler(markdownReportFormat)
+	directory, err := ioutil.TempDir("", "report")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	handler.path = filepath.Join(directory, "report.md")
+
+	assert.Nil(t, handler.Notify(newReportTestRepositories(ctrl)))
+	report, err := ioutil.ReadFile(handler.path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(report), "# Pull requests of my-team\n")
+	assert.Contains(t, string(report), "## In need of approvers (2)\n\n| Repository | Pull request | Author | Age | Approvals | Reviewers |\n")
+	assert.Contains(t, string(report), `| [repo](repo.com) | [old \| \[pipes\]](old.com/%28pull%29%201) | author | 3 days | 0/1 | pending: reviewer; absent: other |`)
+	assert.Contains(t, string(report), "\n1 pull request ignored: 1 WIP\n")
+}
+
+func TestNotifyHTMLReport(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler, _ := newTestReportMessageHandler(htmlReportFormat)
+	dryRun := &DryRun{Writer: &bytes.Buffer{}}
+	handler.dryRun = dryRun
+	assert.Nil(t, handler.Notify(newReportTestRepositories(ctrl)))
+	report := dryRun.Writer.(*bytes.Buffer).String()
+	assert.True(t, strings.HasPrefix(report, "<!DOCTYPE html>"))
+	assert.Contains(t, report, "<title>Pull requests of my-team</title>")
+	assert.Contains(t, report, `<h2 class="ready_to_merge">Ready to merge (1)</h2>`)
+	assert.Contains(t, report, `<a href="old.com/%28pull%29%201">old | [pipes]</a>`)
+	assert.Contains(t, report, `<td class="enough">1/1</td>`)
+	assert.Contains(t, report, `<p class="ignored">1 pull request ignored: 1 WIP</p>`)
+
+	handler.dryRun = nil
+	handler.stdout = &bytes.Buffer{}
+	emptyRepository := newIgnoredTestRepository(ctrl)
+	emptyRepository.EXPECT().HasPullRequestsToDisplay().Return(false).AnyTimes()
+	assert.Nil(t, handler.Notify([]hosts.Repository{emptyRepository}))
+	assert.Contains(t, handler.stdout.(*bytes.Buffer).String(), "No pull requests need action.")
+}
@@ -3,166 +3,706 @@ package messages
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/julienduchesne/pull-request-reminder/config"
 	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
 	"github.com/nlopes/slack"
 	log "github.com/sirupsen/logrus"
 )
 
-const headerText = "Hello, here are the pull requests requiring your attention today:"
+// slackHandlerName is the name of the Slack handler in metrics
+const slackHandlerName = "slack"
+
+// slackMessageNotFoundError is the error returned by Slack when updating or deleting a message that doesn't exist
+const slackMessageNotFoundError = "message_not_found"
+
+// noPullRequestsText replaces the channel message in update mode when no pull requests need action anymore
+const noPullRequestsText = "No pull requests need action :tada:"
+
+const (
+	newMessageMode    = "new"
+	updateMessageMode = "update"
+	threadMessageMode = "thread"
+)
 
 type slackClient interface {
 	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	GetUserByEmail(email string) (*slack.User, error)
+	GetUsers() ([]slack.User, error)
+	DeleteMessage(channelID, timestamp string) (string, string, error)
+}
+
+// slackPostedMessage is the persisted reference to the last message posted to a channel
+type slackPostedMessage struct {
+	ChannelID              string
+	Timestamp              string
+	ContinuationTimestamps []string
+	PostedAt               time.Time
+}
+
+// slackUserMessage is the persisted record of the last individual message sent to a user
+type slackUserMessage struct {
+	SentAt time.Time
 }
 
 type slackMessageHandler struct {
 	channel      string
 	messageUsers bool
 	client       slackClient
+	teamName     string
+	templates    *messageTemplates
+
+	neededApprovals int
+	users           *slackUserResolver
+
+	channelMessageMode string
+	rolloverPeriod     time.Duration
+	store              state.Store
+
+	interactive bool
+
+	mentionPendingReviewers bool
+	unassignedUserGroups    []string
+
+	sort           string
+	channelGroupBy string
+	userGroupBy    string
+
+	// teamConfig is used to get the users' schedules and absences. Users are only messaged individually when available
+	teamConfig        *config.TeamConfig
+	userMessagePeriod time.Duration
+	awayStatusEmojis  []string
+
+	showIgnored bool
+
+	// escalations are the team's escalation steps, sorted by delay
+	escalations []config.EscalationRule
 
 	debugUser string
 }
 
-func (handler *slackMessageHandler) sendMessage(destination string, blocks []slack.Block) error {
+func (handler *slackMessageHandler) sendMessage(destination string, blocks []slack.Block, options ...slack.MsgOption) (string, string, error) {
 	blocksJSON, _ := json.Marshal(blocks)
 	log.Debugf("Sent the following message to %s:\n %s", destination, string(blocksJSON))
-	_, _, err := handler.client.PostMessage(destination, slack.MsgOptionAsUser(true), slack.MsgOptionBlocks(blocks...))
+	channelID, timestamp, err := handler.client.PostMessage(destination, append([]slack.MsgOption{slack.MsgOptionAsUser(true), slack.MsgOptionBlocks(blocks...)}, options...)...)
+	if err == nil {
+		messagesSent.WithLabelValues(slackHandlerName).Inc()
+	}
+	return channelID, timestamp, err
+}
+
+// sendMessages sends the first message to the given destination and the following ones in its thread.
+// It returns the channel ID and the timestamps of all sent messages
+func (handler *slackMessageHandler) sendMessages(destination string, messages [][]slack.Block, options ...slack.MsgOption) (string, []string, error) {
+	var (
+		channelID  string
+		timestamps []string
+	)
+	for index, blocks := range messages {
+		messageOptions := options
+		if index > 0 {
+			destination = channelID
+			messageOptions = append(messageOptions, slack.MsgOptionTS(timestamps[0]))
+		}
+		sentChannelID, timestamp, err := handler.sendMessage(destination, blocks, messageOptions...)
+		if err != nil {
+			return channelID, timestamps, err
+		}
+		if index == 0 {
+			channelID = sentChannelID
+		}
+		timestamps = append(timestamps, timestamp)
+	}
+	return channelID, timestamps, nil
+}
+
+// getCurrentChannelMessage returns the last message posted to the channel if it is more recent than the rollover period
+func (handler *slackMessageHandler) getCurrentChannelMessage() (*slackPostedMessage, bool, error) {
+	lastMessage := &slackPostedMessage{}
+	found, err := handler.store.Get(handler.channelMessageKey(), lastMessage)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return lastMessage, time.Since(lastMessage.PostedAt) < handler.rolloverPeriod, nil
+}
+
+func (handler *slackMessageHandler) channelMessageKey() string {
+	return fmt.Sprintf("slack/%s/%s", handler.teamName, handler.channel)
+}
+
+// sendChannelMessage posts, updates or replies to the channel's last message depending on the configured mode.
+// When the message is split, the continuation messages are posted in the thread of the first one
+func (handler *slackMessageHandler) sendChannelMessage(messages [][]slack.Block) error {
+	if handler.channelMessageMode == newMessageMode {
+		_, _, err := handler.sendMessages(handler.channel, messages)
+		return err
+	}
+
+	lastMessage, current, err := handler.getCurrentChannelMessage()
+	if err != nil {
+		return err
+	}
+
+	if current {
+		if handler.channelMessageMode == threadMessageMode {
+			for _, blocks := range messages {
+				if _, _, err = handler.sendMessage(lastMessage.ChannelID, blocks, slack.MsgOptionTS(lastMessage.Timestamp)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		updated, err := handler.updateChannelMessage(lastMessage, messages)
+		if err != nil || updated {
+			return err
+		}
+		// The message was deleted from the channel, a new one is posted instead
+	}
+
+	channelID, timestamps, err := handler.sendMessages(handler.channel, messages)
+	if err != nil {
+		return err
+	}
+	return handler.store.Set(handler.channelMessageKey(), &slackPostedMessage{
+		ChannelID:              channelID,
+		Timestamp:              timestamps[0],
+		ContinuationTimestamps: timestamps[1:],
+		PostedAt:               time.Now(),
+	})
+}
+
+// updateChannelMessage replaces the content of the channel's last message and of its continuation messages.
+// It returns false, without error, if the first message was deleted from the channel and can't be updated anymore
+func (handler *slackMessageHandler) updateChannelMessage(lastMessage *slackPostedMessage, messages [][]slack.Block) (bool, error) {
+	previousTimestamps := append([]string{lastMessage.Timestamp}, lastMessage.ContinuationTimestamps...)
+	lastMessage.ContinuationTimestamps = []string{}
+	for index, blocks := range messages {
+		if index < len(previousTimestamps) {
+			blocksJSON, _ := json.Marshal(blocks)
+			log.Debugf("Updated the message %s in %s with the following message:\n %s", previousTimestamps[index], handler.channel, string(blocksJSON))
+			_, _, _, err := handler.client.UpdateMessage(lastMessage.ChannelID, previousTimestamps[index], slack.MsgOptionAsUser(true), slack.MsgOptionBlocks(blocks...))
+			if err == nil {
+				messagesSent.WithLabelValues(slackHandlerName).Inc()
+				if index > 0 {
+					lastMessage.ContinuationTimestamps = append(lastMessage.ContinuationTimestamps, previousTimestamps[index])
+				}
+				continue
+			}
+			if !isMessageNotFound(err) {
+				return false, err
+			}
+			if index == 0 {
+				log.Warningf("The message %s was deleted from %s, posting a new one", previousTimestamps[index], handler.channel)
+				return false, nil
+			}
+			// A deleted continuation message is posted again in the thread
+		}
+		_, timestamp, err := handler.sendMessage(lastMessage.ChannelID, blocks, slack.MsgOptionTS(lastMessage.Timestamp))
+		if err != nil {
+			return false, err
+		}
+		lastMessage.ContinuationTimestamps = append(lastMessage.ContinuationTimestamps, timestamp)
+	}
+	for index := len(messages); index < len(previousTimestamps); index++ {
+		// The message is shorter than before, remove the continuation messages that are not needed anymore
+		if _, _, err := handler.client.DeleteMessage(lastMessage.ChannelID, previousTimestamps[index]); err != nil && !isMessageNotFound(err) {
+			return false, err
+		}
+	}
+	return true, handler.store.Set(handler.channelMessageKey(), lastMessage)
+}
+
+// clearChannelMessage updates the channel's current message, in update mode, when no pull requests need action anymore.
+// Otherwise, the message would keep listing the pull requests that were merged since
+func (handler *slackMessageHandler) clearChannelMessage() error {
+	lastMessage, current, err := handler.getCurrentChannelMessage()
+	if err != nil || !current {
+		return err
+	}
+	_, err = handler.updateChannelMessage(lastMessage, [][]slack.Block{{newSlackTextSection("mrkdwn", noPullRequestsText, false)}})
 	return err
 }
 
-func (handler *slackMessageHandler) Notify(repositoriesNeedingAction []hosts.Repository) error {
+// isMessageNotFound returns true if Slack's error means that the message doesn't exist, usually because it was deleted
+func isMessageNotFound(err error) bool {
+	return err.Error() == slackMessageNotFoundError
+}
+
+func (handler *slackMessageHandler) Notify(repositories []hosts.Repository) error {
+	now := time.Now()
+	repositoriesNeedingAction := hosts.GetRepositoriesNeedingAction(repositories)
+	continuationSection, err := handler.buildContinuationSection()
+	if err != nil {
+		return err
+	}
+	filter := newDeltaFilter(handler.teamConfig, handler.store, now)
+
 	if handler.channel != "" {
-		sections := buildChannelSlackMessage(repositoriesNeedingAction)
-		if err := handler.sendMessage(handler.channel, sections); err != nil {
-			return err
+		if len(repositoriesNeedingAction) == 0 && handler.channelMessageMode == updateMessageMode {
+			if err := handler.clearChannelMessage(); err != nil {
+				return err
+			}
+		} else {
+			message, pullRequests, err := handler.buildChannelNotification(repositoriesNeedingAction, filter)
+			if err != nil {
+				return err
+			}
+			if len(message) <= 1 {
+				// Only the header: no pull requests need action or, in delta mode, none changed since the last run
+				log.Infof("No pull requests to send to %s", handler.channel)
+			} else {
+				if handler.showIgnored {
+					if message, err = handler.withIgnoredSection(message, repositories); err != nil {
+						return err
+					}
+				}
+				if err := handler.sendChannelMessage(message.split(continuationSection)); err != nil {
+					return err
+				}
+			}
+			if err := recordNotifications(handler.store, handler.channelMessageKey(), pullRequests, now); err != nil {
+				return err
+			}
 		}
 	}
 
 	if handler.messageUsers {
-		for user, sections := range buildUserSlackMessages(repositoriesNeedingAction) {
-			if handler.debugUser != "" {
-				sections = append([]slack.Block{
-					slack.NewDividerBlock(),
-					slack.NewSectionBlock(slack.NewTextBlockObject("plain_text", fmt.Sprintf("Would've sent to %s", user), false, false), nil, nil),
-				}, sections...)
-				user = handler.debugUser
+		messagePerUser, pullRequestsPerUser, err := handler.buildUserNotifications(repositoriesNeedingAction, filter, now)
+		if err != nil {
+			return err
+		}
+		for user, pullRequests := range pullRequestsPerUser {
+			if message, ok := messagePerUser[user]; ok {
+				if err := handler.sendUserMessage(user, message, continuationSection); err != nil {
+					return err
+				}
 			}
-			if err := handler.sendMessage(user, sections); err != nil {
-				return err
+			if handler.debugUser == "" {
+				if _, ok := messagePerUser[user]; ok {
+					if err := handler.store.Set(handler.userMessageKey(user), &slackUserMessage{SentAt: now}); err != nil {
+						return err
+					}
+				}
+				if err := recordNotifications(handler.store, handler.userMessageKey(user), pullRequests, now); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
-	return nil
+	return handler.sendEscalations(repositories)
+}
+
+// sendUserMessage sends a message to the given user. In debug mode, the message is sent to the debug user instead
+func (handler *slackMessageHandler) sendUserMessage(user string, message slackMessage, continuationSection slack.Block) error {
+	if handler.debugUser != "" {
+		message = append(slackMessage{{blocks: []slack.Block{
+			slack.NewDividerBlock(),
+			newSlackTextSection("plain_text", fmt.Sprintf("Would've sent to %s", user), false),
+		}}}, message...)
+		user = handler.debugUser
+	}
+	_, _, err := handler.sendMessages(user, message.split(continuationSection))
+	return err
 }
 
-func newSlackMessageHandler(config *config.TeamConfig) *slackMessageHandler {
+func newSlackMessageHandler(config *config.TeamConfig, store state.Store, dryRun *DryRun) (*slackMessageHandler, error) {
 	slackConfig := config.Messaging.Slack
+	channelMessageMode := slackConfig.ChannelMessageMode
+	switch channelMessageMode {
+	case "":
+		channelMessageMode = newMessageMode
+	case newMessageMode, updateMessageMode, threadMessageMode:
+	default:
+		return nil, fmt.Errorf("Invalid Slack channel message mode %q. Valid modes are: %s, %s and %s", channelMessageMode, newMessageMode, updateMessageMode, threadMessageMode)
+	}
+	sort := utilities.FirstNonEmpty(slackConfig.Sort, config.Messaging.Sort)
+	channelGroupBy := utilities.FirstNonEmpty(slackConfig.ChannelGroupBy, config.Messaging.GroupBy, groupByRepository)
+	userGroupBy := utilities.FirstNonEmpty(slackConfig.UserGroupBy, config.Messaging.GroupBy, groupByRepository)
+	if err := validateSort(sort); err != nil {
+		return nil, err
+	}
+	for _, groupBy := range []string{channelGroupBy, userGroupBy} {
+		if err := validateGroupBy(groupBy); err != nil {
+			return nil, err
+		}
+	}
+	templates, err := newMessageTemplates(config.Messaging.Templates, slackConfig.Templates)
+	if err != nil {
+		return nil, err
+	}
+	var client slackClient = slack.New(slackConfig.Token)
+	if dryRun != nil {
+		client = newSlackDryRunClient(slackConfig.Token, config.Name, dryRun)
+	}
 	return &slackMessageHandler{
 		channel:      slackConfig.Channel,
 		debugUser:    slackConfig.DebugUser,
 		messageUsers: slackConfig.MessageUsersIndividually,
-		client:       slack.New(slackConfig.Token),
-	}
+		client:       client,
+		teamName:     config.Name,
+		templates:    templates,
+
+		neededApprovals: config.GetNumberOfNeededApprovals(),
+		users:           newSlackUserResolver(client, slackConfig.ResolveUsers),
+
+		channelMessageMode: channelMessageMode,
+		rolloverPeriod:     slackConfig.GetRolloverPeriod(),
+		store:              store,
+
+		interactive: slackConfig.Interactive,
+
+		mentionPendingReviewers: slackConfig.MentionPendingReviewers,
+		unassignedUserGroups:    slackConfig.UnassignedUserGroups,
+
+		sort:           sort,
+		channelGroupBy: channelGroupBy,
+		userGroupBy:    userGroupBy,
+
+		teamConfig:        config,
+		userMessagePeriod: slackConfig.UserMessagePeriod,
+		awayStatusEmojis:  slackConfig.AwayStatusEmojis,
+
+		showIgnored: slackConfig.ShowIgnored,
+
+		escalations: sortEscalations(config.Escalations),
+	}, nil
 }
 
-func buildChannelSlackMessage(repositoriesNeedingAction []hosts.Repository) []slack.Block {
-	headerTextBlock := slack.NewTextBlockObject("plain_text", headerText, false, false)
+func (handler *slackMessageHandler) buildHeaderSection(repositories []hosts.Repository) (slack.Block, error) {
+	data := headerData{Team: handler.teamName}
+	for _, repository := range repositories {
+		data.Repositories = append(data.Repositories, newRepositoryData(repository))
+		readyToMerge, readyToReview, changesRequested := repository.GetPullRequestsToDisplay()
+		data.PullRequestCount += len(readyToMerge) + len(readyToReview) + len(changesRequested)
+	}
+	text, err := render(handler.templates.header, data)
+	if err != nil {
+		return nil, err
+	}
+	return newSlackTextSection("plain_text", text, false), nil
+}
 
-	sections := []slack.Block{slack.NewSectionBlock(headerTextBlock, nil, nil)}
-	for _, repository := range repositoriesNeedingAction {
+// withIgnoredSection ends the message with the number of ignored pull requests of the given repositories, if there are any
+func (handler *slackMessageHandler) withIgnoredSection(message slackMessage, repositories []hosts.Repository) (slackMessage, error) {
+	data := newIgnoredData(handler.teamName, repositories)
+	if data.Count == 0 {
+		return message, nil
+	}
+	text, err := render(handler.templates.ignored, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(message, slackBlockGroup{blocks: []slack.Block{slack.NewDividerBlock(), newSlackTextSection("mrkdwn", text, false)}}), nil
+}
 
-		titleBlock := slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("[%v] *<%v|%v>*", repository.GetHost().GetName(), repository.GetLink(), repository.GetName()), false, false),
-			nil, nil,
-		)
-		sections = append(sections,
-			slack.NewDividerBlock(),
-			titleBlock,
-		)
+func (handler *slackMessageHandler) buildContinuationSection() (slack.Block, error) {
+	text, err := render(handler.templates.continued, headerData{Team: handler.teamName})
+	if err != nil {
+		return nil, err
+	}
+	return newSlackTextSection("plain_text", text, false), nil
+}
 
-		readyToMerge, readyToReview := repository.GetPullRequestsToDisplay()
-		sections = append(sections, getPullRequestSections(":heavy_check_mark: Pull requests awaiting merge", true, readyToMerge)...)
-		sections = append(sections, getPullRequestSections(":no_entry: Pull requests still in need of approvers", false, readyToReview)...)
+func (handler *slackMessageHandler) buildRepositorySection(repository hosts.Repository) (slack.Block, error) {
+	text, err := render(handler.templates.repository, newRepositoryData(repository))
+	if err != nil {
+		return nil, err
 	}
+	return newSlackTextSection("mrkdwn", text, false), nil
+}
 
-	return sections
+// buildGroupSection returns the title of the given group: the repository's title or the group template
+func (handler *slackMessageHandler) buildGroupSection(group pullRequestGroup, groupBy string) (slack.Block, error) {
+	if group.repository != nil {
+		return handler.buildRepositorySection(group.repository)
+	}
+	text, err := render(handler.templates.group, groupData{Team: handler.teamName, GroupBy: groupBy, Name: group.name})
+	if err != nil {
+		return nil, err
+	}
+	return newSlackTextSection("mrkdwn", text, false), nil
 }
 
-func buildUserSlackMessages(repositoriesNeedingAction []hosts.Repository) map[string][]slack.Block {
-	headerTextBlock := slack.NewTextBlockObject("plain_text", headerText, false, false)
+// buildGroupedSlackMessage returns the message listing the given pull requests, sorted and grouped according to the configuration
+func (handler *slackMessageHandler) buildGroupedSlackMessage(repositoriesNeedingAction []hosts.Repository, pullRequests []categorizedPullRequest, groupBy string, channel bool) (slackMessage, error) {
+	header, err := handler.buildHeaderSection(repositoriesNeedingAction)
+	if err != nil {
+		return nil, err
+	}
 
-	messagePerUser := map[string][]slack.Block{}
-	for _, repository := range repositoriesNeedingAction {
-		readyToMerge, readyToReview := repository.GetPullRequestsToDisplay()
-		readyToMergeByUser, readyToReviewByUser := map[string][]*hosts.PullRequest{}, map[string][]*hosts.PullRequest{}
-		for _, pullRequest := range readyToMerge {
-			author := pullRequest.Author.SlackUsername
-			readyToMergeByUser[author] = append(readyToMergeByUser[author], pullRequest)
+	message := slackMessage{{blocks: []slack.Block{header}}}
+	for _, group := range groupPullRequests(pullRequests, groupBy, handler.isPendingReviewer) {
+		groupSection, err := handler.buildGroupSection(group, groupBy)
+		if err != nil {
+			return nil, err
 		}
-		for _, pullRequest := range readyToReview {
-			for _, reviewer := range pullRequest.Reviewers {
-				username := reviewer.User.SlackUsername
-				if !reviewer.Approved && reviewer.User.SlackUsername != "" {
-					readyToReviewByUser[username] = append(readyToReviewByUser[username], pullRequest)
-				}
+		groupMessage := slackMessage{}
+		for _, category := range categories {
+			// In the channel, authors are mentioned when they have to act and reviewers when they are asked to
+			linkAuthor := channel && category != readyToReviewCategory
+			mentionReviewers := channel && category == readyToReviewCategory
+			categoryMessage, err := handler.getPullRequestSections(group, groupSection, category, linkAuthor, mentionReviewers, group.inCategory(category))
+			if err != nil {
+				return nil, err
 			}
+			groupMessage = append(groupMessage, categoryMessage...)
 		}
+		message = append(message, withGroupTitle(groupMessage, groupSection)...)
+	}
 
-		usersInit := map[string]bool{}
-		var initRepositoryMessage = func(user string) {
-			if _, ok := messagePerUser[user]; !ok {
-				messagePerUser[user] = []slack.Block{slack.NewSectionBlock(headerTextBlock, nil, nil)}
+	return message, nil
+}
+
+// getPullRequests returns the sorted pull requests of the given repositories
+func (handler *slackMessageHandler) getPullRequests(repositoriesNeedingAction []hosts.Repository) []categorizedPullRequest {
+	pullRequests := getCategorizedPullRequests(repositoriesNeedingAction)
+	sortPullRequests(pullRequests, handler.sort)
+	return pullRequests
+}
+
+func (handler *slackMessageHandler) buildChannelSlackMessage(repositoriesNeedingAction []hosts.Repository) (slackMessage, error) {
+	message, _, err := handler.buildChannelNotification(repositoriesNeedingAction, newDeltaFilter(handler.teamConfig, handler.store, time.Now()))
+	return message, err
+}
+
+// buildChannelNotification returns the channel message, with the pull requests kept by the delta filter, and all pull requests
+// the channel is notified about, including those that didn't change since they were last notified
+func (handler *slackMessageHandler) buildChannelNotification(repositoriesNeedingAction []hosts.Repository, filter *deltaFilter) (slackMessage, []categorizedPullRequest, error) {
+	pullRequests := handler.getPullRequests(repositoriesNeedingAction)
+	message, err := handler.buildGroupedSlackMessage(repositoriesNeedingAction, filter.filter(handler.channelMessageKey(), pullRequests), handler.channelGroupBy, true)
+	return message, pullRequests, err
+}
+
+func (handler *slackMessageHandler) buildUserSlackMessages(repositoriesNeedingAction []hosts.Repository) (map[string]slackMessage, error) {
+	now := time.Now()
+	messagePerUser, _, err := handler.buildUserNotifications(repositoriesNeedingAction, newDeltaFilter(handler.teamConfig, handler.store, now), now)
+	return messagePerUser, err
+}
+
+// buildUserNotifications returns the individual messages, with the pull requests kept by the delta filter, and all pull requests
+// each user is notified about. Users who have no changed pull requests are not messaged, and users who can't be messaged
+// at the moment (see canMessageUser) are left out so that they are notified once they are available
+func (handler *slackMessageHandler) buildUserNotifications(repositoriesNeedingAction []hosts.Repository, filter *deltaFilter, now time.Time) (map[string]slackMessage, map[string][]categorizedPullRequest, error) {
+	pullRequestsPerUser := map[string][]categorizedPullRequest{}
+	users := map[string]config.User{}
+	for _, pullRequest := range handler.getPullRequests(repositoriesNeedingAction) {
+		for _, user := range handler.getUsersToMessage(pullRequest) {
+			destination := handler.users.resolve(user).destination()
+			if destination == "" {
+				continue
 			}
-			if !usersInit[user] {
-				titleBlock := slack.NewSectionBlock(
-					slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("[%v] *<%v|%v>*", repository.GetHost().GetName(), repository.GetLink(), repository.GetName()), false, false),
-					nil, nil,
-				)
-				messagePerUser[user] = append(messagePerUser[user],
-					slack.NewDividerBlock(),
-					titleBlock,
-				)
-				usersInit[user] = true
+			if _, ok := users[destination]; !ok {
+				users[destination] = user
 			}
-
+			pullRequestsPerUser[destination] = append(pullRequestsPerUser[destination], pullRequest)
 		}
+	}
 
-		for user, pullRequests := range readyToMergeByUser {
-			initRepositoryMessage(user)
-			messagePerUser[user] = append(messagePerUser[user], getPullRequestSections(":heavy_check_mark: Pull requests awaiting merge", false, pullRequests)...)
+	messagePerUser := map[string]slackMessage{}
+	for destination, userPullRequests := range pullRequestsPerUser {
+		changedPullRequests := filter.filter(handler.userMessageKey(destination), userPullRequests)
+		if len(changedPullRequests) == 0 {
+			continue
+		}
+		if !handler.canMessageUser(users[destination], destination, now) {
+			delete(pullRequestsPerUser, destination)
+			continue
 		}
-		for user, pullRequests := range readyToReviewByUser {
-			initRepositoryMessage(user)
-			messagePerUser[user] = append(messagePerUser[user], getPullRequestSections(":no_entry: Pull requests still in need of approvers", false, pullRequests)...)
+		message, err := handler.buildGroupedSlackMessage(repositoriesNeedingAction, changedPullRequests, handler.userGroupBy, false)
+		if err != nil {
+			return nil, nil, err
 		}
+		messagePerUser[destination] = message
+	}
+	return messagePerUser, pullRequestsPerUser, nil
+}
 
+// getUsersToMessage returns the users who have to act on the pull request: its pending reviewers or its author
+func (handler *slackMessageHandler) getUsersToMessage(pullRequest categorizedPullRequest) []config.User {
+	users := []config.User{}
+	if pullRequest.category == readyToReviewCategory {
+		for _, reviewer := range pullRequest.pullRequest.Reviewers {
+			if !reviewer.Approved && handler.isPendingReviewer(pullRequest.pullRequest, reviewer) {
+				users = append(users, reviewer.User)
+			}
+		}
+	} else {
+		// Authors have to merge their pull requests or address the requested changes
+		users = append(users, pullRequest.pullRequest.Author)
 	}
+	return users
+}
 
-	return messagePerUser
+// withGroupTitle adds a divider and the group's title to the first group of blocks of the group's message
+func withGroupTitle(groupMessage slackMessage, groupSection slack.Block) slackMessage {
+	if len(groupMessage) == 0 {
+		return groupMessage
+	}
+	groupMessage[0].blocks = append([]slack.Block{slack.NewDividerBlock(), groupSection}, groupMessage[0].blocks...)
+	groupMessage[0].context = nil
+	return groupMessage
+}
+
+// newPullRequestData returns the data given to the pull request template, with Slack mentions
+func (handler *slackMessageHandler) newPullRequestData(categorizedPullRequest categorizedPullRequest, linkAuthor bool) pullRequestData {
+	pr := categorizedPullRequest.pullRequest
+	data := newPullRequestData(newRepositoryData(categorizedPullRequest.repository), categorizedPullRequest.category, linkAuthor, handler.neededApprovals, pr, handler.isAbsent)
+	data.Title = truncate(data.Title, maxTitleLength)
+	data.AuthorMention = handler.users.resolve(pr.Author).mention()
+	if pr.State != nil && pr.State.ClaimedBy != "" {
+		data.ClaimedBy = slackUser{ID: pr.State.ClaimedBy}.mention()
+	}
+	return data
 }
 
-func getPullRequestSections(title string, linkAuthor bool, pullRequests []*hosts.PullRequest) []slack.Block {
-	sections := []slack.Block{}
+func (handler *slackMessageHandler) getPullRequestSections(group pullRequestGroup, groupSection slack.Block, category string, linkAuthor, mentionReviewers bool, pullRequests []categorizedPullRequest) (slackMessage, error) {
+	message := slackMessage{}
 	if len(pullRequests) == 0 {
-		return sections
+		return message, nil
 	}
-	pullRequestTitle := slack.NewSectionBlock(
-		slack.NewTextBlockObject("plain_text", title, true, false),
-		nil, nil,
-	)
-	sections = append(sections, pullRequestTitle)
-	for _, pr := range pullRequests {
-		text := fmt.Sprintf("<%v|%v>", pr.Link, pr.Title)
-		if linkAuthor && pr.Author.SlackUsername != "" {
-			text = fmt.Sprintf("%s: %s", pr.Author.SlackUsername, text)
-		}
-		pullRequestBlock := slack.NewSectionBlock(
-			slack.NewTextBlockObject("mrkdwn", text, false, false),
-			nil, nil,
-		)
-		sections = append(sections, pullRequestBlock)
-	}
-	return sections
+
+	data := categoryData{
+		Team:     handler.teamName,
+		Category: category,
+	}
+	if group.repository != nil {
+		data.Repository = newRepositoryData(group.repository)
+	}
+	for _, categorizedPullRequest := range pullRequests {
+		pullRequestData := handler.newPullRequestData(categorizedPullRequest, linkAuthor)
+		pullRequestData.ShowRepository = group.repository == nil
+		if mentionReviewers {
+			pullRequestData.Mentions = handler.getReviewerMentions(categorizedPullRequest.pullRequest)
+		}
+		data.PullRequests = append(data.PullRequests, pullRequestData)
+	}
+
+	title, err := render(handler.templates.categoryTemplate(category), data)
+	if err != nil {
+		return nil, err
+	}
+	pullRequestTitle := newSlackTextSection("plain_text", title, true)
+	for index, pr := range data.PullRequests {
+		text, err := render(handler.templates.pullRequest, pr)
+		if err != nil {
+			return nil, err
+		}
+		group := slackBlockGroup{
+			blocks:  []slack.Block{newSlackTextSection("mrkdwn", text, false)},
+			context: []slack.Block{groupSection, pullRequestTitle},
+		}
+		if index == 0 {
+			// The category title always stays with its first pull request
+			group.blocks = append([]slack.Block{pullRequestTitle}, group.blocks...)
+			group.context = []slack.Block{groupSection}
+		}
+		if handler.interactive {
+			group.blocks = append(group.blocks, getSlackActionsBlock(pr.Link))
+		}
+		message = append(message, group)
+	}
+	return message, nil
+}
+
+// canMessageUser returns true if the user is available and if they were not messaged during the user message period
+func (handler *slackMessageHandler) canMessageUser(user config.User, destination string, now time.Time) bool {
+	if !handler.isAvailable(user, destination, now) {
+		return false
+	}
+	if handler.userMessagePeriod > 0 {
+		lastMessage := &slackUserMessage{}
+		if found, err := handler.store.Get(handler.userMessageKey(destination), lastMessage); err != nil {
+			log.WithError(err).Warningf("Unable to read the last message sent to %s", destination)
+		} else if found && now.Sub(lastMessage.SentAt) < handler.userMessagePeriod {
+			log.Infof("%s was already messaged at %v", destination, lastMessage.SentAt)
+			return false
+		}
+	}
+	return true
+}
+
+// isAvailable returns true if the user is not out of office and if they are available according to their schedule
+func (handler *slackMessageHandler) isAvailable(user config.User, destination string, now time.Time) bool {
+	if handler.teamConfig.IsUserAbsent(user, now) || handler.hasAwayStatus(user) {
+		log.Infof("Not messaging %s since they are out of office", destination)
+		return false
+	}
+	schedule := handler.teamConfig.GetUserSchedule(user)
+	if !schedule.IsAvailable(now) {
+		log.Infof("Deferring the message to %s until %v (%s)", destination, schedule.NextAvailableTime(now), schedule.Timezone)
+		return false
+	}
+	return true
+}
+
+func (handler *slackMessageHandler) userMessageKey(destination string) string {
+	return fmt.Sprintf("slack/%s/users/%s", handler.teamName, destination)
+}
+
+// isPendingReviewer returns true if the given reviewer can be messaged about the pull request, they are not absent and they did not dismiss it
+func (handler *slackMessageHandler) isPendingReviewer(pullRequest *hosts.PullRequest, reviewer *hosts.Reviewer) bool {
+	user := handler.users.resolve(reviewer.User)
+	return user.destination() != "" && !handler.isAbsent(reviewer) && !isDismissedBy(pullRequest, user)
+}
+
+// isAbsent returns true if the reviewer is out of office or has an away status on Slack
+func (handler *slackMessageHandler) isAbsent(reviewer *hosts.Reviewer) bool {
+	return reviewer.Absent || (reviewer.User.Name != "" && handler.hasAwayStatus(reviewer.User))
+}
+
+// hasAwayStatus returns true if the user's Slack status emoji is one of the away status emojis
+func (handler *slackMessageHandler) hasAwayStatus(user config.User) bool {
+	if len(handler.awayStatusEmojis) == 0 {
+		return false
+	}
+	statusEmoji := handler.users.resolve(user).StatusEmoji
+	for _, awayStatusEmoji := range handler.awayStatusEmojis {
+		if statusEmoji != "" && statusEmoji == awayStatusEmoji {
+			return true
+		}
+	}
+	return false
+}
+
+// getReviewerMentions returns the mentions of the team reviewers who have not approved the pull request yet.
+// When there are none left, the unassigned user groups are mentioned instead
+func (handler *slackMessageHandler) getReviewerMentions(pullRequest *hosts.PullRequest) []string {
+	pendingReviewers := []string{}
+	for _, reviewer := range pullRequest.Reviewers {
+		if reviewer.User.Name == "" || reviewer.Approved || handler.isAbsent(reviewer) {
+			continue
+		}
+		user := handler.users.resolve(reviewer.User)
+		if mention := user.mention(); mention != "" && !isDismissedBy(pullRequest, user) {
+			pendingReviewers = append(pendingReviewers, mention)
+		}
+	}
+
+	mentions := []string{}
+	if len(pendingReviewers) == 0 {
+		for _, userGroup := range handler.unassignedUserGroups {
+			mentions = append(mentions, fmt.Sprintf("<!subteam^%s>", userGroup))
+		}
+	} else if handler.mentionPendingReviewers {
+		mentions = pendingReviewers
+	}
+	return mentions
+}
+
+// isDismissedBy returns true if the given user skipped the pull request or if it was claimed by someone else
+func isDismissedBy(pullRequest *hosts.PullRequest, user slackUser) bool {
+	pullRequestState := pullRequest.State
+	if pullRequestState == nil {
+		return false
+	}
+	username := strings.TrimPrefix(user.Username, "@")
+	isClaimed := pullRequestState.ClaimedBy != "" || pullRequestState.ClaimedByUsername != ""
+	return pullRequestState.IsSkippedBy(user.ID, username, pullRequest.UpdateTime) || (isClaimed && !pullRequestState.IsClaimedBy(user.ID, username))
 }
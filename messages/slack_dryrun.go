@@ -0,0 +1,89 @@
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nlopes/slack"
+)
+
+// Actions of the Slack messages rendered in dry-run mode
+const (
+	postAction   = "post"
+	updateAction = "update"
+	deleteAction = "delete"
+)
+
+// slackDryRunPayload is a Slack message rendered in dry-run mode
+type slackDryRunPayload struct {
+	Team    string `json:"team"`
+	Handler string `json:"handler"`
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+	// Timestamp is the updated or deleted message
+	Timestamp       string          `json:"ts,omitempty"`
+	ThreadTimestamp string          `json:"thread_ts,omitempty"`
+	Blocks          json.RawMessage `json:"blocks,omitempty"`
+}
+
+// slackDryRunClient writes the messages to the dry-run output instead of sending them.
+// Users are resolved with the real client, if a token is set
+type slackDryRunClient struct {
+	users  slackClient
+	dryRun *DryRun
+	team   string
+	count  int
+}
+
+func newSlackDryRunClient(token, team string, dryRun *DryRun) *slackDryRunClient {
+	client := &slackDryRunClient{dryRun: dryRun, team: team}
+	if token != "" {
+		client.users = slack.New(token)
+	}
+	return client
+}
+
+func (client *slackDryRunClient) write(action, channelID, timestamp string, options ...slack.MsgOption) error {
+	payload := &slackDryRunPayload{Team: client.team, Handler: slackHandlerName, Action: action, Channel: channelID, Timestamp: timestamp}
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		return err
+	}
+	payload.ThreadTimestamp = values.Get("thread_ts")
+	if blocks := values.Get("blocks"); blocks != "" {
+		payload.Blocks = json.RawMessage(blocks)
+	}
+	payloadJSON, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	return client.dryRun.write(client.team, slackHandlerName, "json", payloadJSON)
+}
+
+func (client *slackDryRunClient) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	client.count++
+	timestamp := fmt.Sprintf("dry-run.%d", client.count)
+	return channelID, timestamp, client.write(postAction, channelID, "", options...)
+}
+
+func (client *slackDryRunClient) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	return channelID, timestamp, "", client.write(updateAction, channelID, timestamp, options...)
+}
+
+func (client *slackDryRunClient) DeleteMessage(channelID, timestamp string) (string, string, error) {
+	return channelID, timestamp, client.write(deleteAction, channelID, timestamp)
+}
+
+func (client *slackDryRunClient) GetUserByEmail(email string) (*slack.User, error) {
+	if client.users == nil {
+		return nil, fmt.Errorf("Slack users can't be resolved without a token")
+	}
+	return client.users.GetUserByEmail(email)
+}
+
+func (client *slackDryRunClient) GetUsers() ([]slack.User, error) {
+	if client.users == nil {
+		return nil, fmt.Errorf("Slack users can't be resolved without a token")
+	}
+	return client.users.GetUsers()
+}
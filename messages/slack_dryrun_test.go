@@ -0,0 +1,96 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func readDryRunPayloads(t *testing.T, output string) []slackDryRunPayload {
+	payloads := []slackDryRunPayload{}
+	decoder := json.NewDecoder(strings.NewReader(output))
+	for decoder.More() {
+		payload := slackDryRunPayload{}
+		assert.Nil(t, decoder.Decode(&payload))
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+func TestSlackDryRun(t *testing.T) {
+	t.Parallel()
+
+	output := &bytes.Buffer{}
+	teamConfig := &config.TeamConfig{Name: "my-team"}
+	teamConfig.Messaging.Slack = config.SlackConfig{Channel: "#my-channel", ChannelMessageMode: updateMessageMode}
+	handler, err := newSlackMessageHandler(teamConfig, state.NewMemoryStore(), &DryRun{Writer: output})
+	assert.Nil(t, err)
+	assert.IsType(t, &slackDryRunClient{}, handler.client)
+
+	// A new message and its continuation in its thread
+	blocks := []slack.Block{newSlackTextSection("plain_text", "Hello", false)}
+	assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks, blocks}))
+	payloads := readDryRunPayloads(t, output.String())
+	assert.Len(t, payloads, 2)
+	assert.Equal(t, "my-team", payloads[0].Team)
+	assert.Equal(t, slackHandlerName, payloads[0].Handler)
+	assert.Equal(t, postAction, payloads[0].Action)
+	assert.Equal(t, "#my-channel", payloads[0].Channel)
+	assert.Equal(t, "", payloads[0].ThreadTimestamp)
+	assert.JSONEq(t, `[{"type": "section", "text": {"type": "plain_text", "text": "Hello"}}]`, string(payloads[0].Blocks))
+	assert.Equal(t, "dry-run.1", payloads[1].ThreadTimestamp)
+
+	// The message is then updated and its continuation deleted
+	output.Reset()
+	assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks}))
+	payloads = readDryRunPayloads(t, output.String())
+	assert.Len(t, payloads, 2)
+	assert.Equal(t, updateAction, payloads[0].Action)
+	assert.Equal(t, "dry-run.1", payloads[0].Timestamp)
+	assert.Equal(t, deleteAction, payloads[1].Action)
+	assert.Equal(t, "dry-run.2", payloads[1].Timestamp)
+	assert.Nil(t, payloads[1].Blocks)
+
+	// Users can't be resolved without a token
+	_, err = handler.client.GetUsers()
+	assert.NotNil(t, err)
+}
+
+func TestDryRunDirectory(t *testing.T) {
+	t.Parallel()
+
+	directory, err := ioutil.TempDir("", "dry-run")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+
+	dryRun := &DryRun{Directory: filepath.Join(directory, "messages")}
+	client := newSlackDryRunClient("", "my team/1", dryRun)
+	_, timestamp, err := client.PostMessage("@user", slack.MsgOptionText("Hello", false))
+	assert.Nil(t, err)
+	assert.Equal(t, "dry-run.1", timestamp)
+	_, _, err = client.PostMessage("#channel", slack.MsgOptionTS(timestamp))
+	assert.Nil(t, err)
+
+	files, err := ioutil.ReadDir(dryRun.Directory)
+	assert.Nil(t, err)
+	fileNames := []string{}
+	for _, file := range files {
+		fileNames = append(fileNames, file.Name())
+	}
+	assert.Equal(t, []string{"001-my_team_1-slack.json", "002-my_team_1-slack.json"}, fileNames)
+
+	payload := slackDryRunPayload{}
+	content, err := ioutil.ReadFile(filepath.Join(dryRun.Directory, fileNames[1]))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(content, &payload))
+	assert.Equal(t, slackDryRunPayload{Team: "my team/1", Handler: slackHandlerName, Action: postAction, Channel: "#channel", ThreadTimestamp: "dry-run.1"}, payload)
+}
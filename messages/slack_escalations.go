@@ -0,0 +1,219 @@
+package messages
+
+import (
+	"sort"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/nlopes/slack"
+)
+
+// escalatedPullRequest is a pull request that reached an escalation step that was not sent yet
+type escalatedPullRequest struct {
+	categorizedPullRequest
+	state *state.EscalationState
+}
+
+// sortEscalations returns a copy of the given escalation steps, sorted by delay
+func sortEscalations(escalations []config.EscalationRule) []config.EscalationRule {
+	sorted := append([]config.EscalationRule{}, escalations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].After < sorted[j].After })
+	return sorted
+}
+
+// sendEscalations sends the escalation steps reached by the pull requests since the last run. A step stays pending until
+// it is sent to all of its recipients: the users who are not available are messaged on a later run.
+// Sent steps are recorded in the state, except in debug mode. The records of the closed pull requests are removed
+func (handler *slackMessageHandler) sendEscalations(repositories []hosts.Repository) error {
+	if len(handler.escalations) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	escalations, err := state.GetTeamEscalations(handler.store, handler.teamName)
+	if err != nil {
+		return err
+	}
+	pullRequestsPerStep := make([][]escalatedPullRequest, len(handler.escalations))
+	for _, pullRequest := range handler.getPullRequests(hosts.GetRepositoriesNeedingAction(repositories)) {
+		step := handler.getEscalationStep(pullRequest.pullRequest, now)
+		if step < 0 {
+			continue
+		}
+		escalationState := escalations.Get(pullRequest.pullRequest.Link)
+		if !escalationState.IsSent(handler.escalations[step].After.String()) {
+			pullRequestsPerStep[step] = append(pullRequestsPerStep[step], escalatedPullRequest{pullRequest, escalationState})
+		}
+	}
+
+	for step, pullRequests := range pullRequestsPerStep {
+		if len(pullRequests) == 0 {
+			continue
+		}
+		rule := handler.escalations[step]
+		if err := handler.sendEscalation(rule, pullRequests, now); err != nil {
+			return err
+		}
+		for _, pullRequest := range pullRequests {
+			if !handler.isEscalationSent(rule, pullRequest) {
+				continue
+			}
+			// The previous steps are not sent anymore once a later step is reached
+			for _, previousRule := range handler.escalations[:step+1] {
+				pullRequest.state.MarkSent(previousRule.After.String(), now)
+			}
+		}
+	}
+
+	if handler.debugUser != "" {
+		return nil
+	}
+	escalations.Prune(hosts.GetOpenPullRequestLinks(repositories))
+	return state.SetTeamEscalations(handler.store, handler.teamName, escalations)
+}
+
+// getEscalationStep returns the index of the last escalation step reached by the pull request. It is -1 if no step is reached
+func (handler *slackMessageHandler) getEscalationStep(pullRequest *hosts.PullRequest, now time.Time) int {
+	if pullRequest.CreateTime.IsZero() {
+		return -1
+	}
+	age := handler.teamConfig.GetAge(pullRequest.CreateTime, now)
+	step := -1
+	for index, rule := range handler.escalations {
+		if age >= rule.After {
+			step = index
+		}
+	}
+	return step
+}
+
+// sendEscalation posts the escalated pull requests in the step's channel and messages the users who have to act on them.
+// Recipients who already got the step are skipped, and those who got it are recorded in the pull request's escalation state
+func (handler *slackMessageHandler) sendEscalation(rule config.EscalationRule, pullRequests []escalatedPullRequest, now time.Time) error {
+	step := rule.After.String()
+	continuationSection, err := handler.buildContinuationSection()
+	if err != nil {
+		return err
+	}
+
+	if channel := rule.GetChannel(handler.channel); channel != "" {
+		channelPullRequests := []escalatedPullRequest{}
+		for _, pullRequest := range pullRequests {
+			if !pullRequest.state.IsSentTo(step, channel) {
+				channelPullRequests = append(channelPullRequests, pullRequest)
+			}
+		}
+		if len(channelPullRequests) > 0 {
+			message, err := handler.buildEscalationMessage(rule, channelPullRequests, handler.getEscalationMentions(rule))
+			if err != nil {
+				return err
+			}
+			if _, _, err = handler.sendMessages(channel, message.split(continuationSection)); err != nil {
+				return err
+			}
+			for _, pullRequest := range channelPullRequests {
+				pullRequest.state.MarkSentTo(step, channel, now)
+			}
+		}
+	}
+
+	if rule.MessageReviewers {
+		pullRequestsPerUser := map[string][]escalatedPullRequest{}
+		for _, pullRequest := range pullRequests {
+			for destination, user := range handler.getEscalationUsers(pullRequest) {
+				if !pullRequest.state.IsSentTo(step, destination) && handler.isAvailable(user, destination, now) {
+					pullRequestsPerUser[destination] = append(pullRequestsPerUser[destination], pullRequest)
+				}
+			}
+		}
+		for user, userPullRequests := range pullRequestsPerUser {
+			message, err := handler.buildEscalationMessage(rule, userPullRequests, []string{})
+			if err != nil {
+				return err
+			}
+			if err = handler.sendUserMessage(user, message, continuationSection); err != nil {
+				return err
+			}
+			for _, pullRequest := range userPullRequests {
+				pullRequest.state.MarkSentTo(step, user, now)
+			}
+		}
+	}
+	return nil
+}
+
+// getEscalationUsers returns the users who have to act on the escalated pull request, by Slack destination.
+// Users who can't be messaged on Slack are left out
+func (handler *slackMessageHandler) getEscalationUsers(pullRequest escalatedPullRequest) map[string]config.User {
+	users := map[string]config.User{}
+	for _, user := range handler.getUsersToMessage(pullRequest.categorizedPullRequest) {
+		if destination := handler.users.resolve(user).destination(); destination != "" {
+			users[destination] = user
+		}
+	}
+	return users
+}
+
+// isEscalationSent returns true if the step was sent to all of its recipients for the given pull request
+func (handler *slackMessageHandler) isEscalationSent(rule config.EscalationRule, pullRequest escalatedPullRequest) bool {
+	step := rule.After.String()
+	if channel := rule.GetChannel(handler.channel); channel != "" && !pullRequest.state.IsSentTo(step, channel) {
+		return false
+	}
+	if rule.MessageReviewers {
+		for destination := range handler.getEscalationUsers(pullRequest) {
+			if !pullRequest.state.IsSentTo(step, destination) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// buildEscalationMessage returns the message listing the pull requests that reached the given escalation step
+func (handler *slackMessageHandler) buildEscalationMessage(rule config.EscalationRule, pullRequests []escalatedPullRequest, mentions []string) (slackMessage, error) {
+	text, err := render(handler.templates.escalation, escalationData{
+		Team:             handler.teamName,
+		After:            rule.After,
+		Mentions:         mentions,
+		PullRequestCount: len(pullRequests),
+	})
+	if err != nil {
+		return nil, err
+	}
+	header := newSlackTextSection("mrkdwn", text, false)
+
+	message := slackMessage{{blocks: []slack.Block{header}}}
+	for _, pullRequest := range pullRequests {
+		data := handler.newPullRequestData(pullRequest.categorizedPullRequest, pullRequest.category != readyToReviewCategory)
+		data.ShowRepository = true
+		text, err := render(handler.templates.pullRequest, data)
+		if err != nil {
+			return nil, err
+		}
+		message = append(message, slackBlockGroup{
+			blocks:  []slack.Block{newSlackTextSection("mrkdwn", text, false)},
+			context: []slack.Block{header},
+		})
+	}
+	return message, nil
+}
+
+// getEscalationMentions returns the Slack mentions of the step's team members. Other mentions are used as is
+func (handler *slackMessageHandler) getEscalationMentions(rule config.EscalationRule) []string {
+	mentions := []string{}
+	for _, mention := range rule.Mentions {
+		for _, user := range handler.teamConfig.Users {
+			if user.Name == mention {
+				if userMention := handler.users.resolve(user).mention(); userMention != "" {
+					mention = userMention
+				}
+				break
+			}
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions
+}
@@ -0,0 +1,129 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendEscalations(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := 24 * time.Hour
+	author := config.User{Name: "author", SlackUsername: "@author"}
+	reviewer := config.User{Name: "reviewer", SlackUsername: "@reviewer"}
+	techLead := config.User{Name: "Tech Lead", SlackUsername: "@lead"}
+	newPullRequest := func(title string, age time.Duration) *hosts.PullRequest {
+		return &hosts.PullRequest{Title: title, Link: title + ".com", Author: author, CreateTime: time.Now().Add(-age), Reviewers: []*hosts.Reviewer{{User: reviewer}}}
+	}
+	mockRepository := newMockRepository(ctrl,
+		[]*hosts.PullRequest{},
+		[]*hosts.PullRequest{newPullRequest("new", day), newPullRequest("waiting", 3*day), newPullRequest("late", 5*day), newPullRequest("forgotten", 10*day)},
+		[]*hosts.PullRequest{},
+	)
+	mockRepository.EXPECT().HasPullRequestsToDisplay().Return(true).AnyTimes()
+	mockRepository.EXPECT().GetIgnoredPullRequests().Return([]*hosts.PullRequest{}).AnyTimes()
+
+	client := &mockSlackClient{}
+	handler := newTestSlackMessageHandler(t)
+	handler.client = client
+	handler.channel = "#team"
+	handler.teamConfig = &config.TeamConfig{Users: []config.User{author, reviewer, techLead}}
+	handler.escalations = sortEscalations([]config.EscalationRule{
+		{After: 7 * day, Channel: "#managers"},
+		{After: 2 * day, MessageReviewers: true},
+		{After: 4 * day, Mentions: []string{"Tech Lead", "<!subteam^S1>"}},
+	})
+	assert.Equal(t, 2*day, handler.escalations[0].After)
+
+	assert.Nil(t, handler.sendEscalations([]hosts.Repository{mockRepository}))
+	assert.Equal(t, []mockSlackMessage{{channel: "@reviewer"}, {channel: "#team"}, {channel: "#managers"}}, client.messages)
+
+	// Steps are only sent once
+	client.messages = nil
+	assert.Nil(t, handler.sendEscalations([]hosts.Repository{mockRepository}))
+	assert.Empty(t, client.messages)
+
+	// The earlier steps of the pull requests that reached a later step are recorded as sent
+	escalations, err := state.GetTeamEscalations(handler.store, "my-team")
+	assert.Nil(t, err)
+	assert.Len(t, escalations.Get("forgotten.com").Sent, 3)
+	assert.Empty(t, escalations.Get("new.com").Sent)
+}
+
+func TestSendEscalationsToUnavailableReviewers(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := 24 * time.Hour
+	tomorrow := time.Now().UTC().Add(day).Weekday().String()
+	reviewer := config.User{Name: "reviewer", SlackUsername: "@reviewer", Schedule: config.Schedule{WorkingDays: []string{tomorrow}}}
+	pullRequest := &hosts.PullRequest{Title: "waiting", Link: "waiting.com", CreateTime: time.Now().Add(-3 * day), Reviewers: []*hosts.Reviewer{{User: reviewer}}}
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{pullRequest}, []*hosts.PullRequest{})
+	mockRepository.EXPECT().HasPullRequestsToDisplay().Return(true).AnyTimes()
+	mockRepository.EXPECT().GetIgnoredPullRequests().Return([]*hosts.PullRequest{}).AnyTimes()
+
+	client := &mockSlackClient{}
+	handler := newTestSlackMessageHandler(t)
+	handler.client = client
+	handler.teamConfig = &config.TeamConfig{Users: []config.User{reviewer}}
+	handler.escalations = []config.EscalationRule{{After: 2 * day, MessageReviewers: true, Channel: "#team"}}
+	escalations := state.TeamEscalations{}
+	escalations.Get("merged.com").MarkSent("48h0m0s", time.Now())
+	state.SetTeamEscalations(handler.store, "my-team", escalations)
+
+	// The reviewer is not working today, the step stays pending
+	assert.Nil(t, handler.sendEscalations([]hosts.Repository{mockRepository}))
+	assert.Equal(t, []mockSlackMessage{{channel: "#team"}}, client.messages)
+	escalations, err := state.GetTeamEscalations(handler.store, "my-team")
+	assert.Nil(t, err)
+	assert.False(t, escalations.Get("waiting.com").IsSent("48h0m0s"))
+	assert.True(t, escalations.Get("waiting.com").IsSentTo("48h0m0s", "#team"))
+	// The records of the closed pull requests are removed
+	assert.NotContains(t, escalations, "merged.com")
+
+	// Once the reviewer is available, they are messaged but the step is not posted in the channel again
+	reviewer.Schedule = config.Schedule{}
+	pullRequest.Reviewers = []*hosts.Reviewer{{User: reviewer}}
+	handler.teamConfig = &config.TeamConfig{Users: []config.User{reviewer}}
+	client.messages = nil
+	assert.Nil(t, handler.sendEscalations([]hosts.Repository{mockRepository}))
+	assert.Equal(t, []mockSlackMessage{{channel: "@reviewer"}}, client.messages)
+	escalations, err = state.GetTeamEscalations(handler.store, "my-team")
+	assert.Nil(t, err)
+	assert.True(t, escalations.Get("waiting.com").IsSent("48h0m0s"))
+}
+
+func TestBuildEscalationMessage(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := newTestSlackMessageHandler(t)
+	handler.teamConfig = &config.TeamConfig{Users: []config.User{{Name: "Tech Lead", SlackUsername: "@lead"}}}
+	rule := config.EscalationRule{After: 96 * time.Hour, Mentions: []string{"Tech Lead", "<!subteam^S1>"}}
+	mentions := handler.getEscalationMentions(rule)
+	assert.Equal(t, []string{"@lead", "<!subteam^S1>"}, mentions)
+
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{}, []*hosts.PullRequest{})
+	pullRequests := []escalatedPullRequest{{categorizedPullRequest: categorizedPullRequest{
+		repository:  mockRepository,
+		category:    changesRequestedCategory,
+		pullRequest: &hosts.PullRequest{Title: "pr1", Link: "link1.com", Author: config.User{SlackUsername: "@jdoe"}},
+	}}}
+	message, err := handler.buildEscalationMessage(rule, pullRequests, mentions)
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Len(t, sections, 2)
+	assert.Equal(t, ":rotating_light: Pull requests waiting for more than 4 days @lead <!subteam^S1>", sections[0].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "[mock-repo] @jdoe: <link1.com|pr1>", sections[1].(*slack.SectionBlock).Text.Text)
+}
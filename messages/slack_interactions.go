@@ -0,0 +1,134 @@
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	snoozeActionID = "snooze"
+	claimActionID  = "claim"
+	skipActionID   = "skip"
+
+	snoozeDuration = 24 * time.Hour
+)
+
+// slackBlockActionsPayload is the payload sent by Slack when a user clicks a button in a message
+type slackBlockActionsPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+type slackInteractionHandler struct {
+	signingSecrets []string
+	store          state.Store
+}
+
+// NewSlackInteractionHandler returns an HTTP handler that receives the button clicks from Slack messages.
+// Requests must be signed with one of the given signing secrets. Choices are persisted in the given store
+func NewSlackInteractionHandler(signingSecrets []string, store state.Store) http.Handler {
+	return &slackInteractionHandler{signingSecrets: signingSecrets, store: store}
+}
+
+// getSlackActionsBlock returns the buttons that can be used to act on the given pull request
+func getSlackActionsBlock(link string) *slack.ActionBlock {
+	var button = func(actionID, text string) slack.BlockElement {
+		return slack.NewButtonBlockElement(actionID, link, slack.NewTextBlockObject("plain_text", text, false, false))
+	}
+	return slack.NewActionBlock("",
+		button(snoozeActionID, "Snooze 1 day"),
+		button(claimActionID, "I'm on it"),
+		button(skipActionID, "Skip"),
+	)
+}
+
+func (handler *slackInteractionHandler) verify(header http.Header, body []byte) error {
+	for _, signingSecret := range handler.signingSecrets {
+		verifier, err := slack.NewSecretsVerifier(header, signingSecret)
+		if err != nil {
+			return err
+		}
+		verifier.Write(body)
+		if verifier.Ensure() == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("The request signature does not match any of the signing secrets")
+}
+
+func (handler *slackInteractionHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, "Unable to read the request", http.StatusBadRequest)
+		return
+	}
+	if err = handler.verify(request.Header, body); err != nil {
+		log.WithError(err).Warningln("Received an unverified Slack interaction")
+		http.Error(writer, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(writer, "Unable to parse the request", http.StatusBadRequest)
+		return
+	}
+	payload := &slackBlockActionsPayload{}
+	if err = json.Unmarshal([]byte(form.Get("payload")), payload); err != nil {
+		http.Error(writer, "Unable to parse the payload", http.StatusBadRequest)
+		return
+	}
+
+	for _, action := range payload.Actions {
+		if err = handler.handleAction(payload.User.ID, payload.User.Username, action.ActionID, action.Value); err != nil {
+			log.WithError(err).Errorf("Unable to handle the %s action on %s", action.ActionID, action.Value)
+			http.Error(writer, "Unable to handle the action", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err = handler.store.Flush(); err != nil {
+		log.WithError(err).Errorln("Unable to save the actions")
+		http.Error(writer, "Unable to handle the action", http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (handler *slackInteractionHandler) handleAction(userID, username, actionID, link string) error {
+	pullRequestState, err := state.GetPullRequestState(handler.store, link)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	switch actionID {
+	case snoozeActionID:
+		pullRequestState.SnoozedUntil = now.Add(snoozeDuration)
+	case claimActionID:
+		pullRequestState.Claim(userID, username)
+	case skipActionID:
+		pullRequestState.Skip(userID, username, now)
+	default:
+		return fmt.Errorf("Unknown action %s", actionID)
+	}
+	log.Infof("%s (%s) chose to %s %s", username, userID, actionID, link)
+	return state.SetPullRequestState(handler.store, link, pullRequestState)
+}
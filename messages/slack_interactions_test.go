@@ -0,0 +1,145 @@
+package messages
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSigningSecret = "my-secret"
+
+func TestSlackInteractionHandler(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name           string
+		method         string
+		actionID       string
+		signingSecret  string
+		expectedStatus int
+		checkState     func(*testing.T, *state.PullRequestState)
+	}{
+		{
+			name:           "Snooze",
+			actionID:       snoozeActionID,
+			expectedStatus: http.StatusOK,
+			checkState: func(t *testing.T, pullRequestState *state.PullRequestState) {
+				assert.True(t, pullRequestState.IsSnoozed(time.Now().Add(23*time.Hour)))
+				assert.False(t, pullRequestState.IsSnoozed(time.Now().Add(25*time.Hour)))
+			},
+		},
+		{
+			name:           "Claim",
+			actionID:       claimActionID,
+			expectedStatus: http.StatusOK,
+			checkState: func(t *testing.T, pullRequestState *state.PullRequestState) {
+				assert.Equal(t, "U123", pullRequestState.ClaimedBy)
+				assert.Equal(t, "jdoe", pullRequestState.ClaimedByUsername)
+			},
+		},
+		{
+			name:           "Skip",
+			actionID:       skipActionID,
+			expectedStatus: http.StatusOK,
+			checkState: func(t *testing.T, pullRequestState *state.PullRequestState) {
+				assert.True(t, pullRequestState.IsSkippedBy("U123", "jdoe", time.Now().Add(-time.Minute)))
+			},
+		},
+		{
+			name:           "Unknown action",
+			actionID:       "merge",
+			expectedStatus: http.StatusInternalServerError,
+			checkState: func(t *testing.T, pullRequestState *state.PullRequestState) {
+				assert.Equal(t, &state.PullRequestState{}, pullRequestState)
+			},
+		},
+		{
+			name:           "Invalid signature",
+			actionID:       snoozeActionID,
+			signingSecret:  "other-secret",
+			expectedStatus: http.StatusUnauthorized,
+			checkState: func(t *testing.T, pullRequestState *state.PullRequestState) {
+				assert.Equal(t, &state.PullRequestState{}, pullRequestState)
+			},
+		},
+		{
+			name:           "Invalid method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+			checkState: func(t *testing.T, pullRequestState *state.PullRequestState) {
+				assert.Equal(t, &state.PullRequestState{}, pullRequestState)
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			store := state.NewMemoryStore()
+			handler := NewSlackInteractionHandler([]string{"unused-secret", testSigningSecret}, store)
+
+			signingSecret := testSigningSecret
+			if tt.signingSecret != "" {
+				signingSecret = tt.signingSecret
+			}
+			request := newSignedSlackInteractionRequest(signingSecret, tt.actionID, "pr.com")
+			if tt.method != "" {
+				request.Method = tt.method
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+
+			pullRequestState, _ := state.GetPullRequestState(store, "pr.com")
+			tt.checkState(t, pullRequestState)
+		})
+	}
+}
+
+func TestSlackInteractionHandlerWithoutSignature(t *testing.T) {
+	t.Parallel()
+
+	request := newSignedSlackInteractionRequest(testSigningSecret, snoozeActionID, "pr.com")
+	request.Header.Del("X-Slack-Signature")
+	recorder := httptest.NewRecorder()
+	NewSlackInteractionHandler([]string{testSigningSecret}, state.NewMemoryStore()).ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestGetSlackActionsBlock(t *testing.T) {
+	t.Parallel()
+
+	block := getSlackActionsBlock("pr.com")
+	assert.Len(t, block.Elements, 3)
+	for index, actionID := range []string{snoozeActionID, claimActionID, skipActionID} {
+		button := block.Elements[index].(*slack.ButtonBlockElement)
+		assert.Equal(t, actionID, button.ActionID)
+		assert.Equal(t, "pr.com", button.Value)
+	}
+}
+
+func newSignedSlackInteractionRequest(signingSecret, actionID, link string) *http.Request {
+	payload := fmt.Sprintf(`{"type":"block_actions","user":{"id":"U123","username":"jdoe"},"actions":[{"action_id":%q,"value":%q}]}`, actionID, link)
+	body := url.Values{"payload": {payload}}.Encode()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	hash := hmac.New(sha256.New, []byte(signingSecret))
+	hash.Write([]byte(fmt.Sprintf("v0:%s:%s", timestamp, body)))
+
+	request := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	request.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(hash.Sum(nil)))
+	return request
+}
@@ -0,0 +1,64 @@
+package messages
+
+import (
+	"github.com/nlopes/slack"
+)
+
+const (
+	// Limits set by Slack on messages
+	maxSlackBlocks     = 50
+	maxSlackTextLength = 3000
+
+	maxTitleLength = 150
+)
+
+// slackBlockGroup is a list of blocks that are never split into different messages
+type slackBlockGroup struct {
+	blocks []slack.Block
+	// context is repeated at the top of the next message when a message is split right before this group (ex: the repository title)
+	context []slack.Block
+}
+
+// slackMessage is a message built from groups of blocks. It may be split into multiple Slack messages
+type slackMessage []slackBlockGroup
+
+// blocks returns all the blocks of the message
+func (message slackMessage) blocks() []slack.Block {
+	blocks := []slack.Block{}
+	for _, group := range message {
+		blocks = append(blocks, group.blocks...)
+	}
+	return blocks
+}
+
+// split returns the message's blocks split into messages that respect Slack's block limit.
+// Messages following the first one start with the given continuation block and the context of their first group
+func (message slackMessage) split(continuationBlock slack.Block) [][]slack.Block {
+	messages := [][]slack.Block{}
+	current := []slack.Block{}
+	for _, group := range message {
+		if len(current) > 0 && len(current)+len(group.blocks) > maxSlackBlocks {
+			messages = append(messages, current)
+			current = append([]slack.Block{continuationBlock}, group.context...)
+		}
+		current = append(current, group.blocks...)
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// newSlackTextSection returns a section block containing the given text, truncated to Slack's maximum text length
+func newSlackTextSection(textType, text string, emoji bool) *slack.SectionBlock {
+	return slack.NewSectionBlock(slack.NewTextBlockObject(textType, truncate(text, maxSlackTextLength), emoji, false), nil, nil)
+}
+
+// truncate shortens the given text to the given number of characters (ellipsis included)
+func truncate(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength-1]) + "…"
+}
@@ -0,0 +1,85 @@
+package messages
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitLargeSlackMessage(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pullRequests := []*hosts.PullRequest{}
+	for i := 0; i < 60; i++ {
+		pullRequests = append(pullRequests, &hosts.PullRequest{Title: fmt.Sprintf("pr%d", i), Link: fmt.Sprintf("link%d.com", i)})
+	}
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, pullRequests, []*hosts.PullRequest{})
+
+	handler := newTestSlackMessageHandler(t)
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	continuationSection, _ := handler.buildContinuationSection()
+	messages := message.split(continuationSection)
+	assert.Len(t, messages, 2)
+
+	// Header, divider, repository title, category title and 46 pull requests
+	assert.Len(t, messages[0], 50)
+	assert.Equal(t, "<link45.com|pr45>", messages[0][49].(*slack.SectionBlock).Text.Text)
+
+	// Continuation, repository title, category title and 14 pull requests
+	assert.Len(t, messages[1], 17)
+	assert.Equal(t, "(continued)", messages[1][0].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "[mock] *<mock-repo.com|mock-repo>*", messages[1][1].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, ":no_entry: Pull requests still in need of approvers", messages[1][2].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "<link46.com|pr46>", messages[1][3].(*slack.SectionBlock).Text.Text)
+}
+
+func TestSplitKeepsGroupsTogether(t *testing.T) {
+	t.Parallel()
+
+	block := slack.NewDividerBlock()
+	continuation := newSlackTextSection("plain_text", "continued", false)
+	context := newSlackTextSection("plain_text", "context", false)
+	message := slackMessage{}
+	for i := 0; i < 20; i++ {
+		message = append(message, slackBlockGroup{blocks: []slack.Block{block, block, block}, context: []slack.Block{context}})
+	}
+
+	messages := message.split(continuation)
+	assert.Len(t, messages, 2)
+	assert.Len(t, messages[0], 48) // 16 groups, the 17th would go over the limit
+	assert.Len(t, messages[1], 14) // Continuation, context and 4 groups
+	assert.Equal(t, []slack.Block{continuation, context, block}, messages[1][:3])
+	assert.Len(t, message.blocks(), 60)
+}
+
+func TestTruncateLongTexts(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "ééééééééé…", truncate(strings.Repeat("é", 20), 10))
+
+	section := newSlackTextSection("mrkdwn", strings.Repeat("a", 5000), false)
+	assert.Len(t, []rune(section.Text.Text), maxSlackTextLength)
+}
+
+func TestTruncateLongPullRequestTitles(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{
+		{Title: strings.Repeat("a", 200), Link: "link.com"},
+	}, []*hosts.PullRequest{})
+	message, err := newTestSlackMessageHandler(t).buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Equal(t, fmt.Sprintf("<link.com|%s…>", strings.Repeat("a", maxTitleLength-1)), sections[4].(*slack.SectionBlock).Text.Text)
+}
@@ -1,11 +1,14 @@
 package messages
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/julienduchesne/pull-request-reminder/config"
 	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/state"
 	"github.com/nlopes/slack"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,11 +41,14 @@ func TestBuildChannelSlackMessageWithSingleRepo(t *testing.T) {
 				Title: "pr3",
 				Link:  "link3.com",
 			},
-		}).AnyTimes()
+		},
+		[]*hosts.PullRequest{}).AnyTimes()
 
 	repositories := []hosts.Repository{mockRepository}
 
-	sections := buildChannelSlackMessage(repositories)
+	message, err := newTestSlackMessageHandler(t).buildChannelSlackMessage(repositories)
+	assert.Nil(t, err)
+	sections := message.blocks()
 	assert.Len(t, sections, 8)
 	// 1. Main Title
 	assert.Equal(t, "Hello, here are the pull requests requiring your attention today:", sections[0].(*slack.SectionBlock).Text.Text)
@@ -109,12 +115,14 @@ func TestBuildUserSlackMessageWithSingleRepo(t *testing.T) {
 					},
 				},
 			},
-		}).AnyTimes()
+		},
+		[]*hosts.PullRequest{}).AnyTimes()
 
 	repositories := []hosts.Repository{mockRepository}
 
-	sectionsByUser := buildUserSlackMessages(repositories)
-	firstUserSections := sectionsByUser["user1"]
+	sectionsByUser, err := newTestSlackMessageHandler(t).buildUserSlackMessages(repositories)
+	assert.Nil(t, err)
+	firstUserSections := sectionsByUser["user1"].blocks()
 	assert.Len(t, firstUserSections, 7)
 	// 1. Main Title
 	assert.Equal(t, "Hello, here are the pull requests requiring your attention today:", firstUserSections[0].(*slack.SectionBlock).Text.Text)
@@ -129,7 +137,7 @@ func TestBuildUserSlackMessageWithSingleRepo(t *testing.T) {
 	assert.Equal(t, ":no_entry: Pull requests still in need of approvers", firstUserSections[5].(*slack.SectionBlock).Text.Text)
 	assert.Equal(t, "<link2.com|pr2>", firstUserSections[6].(*slack.SectionBlock).Text.Text)
 
-	secondUserSections := sectionsByUser["user2"]
+	secondUserSections := sectionsByUser["user2"].blocks()
 	assert.Len(t, secondUserSections, 5)
 	// 1. Main Title
 	assert.Equal(t, "Hello, here are the pull requests requiring your attention today:", secondUserSections[0].(*slack.SectionBlock).Text.Text)
@@ -141,3 +149,559 @@ func TestBuildUserSlackMessageWithSingleRepo(t *testing.T) {
 	assert.Equal(t, ":no_entry: Pull requests still in need of approvers", secondUserSections[3].(*slack.SectionBlock).Text.Text)
 	assert.Equal(t, "<link3.com|pr3>", secondUserSections[4].(*slack.SectionBlock).Text.Text)
 }
+
+func TestBuildChannelSlackMessageWithCustomTemplates(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHost := hosts.NewMockHost(ctrl)
+	mockHost.EXPECT().GetName().Return("mock").AnyTimes()
+
+	mockRepository := hosts.NewMockRepository(ctrl)
+	mockRepository.EXPECT().GetHost().Return(mockHost).AnyTimes()
+	mockRepository.EXPECT().GetLink().Return("mock-repo.com").AnyTimes()
+	mockRepository.EXPECT().GetName().Return("mock-repo").AnyTimes()
+	mockRepository.EXPECT().GetPullRequestsToDisplay().Return(
+		[]*hosts.PullRequest{},
+		[]*hosts.PullRequest{
+			{
+				Title:      "pr1",
+				Link:       "link1.com",
+				Author:     config.User{Name: "John Doe"},
+				CreateTime: time.Now().Add(-50 * time.Hour),
+			},
+		},
+		[]*hosts.PullRequest{}).AnyTimes()
+
+	handler := newTestSlackMessageHandler(t)
+	handler.templates, _ = newMessageTemplates(
+		config.MessageTemplates{
+			Header:        "{{ .PullRequestCount }} PRs for {{ .Team }}",
+			ReadyToReview: "{{ len .PullRequests }} to review",
+		},
+		config.MessageTemplates{
+			Header:      "{{ .PullRequestCount }} PRs for {{ .Team | upper }}",
+			PullRequest: "{{ .Title }} by {{ .Author.Name }} ({{ humanizeDuration .Age }} old)",
+		},
+	)
+
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Len(t, sections, 5)
+	assert.Equal(t, "1 PRs for MY-TEAM", sections[0].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "[mock] *<mock-repo.com|mock-repo>*", sections[2].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "1 to review", sections[3].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "pr1 by John Doe (2 days old)", sections[4].(*slack.SectionBlock).Text.Text)
+}
+
+func TestSendChannelMessageModes(t *testing.T) {
+	t.Parallel()
+
+	blocks := []slack.Block{slack.NewDividerBlock()}
+	cases := []struct {
+		name             string
+		mode             string
+		lastMessage      *slackPostedMessage
+		deleted          map[string]bool
+		expectedMessages []mockSlackMessage
+		expectStored     bool
+	}{
+		{
+			name: "New message mode",
+			mode: newMessageMode,
+			lastMessage: &slackPostedMessage{
+				ChannelID: "C1", Timestamp: "1.0", PostedAt: time.Now(),
+			},
+			expectedMessages: []mockSlackMessage{{channel: "#my-channel"}},
+		},
+		{
+			name:             "Update mode without previous message",
+			mode:             updateMessageMode,
+			expectedMessages: []mockSlackMessage{{channel: "#my-channel"}},
+			expectStored:     true,
+		},
+		{
+			name: "Update mode with a recent message",
+			mode: updateMessageMode,
+			lastMessage: &slackPostedMessage{
+				ChannelID: "C1", Timestamp: "1.0", PostedAt: time.Now().Add(-time.Hour),
+			},
+			expectedMessages: []mockSlackMessage{{channel: "C1", updatedTimestamp: "1.0"}},
+		},
+		{
+			name: "Update mode with a deleted message",
+			mode: updateMessageMode,
+			lastMessage: &slackPostedMessage{
+				ChannelID: "C1", Timestamp: "1.0", PostedAt: time.Now().Add(-time.Hour),
+			},
+			deleted:          map[string]bool{"1.0": true},
+			expectedMessages: []mockSlackMessage{{channel: "#my-channel"}},
+			expectStored:     true,
+		},
+		{
+			name: "Update mode with a message older than the rollover period",
+			mode: updateMessageMode,
+			lastMessage: &slackPostedMessage{
+				ChannelID: "C1", Timestamp: "1.0", PostedAt: time.Now().Add(-25 * time.Hour),
+			},
+			expectedMessages: []mockSlackMessage{{channel: "#my-channel"}},
+			expectStored:     true,
+		},
+		{
+			name: "Thread mode with a recent message",
+			mode: threadMessageMode,
+			lastMessage: &slackPostedMessage{
+				ChannelID: "C1", Timestamp: "1.0", PostedAt: time.Now().Add(-time.Hour),
+			},
+			expectedMessages: []mockSlackMessage{{channel: "C1", threadTimestamp: "1.0"}},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockSlackClient{deleted: tt.deleted}
+			handler := newTestSlackMessageHandler(t)
+			handler.client = client
+			handler.channel = "#my-channel"
+			handler.channelMessageMode = tt.mode
+			if tt.lastMessage != nil {
+				handler.store.Set("slack/my-team/#my-channel", tt.lastMessage)
+			}
+
+			assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks}))
+			assert.Equal(t, tt.expectedMessages, client.messages)
+
+			if tt.expectStored {
+				storedMessage := &slackPostedMessage{}
+				handler.store.Get("slack/my-team/#my-channel", storedMessage)
+				assert.Equal(t, "C123", storedMessage.ChannelID)
+				assert.Equal(t, "123.456", storedMessage.Timestamp)
+			}
+		})
+	}
+}
+
+func TestNotifyWithoutPullRequestsNeedingAction(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Handlers are notified on every run, nothing is posted when no pull requests need action
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{}, []*hosts.PullRequest{})
+	mockRepository.EXPECT().HasPullRequestsToDisplay().Return(false).AnyTimes()
+	client := &mockSlackClient{}
+	handler := newTestSlackMessageHandler(t)
+	handler.client = client
+	handler.channel = "#my-channel"
+	handler.messageUsers = true
+	assert.Nil(t, handler.Notify([]hosts.Repository{mockRepository}))
+	assert.Empty(t, client.messages)
+
+	// In update mode, the current message is updated so that it doesn't list the merged pull requests anymore
+	handler.channelMessageMode = updateMessageMode
+	assert.Nil(t, handler.Notify([]hosts.Repository{mockRepository}))
+	assert.Empty(t, client.messages) // There is no current message
+	handler.store.Set("slack/my-team/#my-channel", &slackPostedMessage{
+		ChannelID: "C1", Timestamp: "1.0", ContinuationTimestamps: []string{"1.1"}, PostedAt: time.Now().Add(-time.Hour),
+	})
+	assert.Nil(t, handler.Notify([]hosts.Repository{mockRepository}))
+	assert.Equal(t, []mockSlackMessage{{channel: "C1", updatedTimestamp: "1.0"}, {channel: "C1", deletedTimestamp: "1.1"}}, client.messages)
+	storedMessage := &slackPostedMessage{}
+	handler.store.Get("slack/my-team/#my-channel", storedMessage)
+	assert.Equal(t, "1.0", storedMessage.Timestamp)
+	assert.Empty(t, storedMessage.ContinuationTimestamps)
+}
+
+func TestSendSplitChannelMessage(t *testing.T) {
+	t.Parallel()
+
+	blocks := []slack.Block{slack.NewDividerBlock()}
+	client := &mockSlackClient{}
+	handler := newTestSlackMessageHandler(t)
+	handler.client = client
+	handler.channel = "#my-channel"
+
+	// New message mode, the continuation is posted in the first message's thread
+	assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks, blocks}))
+	assert.Equal(t, []mockSlackMessage{{channel: "#my-channel"}, {channel: "C123", threadTimestamp: "123.456"}}, client.messages)
+
+	// Update mode, the two first messages are updated, the extra one is deleted
+	client.messages = nil
+	handler.channelMessageMode = updateMessageMode
+	handler.store.Set("slack/my-team/#my-channel", &slackPostedMessage{
+		ChannelID: "C1", Timestamp: "1.0", ContinuationTimestamps: []string{"1.1", "1.2"}, PostedAt: time.Now(),
+	})
+	assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks, blocks}))
+	assert.Equal(t, []mockSlackMessage{
+		{channel: "C1", updatedTimestamp: "1.0"},
+		{channel: "C1", updatedTimestamp: "1.1"},
+		{channel: "C1", deletedTimestamp: "1.2"},
+	}, client.messages)
+
+	// Update mode, the message got longer so a continuation is added to the thread
+	client.messages = nil
+	assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks, blocks, blocks}))
+	assert.Equal(t, []mockSlackMessage{
+		{channel: "C1", updatedTimestamp: "1.0"},
+		{channel: "C1", updatedTimestamp: "1.1"},
+		{channel: "C1", threadTimestamp: "1.0"},
+	}, client.messages)
+	storedMessage := &slackPostedMessage{}
+	handler.store.Get("slack/my-team/#my-channel", storedMessage)
+	assert.Equal(t, []string{"1.1", "123.458"}, storedMessage.ContinuationTimestamps)
+
+	// Update mode, a deleted continuation is posted again in the thread
+	client.messages = nil
+	client.deleted = map[string]bool{"1.1": true}
+	assert.Nil(t, handler.sendChannelMessage([][]slack.Block{blocks, blocks}))
+	assert.Equal(t, []mockSlackMessage{
+		{channel: "C1", updatedTimestamp: "1.0"},
+		{channel: "C1", threadTimestamp: "1.0"},
+		{channel: "C1", deletedTimestamp: "123.458"},
+	}, client.messages)
+}
+
+func TestBuildChannelSlackMessageWithInteractiveButtons(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{
+		{
+			Title: "pr1",
+			Link:  "link1.com",
+			State: &state.PullRequestState{ClaimedByUsername: "jdoe"},
+		},
+	}, []*hosts.PullRequest{})
+
+	handler := newTestSlackMessageHandler(t)
+	handler.interactive = true
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Len(t, sections, 6)
+	assert.Equal(t, "<link1.com|pr1> (claimed by @jdoe)", sections[4].(*slack.SectionBlock).Text.Text)
+	assert.IsType(t, &slack.ActionBlock{}, sections[5])
+}
+
+func TestBuildUserSlackMessagesWithDismissedPullRequests(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	reviewers := []*hosts.Reviewer{
+		{User: config.User{SlackUsername: "@user1"}},
+		{User: config.User{SlackUsername: "@user2"}},
+	}
+	skipped := &state.PullRequestState{}
+	skipped.Skip("", "user1", time.Now())
+	claimed := &state.PullRequestState{}
+	claimed.Claim("U2", "user2")
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{
+		{Title: "claimed", Link: "link1.com", Reviewers: reviewers, State: claimed},
+		{Title: "skipped", Link: "link2.com", Reviewers: reviewers, State: skipped, UpdateTime: time.Now().Add(-time.Hour)},
+	}, []*hosts.PullRequest{})
+
+	sectionsByUser, err := newTestSlackMessageHandler(t).buildUserSlackMessages([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	assert.NotContains(t, sectionsByUser, "@user1")
+	secondUserSections := sectionsByUser["@user2"].blocks()
+	assert.Len(t, secondUserSections, 6)
+	assert.Equal(t, "<link1.com|claimed> (claimed by <@U2>)", secondUserSections[4].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "<link2.com|skipped>", secondUserSections[5].(*slack.SectionBlock).Text.Text)
+}
+
+func TestBuildChannelSlackMessageWithReviewerMentions(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := newMockRepository(ctrl,
+		[]*hosts.PullRequest{
+			{Title: "merge", Link: "link1.com", Reviewers: []*hosts.Reviewer{{User: config.User{Name: "user1", SlackUsername: "@user1"}}}},
+		},
+		[]*hosts.PullRequest{
+			{Title: "pending", Link: "link2.com", Reviewers: []*hosts.Reviewer{
+				{User: config.User{Name: "user1", SlackUsername: "@user1"}, Approved: true},
+				{User: config.User{Name: "user2", SlackUsername: "@user2"}},
+				{User: config.User{Name: "user3", SlackUsername: "@user3"}, RequestedChanges: true},
+				{User: config.User{SlackUsername: "@not-a-member"}},
+			}},
+			{Title: "unassigned", Link: "link3.com", Reviewers: []*hosts.Reviewer{
+				{User: config.User{Name: "user1", SlackUsername: "@user1"}, Approved: true},
+			}},
+		}, []*hosts.PullRequest{})
+
+	handler := newTestSlackMessageHandler(t)
+	handler.mentionPendingReviewers = true
+	handler.unassignedUserGroups = []string{"S123"}
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Len(t, sections, 8)
+	assert.Equal(t, "<link1.com|merge>", sections[4].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "<link2.com|pending> cc @user2 @user3", sections[6].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "<link3.com|unassigned> cc <!subteam^S123>", sections[7].(*slack.SectionBlock).Text.Text)
+
+	// Without the option, only the user groups are mentioned
+	handler.mentionPendingReviewers = false
+	message, err = handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections = message.blocks()
+	assert.Equal(t, "<link2.com|pending>", sections[6].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "<link3.com|unassigned> cc <!subteam^S123>", sections[7].(*slack.SectionBlock).Text.Text)
+}
+
+func TestBuildSlackMessagesWithChangesRequested(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{}, []*hosts.PullRequest{
+		{
+			Title:  "pr1",
+			Link:   "link1.com",
+			Author: config.User{Name: "user1", SlackUsername: "@user1"},
+			Reviewers: []*hosts.Reviewer{
+				{RequestedChanges: true, User: config.User{Name: "user2", SlackUsername: "@user2"}},
+			},
+		},
+	})
+	handler := newTestSlackMessageHandler(t)
+
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Len(t, sections, 5)
+	assert.Equal(t, ":pencil2: Pull requests waiting on their author (changes requested)", sections[3].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "@user1: <link1.com|pr1>", sections[4].(*slack.SectionBlock).Text.Text)
+
+	// Only the author is messaged, the reviewer is waiting on them
+	sectionsByUser, err := handler.buildUserSlackMessages([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	assert.Len(t, sectionsByUser, 1)
+	authorSections := sectionsByUser["@user1"].blocks()
+	assert.Len(t, authorSections, 5)
+	assert.Equal(t, ":pencil2: Pull requests waiting on their author (changes requested)", authorSections[3].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "<link1.com|pr1>", authorSections[4].(*slack.SectionBlock).Text.Text)
+}
+
+func TestBuildChannelSlackMessageGroupedByReviewer(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user1 := config.User{Name: "User 1", SlackUsername: "@user1"}
+	user2 := config.User{Name: "User 2", SlackUsername: "@user2"}
+	mockRepository := newMockRepository(ctrl,
+		[]*hosts.PullRequest{{Title: "merge", Link: "link1.com", Author: user1, CreateTime: time.Now().Add(-time.Hour)}},
+		[]*hosts.PullRequest{{Title: "review", Link: "link2.com", Author: user1, CreateTime: time.Now().Add(-2 * time.Hour), Reviewers: []*hosts.Reviewer{{User: user2}}}},
+		[]*hosts.PullRequest{},
+	)
+
+	handler := newTestSlackMessageHandler(t)
+	handler.sort = config.SortByAge
+	handler.channelGroupBy = groupByReviewer
+	handler.templates, _ = newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{PullRequest: "{{ if .ShowRepository }}[{{ .Repository.Name }}] {{ end }}{{ .Title }}"})
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Len(t, sections, 9)
+	// The oldest pull request's group comes first
+	assert.Equal(t, "*User 2*", sections[2].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, ":no_entry: Pull requests still in need of approvers", sections[3].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "[mock-repo] review", sections[4].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "*User 1*", sections[6].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "[mock-repo] merge", sections[8].(*slack.SectionBlock).Text.Text)
+}
+
+func TestBuildUserSlackMessagesWithSchedules(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Weekday().String()
+	absentUser := config.User{Name: "user1", SlackUsername: "@user1", Schedule: config.Schedule{WorkingDays: []string{tomorrow}}}
+	alreadyMessagedUser := config.User{Name: "user2", SlackUsername: "@user2"}
+	availableUser := config.User{Name: "user3", SlackUsername: "@user3"}
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{
+		{Title: "pr1", Link: "link1.com", Reviewers: []*hosts.Reviewer{{User: absentUser}, {User: alreadyMessagedUser}, {User: availableUser}}},
+	}, []*hosts.PullRequest{})
+
+	handler := newTestSlackMessageHandler(t)
+	handler.userMessagePeriod = 20 * time.Hour
+	handler.store.Set(handler.userMessageKey("@user2"), &slackUserMessage{SentAt: time.Now().Add(-time.Hour)})
+	handler.store.Set(handler.userMessageKey("@user3"), &slackUserMessage{SentAt: time.Now().Add(-21 * time.Hour)})
+
+	sectionsByUser, err := handler.buildUserSlackMessages([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	assert.Len(t, sectionsByUser, 1)
+	assert.Contains(t, sectionsByUser, "@user3")
+}
+
+func TestNotifyDeferredUserInDeltaMode(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	deferredUser := config.User{Name: "user2", SlackUsername: "@user2"}
+	availableUser := config.User{Name: "user3", SlackUsername: "@user3"}
+	pullRequest := &hosts.PullRequest{Title: "pr1", Link: "link1.com", Reviewers: []*hosts.Reviewer{{User: deferredUser}, {User: availableUser}}}
+	mockRepository := newMockRepository(ctrl, []*hosts.PullRequest{}, []*hosts.PullRequest{pullRequest}, []*hosts.PullRequest{})
+	mockRepository.EXPECT().HasPullRequestsToDisplay().Return(true).AnyTimes()
+
+	client := &mockSlackClient{}
+	handler := newTestSlackMessageHandler(t)
+	handler.client = client
+	handler.channel = "#my-channel"
+	handler.messageUsers = true
+	handler.userMessagePeriod = 20 * time.Hour
+	handler.teamConfig = &config.TeamConfig{Name: "my-team"}
+	handler.teamConfig.Messaging.Delta.Enabled = true
+	now := time.Now()
+	state.SetTeamState(handler.store, "my-team", &state.TeamState{LastRun: now.Add(-time.Hour), LastDigest: now.Add(-time.Hour)})
+	handler.store.Set(handler.userMessageKey("@user2"), &slackUserMessage{SentAt: now.Add(-time.Hour)})
+
+	// The message to user2 is held back since they were messaged during the user message period
+	assert.Nil(t, handler.Notify([]hosts.Repository{mockRepository}))
+	assert.ElementsMatch(t, []mockSlackMessage{{channel: "#my-channel"}, {channel: "@user3"}}, client.messages)
+	pullRequestState, _ := state.GetPullRequestState(handler.store, "link1.com")
+	assert.False(t, pullRequestState.GetNotification(handler.channelMessageKey()).Time.IsZero())
+	assert.False(t, pullRequestState.GetNotification(handler.userMessageKey("@user3")).Time.IsZero())
+	assert.True(t, pullRequestState.GetNotification(handler.userMessageKey("@user2")).Time.IsZero())
+
+	// On the next run, the pull request didn't change but user2 was never notified about it
+	state.SetTeamState(handler.store, "my-team", &state.TeamState{LastRun: now, LastDigest: now.Add(-time.Hour)})
+	handler.store.Set(handler.userMessageKey("@user2"), &slackUserMessage{SentAt: now.Add(-21 * time.Hour)})
+	pullRequest.State = pullRequestState
+	client.messages = nil
+	assert.Nil(t, handler.Notify([]hosts.Repository{mockRepository}))
+	assert.Equal(t, []mockSlackMessage{{channel: "@user2"}}, client.messages)
+}
+
+func TestBuildSlackMessagesWithAbsentUsers(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	onVacation := config.User{Name: "user1", SlackUsername: "@user1"}
+	awayOnSlack := config.User{Name: "user2", SlackUsername: "@user2"}
+	present := config.User{Name: "user3", SlackUsername: "@user3"}
+	mockRepository := newMockRepository(ctrl,
+		[]*hosts.PullRequest{{Title: "merge", Link: "link1.com", Author: onVacation}},
+		[]*hosts.PullRequest{
+			{Title: "absent reviewers", Link: "link2.com", Author: present, Reviewers: []*hosts.Reviewer{{User: onVacation, Absent: true}, {User: awayOnSlack}}},
+			{Title: "present reviewer", Link: "link3.com", Author: present, Reviewers: []*hosts.Reviewer{{User: awayOnSlack}, {User: present}}},
+		},
+		[]*hosts.PullRequest{},
+	)
+
+	handler := newTestSlackMessageHandler(t)
+	handler.teamConfig = &config.TeamConfig{Users: []config.User{
+		{Name: "user1", OutOfOffice: []config.DateRange{{From: time.Now().AddDate(0, 0, -1).Format("2006-01-02"), To: time.Now().AddDate(0, 0, 1).Format("2006-01-02")}}},
+	}}
+	handler.awayStatusEmojis = []string{":palm_tree:", ":face_with_thermometer:"}
+	handler.users = newSlackUserResolver(&mockSlackClient{users: []slack.User{
+		{ID: "U1", Name: "user1"},
+		{ID: "U2", Name: "user2", Profile: slack.UserProfile{StatusEmoji: ":face_with_thermometer:"}},
+		{ID: "U3", Name: "user3"},
+	}}, true)
+	handler.templates, _ = newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{PullRequest: "{{ .Title }}{{ if .NeedsReassignment }} (needs reassignment){{ end }}"})
+
+	message, err := handler.buildChannelSlackMessage([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	sections := message.blocks()
+	assert.Equal(t, "absent reviewers (needs reassignment)", sections[6].(*slack.SectionBlock).Text.Text)
+	assert.Equal(t, "present reviewer", sections[7].(*slack.SectionBlock).Text.Text)
+
+	// Absent users are not messaged, even about their own pull requests
+	sectionsByUser, err := handler.buildUserSlackMessages([]hosts.Repository{mockRepository})
+	assert.Nil(t, err)
+	assert.Len(t, sectionsByUser, 1)
+	assert.Contains(t, sectionsByUser, "U3")
+	// The Slack status is only known to the Slack handler, it is not written to the pull requests shared with the other handlers
+	_, readyToReview, _ := mockRepository.GetPullRequestsToDisplay()
+	assert.False(t, readyToReview[0].Reviewers[1].Absent)
+	assert.False(t, readyToReview[1].Reviewers[0].Absent)
+}
+
+func newMockRepository(ctrl *gomock.Controller, readyToMerge, readyToReview, changesRequested []*hosts.PullRequest) *hosts.MockRepository {
+	mockHost := hosts.NewMockHost(ctrl)
+	mockHost.EXPECT().GetName().Return("mock").AnyTimes()
+
+	mockRepository := hosts.NewMockRepository(ctrl)
+	mockRepository.EXPECT().GetHost().Return(mockHost).AnyTimes()
+	mockRepository.EXPECT().GetLink().Return("mock-repo.com").AnyTimes()
+	mockRepository.EXPECT().GetName().Return("mock-repo").AnyTimes()
+	mockRepository.EXPECT().GetPullRequestsToDisplay().Return(readyToMerge, readyToReview, changesRequested).AnyTimes()
+	return mockRepository
+}
+
+func newTestSlackMessageHandler(t *testing.T) *slackMessageHandler {
+	templates, err := newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{})
+	assert.Nil(t, err)
+	return &slackMessageHandler{
+		teamName:           "my-team",
+		templates:          templates,
+		channelMessageMode: newMessageMode,
+		rolloverPeriod:     24 * time.Hour,
+		store:              state.NewMemoryStore(),
+		users:              newSlackUserResolver(nil, false),
+		teamConfig:         &config.TeamConfig{},
+	}
+}
+
+type mockSlackMessage struct {
+	channel          string
+	threadTimestamp  string
+	updatedTimestamp string
+	deletedTimestamp string
+}
+
+type mockSlackClient struct {
+	messages []mockSlackMessage
+	posted   int
+	// deleted are the timestamps of the messages that were deleted from Slack and can't be updated anymore
+	deleted map[string]bool
+
+	users          []slack.User
+	usersByEmail   map[string]string
+	getUsersCalled int
+}
+
+func (client *mockSlackClient) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	client.messages = append(client.messages, mockSlackMessage{channel: channelID, threadTimestamp: values.Get("thread_ts")})
+	client.posted++
+	return "C123", fmt.Sprintf("123.%d", 455+client.posted), err
+}
+
+func (client *mockSlackClient) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	if client.deleted[timestamp] {
+		return "", "", "", fmt.Errorf(slackMessageNotFoundError)
+	}
+	client.messages = append(client.messages, mockSlackMessage{channel: channelID, updatedTimestamp: timestamp})
+	return channelID, timestamp, "", nil
+}
+
+func (client *mockSlackClient) DeleteMessage(channelID, timestamp string) (string, string, error) {
+	if client.deleted[timestamp] {
+		return "", "", fmt.Errorf(slackMessageNotFoundError)
+	}
+	client.messages = append(client.messages, mockSlackMessage{channel: channelID, deletedTimestamp: timestamp})
+	return channelID, timestamp, nil
+}
+
+func (client *mockSlackClient) GetUserByEmail(email string) (*slack.User, error) {
+	if id, ok := client.usersByEmail[email]; ok {
+		return &slack.User{ID: id}, nil
+	}
+	return nil, fmt.Errorf("users_not_found")
+}
+
+func (client *mockSlackClient) GetUsers() ([]slack.User, error) {
+	client.getUsersCalled++
+	return client.users, nil
+}
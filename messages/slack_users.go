@@ -0,0 +1,140 @@
+package messages
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+)
+
+// slackUser is a team member's identity on Slack
+type slackUser struct {
+	// ID is the Slack user ID. It is empty if the user could not be resolved
+	ID string
+	// Username is the configured slack_username. It is used when the ID is not known
+	Username string
+	// StatusEmoji is the user's current Slack status emoji (ex: :palm_tree:)
+	StatusEmoji string
+}
+
+// destination returns the channel to use to send a direct message to the user
+func (user slackUser) destination() string {
+	if user.ID != "" {
+		return user.ID
+	}
+	return user.Username
+}
+
+// mention returns the text used to mention the user in a message
+func (user slackUser) mention() string {
+	if user.ID != "" {
+		return fmt.Sprintf("<@%s>", user.ID)
+	}
+	return user.Username
+}
+
+// slackUserResolver finds the Slack user ID of team members from their email, their username or their name.
+// Results are cached for the lifetime of the resolver
+type slackUserResolver struct {
+	client  slackClient
+	enabled bool
+
+	slackUsers []slack.User
+	cache      map[string]slackUser
+}
+
+func newSlackUserResolver(client slackClient, enabled bool) *slackUserResolver {
+	return &slackUserResolver{client: client, enabled: enabled, cache: map[string]slackUser{}}
+}
+
+func (resolver *slackUserResolver) resolve(user config.User) slackUser {
+	resolvedUser := slackUser{Username: user.SlackUsername}
+	if !resolver.enabled {
+		return resolvedUser
+	}
+	if cachedUser, ok := resolver.cache[user.Name]; ok {
+		return cachedUser
+	}
+
+	if user.Email != "" {
+		if foundUser, err := resolver.client.GetUserByEmail(user.Email); err != nil {
+			log.WithError(err).Warningf("Unable to find the Slack user of %s by email", user.Name)
+		} else {
+			resolvedUser.ID, resolvedUser.StatusEmoji = foundUser.ID, getStatusEmoji(foundUser)
+		}
+	}
+	if resolvedUser.ID == "" {
+		if foundUser := resolver.findInUserList(user); foundUser != nil {
+			resolvedUser.ID, resolvedUser.StatusEmoji = foundUser.ID, getStatusEmoji(foundUser)
+		}
+	}
+	if resolvedUser.ID == "" {
+		log.Warningf("Unable to resolve the Slack user of %s", user.Name)
+	}
+
+	resolver.cache[user.Name] = resolvedUser
+	return resolvedUser
+}
+
+// findInUserList matches the configured username and then the user's name (accents, case and punctuation are ignored)
+// with the Slack users' handles, display names and real names
+func (resolver *slackUserResolver) findInUserList(user config.User) *slack.User {
+	if resolver.slackUsers == nil {
+		slackUsers, err := resolver.client.GetUsers()
+		if err != nil {
+			log.WithError(err).Warningln("Unable to list Slack users")
+			return nil
+		}
+		resolver.slackUsers = []slack.User{}
+		for _, slackUser := range slackUsers {
+			if !slackUser.Deleted && !slackUser.IsBot {
+				resolver.slackUsers = append(resolver.slackUsers, slackUser)
+			}
+		}
+	}
+
+	if username := strings.TrimPrefix(user.SlackUsername, "@"); username != "" {
+		for index, slackUser := range resolver.slackUsers {
+			if slackUser.Name == username {
+				return &resolver.slackUsers[index]
+			}
+		}
+	}
+
+	normalizedName := utilities.NormalizeName(user.Name)
+	if normalizedName == "" {
+		return nil
+	}
+	matches := []*slack.User{}
+	for index, slackUser := range resolver.slackUsers {
+		for _, name := range []string{slackUser.Profile.DisplayName, slackUser.Profile.RealName, slackUser.RealName} {
+			if utilities.NormalizeName(name) == normalizedName {
+				matches = append(matches, &resolver.slackUsers[index])
+				break
+			}
+		}
+	}
+	if len(matches) > 1 {
+		ids := []string{}
+		for _, match := range matches {
+			ids = append(ids, match.ID)
+		}
+		log.Warningf("%s matches multiple Slack users (%s). Please set the user's email or slack_username", user.Name, strings.Join(ids, ", "))
+		return nil
+	} else if len(matches) == 1 {
+		return matches[0]
+	}
+	return nil
+}
+
+// getStatusEmoji returns the user's status emoji, unless it has expired
+func getStatusEmoji(user *slack.User) string {
+	if user.Profile.StatusExpiration > 0 && time.Now().Unix() >= int64(user.Profile.StatusExpiration) {
+		return ""
+	}
+	return user.Profile.StatusEmoji
+}
@@ -0,0 +1,110 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSlackUsers(t *testing.T) {
+	t.Parallel()
+
+	newSlackUser := func(id, name, displayName, realName string) slack.User {
+		user := slack.User{ID: id, Name: name, RealName: realName}
+		user.Profile.DisplayName = displayName
+		user.Profile.RealName = realName
+		return user
+	}
+	deletedUser := newSlackUser("U0", "jdoe", "", "John Doe")
+	deletedUser.Deleted = true
+
+	client := &mockSlackClient{
+		usersByEmail: map[string]string{"jdoe@example.com": "U1"},
+		users: []slack.User{
+			deletedUser,
+			newSlackUser("U2", "jdoe", "", ""),
+			newSlackUser("U3", "frank", "Frank", "François Bélanger"),
+			newSlackUser("U4", "alex1", "", "Alex Smith"),
+			newSlackUser("U5", "alex2", "Alex Smith", ""),
+		},
+	}
+	resolver := newSlackUserResolver(client, true)
+
+	cases := []struct {
+		name         string
+		user         config.User
+		expectedUser slackUser
+	}{
+		{
+			name:         "By email",
+			user:         config.User{Name: "John Doe", Email: "jdoe@example.com", SlackUsername: "@stale"},
+			expectedUser: slackUser{ID: "U1", Username: "@stale"},
+		},
+		{
+			name:         "Email not found, by username",
+			user:         config.User{Name: "John Doe 2", Email: "unknown@example.com", SlackUsername: "@jdoe"},
+			expectedUser: slackUser{ID: "U2", Username: "@jdoe"},
+		},
+		{
+			name:         "By name without accents",
+			user:         config.User{Name: "francois belanger"},
+			expectedUser: slackUser{ID: "U3"},
+		},
+		{
+			name:         "Ambiguous name",
+			user:         config.User{Name: "Alex Smith", SlackUsername: "@asmith"},
+			expectedUser: slackUser{Username: "@asmith"},
+		},
+		{
+			name:         "Not found",
+			user:         config.User{Name: "Nobody"},
+			expectedUser: slackUser{},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedUser, resolver.resolve(tt.user))
+		})
+	}
+	assert.Equal(t, 1, client.getUsersCalled) // The user list is cached
+}
+
+func TestResolveSlackUsersDisabled(t *testing.T) {
+	t.Parallel()
+
+	user := newSlackUserResolver(nil, false).resolve(config.User{Name: "John Doe", Email: "jdoe@example.com", SlackUsername: "@jdoe"})
+	assert.Equal(t, slackUser{Username: "@jdoe"}, user)
+	assert.Equal(t, "@jdoe", user.destination())
+	assert.Equal(t, "@jdoe", user.mention())
+
+	user = slackUser{ID: "U1", Username: "@jdoe"}
+	assert.Equal(t, "U1", user.destination())
+	assert.Equal(t, "<@U1>", user.mention())
+}
+
+func TestResolveSlackUserStatus(t *testing.T) {
+	t.Parallel()
+
+	newSlackUser := func(id, name, statusEmoji string, statusExpiration int) slack.User {
+		user := slack.User{ID: id, Name: name}
+		user.Profile.StatusEmoji = statusEmoji
+		user.Profile.StatusExpiration = statusExpiration
+		return user
+	}
+	client := &mockSlackClient{
+		users: []slack.User{
+			newSlackUser("U1", "away", ":palm_tree:", 0),
+			newSlackUser("U2", "back", ":palm_tree:", int(time.Now().Add(-time.Hour).Unix())),
+			newSlackUser("U3", "still_away", ":palm_tree:", int(time.Now().Add(time.Hour).Unix())),
+		},
+	}
+	resolver := newSlackUserResolver(client, true)
+
+	assert.Equal(t, ":palm_tree:", resolver.resolve(config.User{Name: "Away", SlackUsername: "@away"}).StatusEmoji)
+	assert.Equal(t, "", resolver.resolve(config.User{Name: "Back", SlackUsername: "@back"}).StatusEmoji) // Expired status
+	assert.Equal(t, ":palm_tree:", resolver.resolve(config.User{Name: "Still Away", SlackUsername: "@still_away"}).StatusEmoji)
+}
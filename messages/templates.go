@@ -0,0 +1,256 @@
+package messages
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/julienduchesne/pull-request-reminder/utilities"
+)
+
+const (
+	readyToMergeCategory  = hosts.ReadyToMergeCategory
+	readyToReviewCategory = hosts.ReadyToReviewCategory
+	// changesRequestedCategory contains the pull requests waiting on their author
+	changesRequestedCategory = hosts.ChangesRequestedCategory
+)
+
+var defaultTemplates = config.MessageTemplates{
+	Header:           "Hello, here are the pull requests requiring your attention today:",
+	Continued:        "(continued)",
+	Repository:       "[{{ .Host }}] *<{{ .Link }}|{{ .Name }}>*",
+	Group:            "*{{ with .Name }}{{ . }}{{ else }}Others{{ end }}*",
+	ReadyToMerge:     ":heavy_check_mark: Pull requests awaiting merge",
+	ReadyToReview:    ":no_entry: Pull requests still in need of approvers",
+	ChangesRequested: ":pencil2: Pull requests waiting on their author (changes requested)",
+	PullRequest: "{{ if .ShowRepository }}[{{ .Repository.Name }}] {{ end }}{{ if and .LinkAuthor .AuthorMention }}{{ .AuthorMention }}: {{ end }}<{{ .Link }}|{{ .Title }}>{{ if .ClaimedBy }} (claimed by {{ .ClaimedBy }}){{ end }}" +
+		"{{ if .NeedsReassignment }} :warning: needs reassignment{{ end }}" +
+		"{{ with .Mentions }} cc {{ join . \" \" }}{{ end }}" +
+		"{{ if .Age }}\n:clock3: Opened {{ humanizeDuration .Age }} ago, updated {{ humanizeDuration .StaleFor }} ago" +
+		" | {{ .Approvals }}/{{ .NeededApprovals }} approvals" +
+		"{{ with .ApprovedBy }} | :white_check_mark: {{ join . \", \" }}{{ end }}" +
+		"{{ with .RequestedChangesBy }} | :x: {{ join . \", \" }}{{ end }}" +
+		"{{ with .PendingReviewers }} | :hourglass: {{ join . \", \" }}{{ end }}" +
+		"{{ with .AbsentReviewers }} | :palm_tree: {{ join . \", \" }}{{ end }}{{ end }}",
+	Escalation: ":rotating_light: Pull requests waiting for more than {{ humanizeDuration .After }}{{ with .Mentions }} {{ join . \" \" }}{{ end }}",
+	Ignored:    "{{ .Count }} pull request{{ if ne .Count 1 }}s{{ end }} ignored: {{ range $index, $reason := .Reasons }}{{ if $index }}, {{ end }}{{ .Count }} {{ .Label }}{{ end }}",
+}
+
+var templateFuncs = template.FuncMap{
+	"humanizeDuration": utilities.HumanizeDuration,
+	"join":             strings.Join,
+	"lower":            strings.ToLower,
+	"upper":            strings.ToUpper,
+}
+
+// headerData is given to the header and continued templates
+type headerData struct {
+	Team             string
+	Repositories     []repositoryData
+	PullRequestCount int
+}
+
+// repositoryData is given to the repository template
+type repositoryData struct {
+	Host string
+	Link string
+	Name string
+}
+
+// groupData is given to the group template, used when pull requests are not grouped by repository
+type groupData struct {
+	Team string
+	// GroupBy is what the pull requests are grouped by: author, reviewer or host
+	GroupBy string
+	// Name is the name of the author, reviewer or host. It is empty for pull requests from non-members or without pending reviewers
+	Name string
+}
+
+// escalationData is given to the escalation template
+type escalationData struct {
+	Team string
+	// After is the delay of the escalation step
+	After time.Duration
+	// Mentions are the mentions of the escalation step. They are only set in channel posts
+	Mentions         []string
+	PullRequestCount int
+}
+
+// ignoredData is given to the ignored template
+type ignoredData struct {
+	Team  string
+	Count int
+	// Reasons are the reasons why pull requests were ignored, the most frequent first
+	Reasons []ignoredReasonData
+}
+
+type ignoredReasonData struct {
+	// Reason is the reason's identifier (ex: no_reviewers) and Label its text (ex: no reviewers)
+	Reason string
+	Label  string
+	Count  int
+}
+
+// categoryData is given to the ready to merge, ready to review and changes requested templates
+type categoryData struct {
+	Team         string
+	Repository   repositoryData
+	Category     string
+	PullRequests []pullRequestData
+}
+
+// pullRequestData is given to the pull request template
+type pullRequestData struct {
+	Title       string
+	Link        string
+	Description string
+	Author      config.User
+	// AuthorMention is the text used to mention the author (ex: <@U1234> on Slack)
+	AuthorMention string
+	Reviewers     []*hosts.Reviewer
+	// Names of the team members who approved, requested changes, have not reviewed yet or are out of office
+	ApprovedBy         []string
+	RequestedChangesBy []string
+	PendingReviewers   []string
+	AbsentReviewers    []string
+	Approvals          int
+	NeededApprovals    int
+	Category           string
+	Repository         repositoryData
+	// ShowRepository is true when pull requests are not grouped by repository
+	ShowRepository bool
+	Age            time.Duration
+	StaleFor       time.Duration
+	LinkAuthor     bool
+	// Mentions are the users or groups that should be called out (ex: pending reviewers in a channel message)
+	Mentions []string
+	// ClaimedBy is the mention of the user who claimed the pull request
+	ClaimedBy string
+	// NeedsReassignment is true if the pull request needing review is only waiting on absent reviewers
+	NeedsReassignment bool
+}
+
+type messageTemplates struct {
+	header           *template.Template
+	continued        *template.Template
+	repository       *template.Template
+	group            *template.Template
+	readyToMerge     *template.Template
+	readyToReview    *template.Template
+	changesRequested *template.Template
+	pullRequest      *template.Template
+	escalation       *template.Template
+	ignored          *template.Template
+}
+
+// newMessageTemplates parses the default templates, overridden by the team's templates and then by the handler's templates
+func newMessageTemplates(teamTemplates, handlerTemplates config.MessageTemplates) (*messageTemplates, error) {
+	merged := defaultTemplates.Merge(teamTemplates).Merge(handlerTemplates)
+	templates := &messageTemplates{}
+	for _, parsed := range []struct {
+		name     string
+		text     string
+		template **template.Template
+	}{
+		{"header", merged.Header, &templates.header},
+		{"continued", merged.Continued, &templates.continued},
+		{"repository", merged.Repository, &templates.repository},
+		{"group", merged.Group, &templates.group},
+		{"ready_to_merge", merged.ReadyToMerge, &templates.readyToMerge},
+		{"ready_to_review", merged.ReadyToReview, &templates.readyToReview},
+		{"changes_requested", merged.ChangesRequested, &templates.changesRequested},
+		{"pull_request", merged.PullRequest, &templates.pullRequest},
+		{"escalation", merged.Escalation, &templates.escalation},
+		{"ignored", merged.Ignored, &templates.ignored},
+	} {
+		var err error
+		if *parsed.template, err = template.New(parsed.name).Funcs(templateFuncs).Parse(parsed.text); err != nil {
+			return nil, fmt.Errorf("Unable to parse the %s template: %v", parsed.name, err)
+		}
+	}
+	return templates, nil
+}
+
+func (templates *messageTemplates) categoryTemplate(category string) *template.Template {
+	switch category {
+	case readyToMergeCategory:
+		return templates.readyToMerge
+	case changesRequestedCategory:
+		return templates.changesRequested
+	}
+	return templates.readyToReview
+}
+
+func render(tmpl *template.Template, data interface{}) (string, error) {
+	buffer := &bytes.Buffer{}
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", fmt.Errorf("Unable to render the %s template: %v", tmpl.Name(), err)
+	}
+	return buffer.String(), nil
+}
+
+func newRepositoryData(repository hosts.Repository) repositoryData {
+	return repositoryData{
+		Host: repository.GetHost().GetName(),
+		Link: repository.GetLink(),
+		Name: repository.GetName(),
+	}
+}
+
+// isReviewerAbsent returns true if the reviewer is out of office according to the team's configuration
+func isReviewerAbsent(reviewer *hosts.Reviewer) bool {
+	return reviewer.Absent
+}
+
+// newPullRequestData returns the data given to the pull request template. Reviewers are reported absent according to the given function
+func newPullRequestData(repository repositoryData, category string, linkAuthor bool, neededApprovals int, pullRequest *hosts.PullRequest, isAbsent func(*hosts.Reviewer) bool) pullRequestData {
+	data := pullRequestData{
+		Title:         pullRequest.Title,
+		Link:          pullRequest.Link,
+		Description:   pullRequest.Description,
+		Author:        pullRequest.Author,
+		AuthorMention: pullRequest.Author.SlackUsername,
+		Reviewers:     pullRequest.Reviewers,
+		Category:      category,
+		Repository:    repository,
+		LinkAuthor:    linkAuthor,
+		Mentions:      []string{},
+
+		ApprovedBy:         []string{},
+		RequestedChangesBy: []string{},
+		PendingReviewers:   []string{},
+		AbsentReviewers:    []string{},
+		NeededApprovals:    neededApprovals,
+	}
+	for _, reviewer := range pullRequest.Reviewers {
+		if reviewer.User.Name == "" {
+			continue // Not a team member
+		}
+		switch {
+		case reviewer.Approved:
+			data.ApprovedBy = append(data.ApprovedBy, reviewer.User.Name)
+		case reviewer.RequestedChanges:
+			data.RequestedChangesBy = append(data.RequestedChangesBy, reviewer.User.Name)
+		case isAbsent(reviewer):
+			data.AbsentReviewers = append(data.AbsentReviewers, reviewer.User.Name)
+		default:
+			data.PendingReviewers = append(data.PendingReviewers, reviewer.User.Name)
+		}
+	}
+	data.Approvals = len(data.ApprovedBy)
+	data.NeedsReassignment = category == readyToReviewCategory && len(data.PendingReviewers) == 0 && len(data.AbsentReviewers) > 0
+	if !pullRequest.CreateTime.IsZero() {
+		data.Age = time.Since(pullRequest.CreateTime)
+	}
+	if !pullRequest.UpdateTime.IsZero() {
+		data.StaleFor = time.Since(pullRequest.UpdateTime)
+	}
+	if pullRequest.State != nil && pullRequest.State.ClaimedByUsername != "" {
+		data.ClaimedBy = "@" + pullRequest.State.ClaimedByUsername
+	}
+	return data
+}
@@ -0,0 +1,93 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/hosts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderPullRequestTemplate(t *testing.T) {
+	t.Parallel()
+
+	templates, err := newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{})
+	assert.Nil(t, err)
+
+	pullRequest := &hosts.PullRequest{Title: "pr1", Link: "link1.com", Author: config.User{SlackUsername: "@jdoe"}}
+	text, err := render(templates.pullRequest, newPullRequestData(repositoryData{}, readyToMergeCategory, true, 1, pullRequest, isReviewerAbsent))
+	assert.Nil(t, err)
+	assert.Equal(t, "@jdoe: <link1.com|pr1>", text)
+
+	text, err = render(templates.pullRequest, newPullRequestData(repositoryData{}, readyToMergeCategory, false, 1, pullRequest, isReviewerAbsent))
+	assert.Nil(t, err)
+	assert.Equal(t, "<link1.com|pr1>", text)
+}
+
+func TestRenderTemplateError(t *testing.T) {
+	t.Parallel()
+
+	templates, err := newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{PullRequest: "{{ .Unknown }}"})
+	assert.Nil(t, err)
+
+	_, err = render(templates.pullRequest, pullRequestData{})
+	assert.Error(t, err)
+}
+
+func TestRenderPullRequestDetails(t *testing.T) {
+	t.Parallel()
+
+	templates, err := newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{})
+	assert.Nil(t, err)
+
+	pullRequest := &hosts.PullRequest{
+		Title:      "pr1",
+		Link:       "link1.com",
+		CreateTime: time.Now().Add(-73 * time.Hour),
+		UpdateTime: time.Now().Add(-5 * time.Hour),
+		Reviewers: []*hosts.Reviewer{
+			{Approved: true, User: config.User{Name: "Alice"}},
+			{RequestedChanges: true, User: config.User{Name: "Bob"}},
+			{User: config.User{Name: "Carol"}},
+			{User: config.User{Name: "Dan"}},
+			{Approved: true, User: config.User{}}, // Not from the team
+		},
+	}
+	data := newPullRequestData(repositoryData{}, readyToReviewCategory, false, 2, pullRequest, isReviewerAbsent)
+	assert.Equal(t, 1, data.Approvals)
+	assert.Equal(t, []string{"Carol", "Dan"}, data.PendingReviewers)
+
+	text, err := render(templates.pullRequest, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "<link1.com|pr1>\n:clock3: Opened 3 days ago, updated 5 hours ago | 1/2 approvals | :white_check_mark: Alice | :x: Bob | :hourglass: Carol, Dan", text)
+}
+
+func TestRenderPullRequestWithAbsentReviewers(t *testing.T) {
+	t.Parallel()
+
+	templates, err := newMessageTemplates(config.MessageTemplates{}, config.MessageTemplates{})
+	assert.Nil(t, err)
+
+	pullRequest := &hosts.PullRequest{
+		Title:      "pr1",
+		Link:       "link1.com",
+		CreateTime: time.Now().Add(-2 * time.Hour),
+		UpdateTime: time.Now().Add(-2 * time.Hour),
+		Reviewers: []*hosts.Reviewer{
+			{Approved: true, Absent: true, User: config.User{Name: "Alice"}},
+			{Absent: true, User: config.User{Name: "Bob"}},
+		},
+	}
+	data := newPullRequestData(repositoryData{}, readyToReviewCategory, false, 2, pullRequest, isReviewerAbsent)
+	assert.Equal(t, []string{}, data.PendingReviewers)
+	assert.Equal(t, []string{"Bob"}, data.AbsentReviewers)
+	assert.True(t, data.NeedsReassignment)
+
+	text, err := render(templates.pullRequest, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "<link1.com|pr1> :warning: needs reassignment\n:clock3: Opened 2 hours ago, updated 2 hours ago | 1/2 approvals | :white_check_mark: Alice | :palm_tree: Bob", text)
+
+	// Only pull requests needing review can be reassigned
+	assert.False(t, newPullRequestData(repositoryData{}, readyToMergeCategory, false, 2, pullRequest, isReviewerAbsent).NeedsReassignment)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/julienduchesne/pull-request-reminder/api"
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/messages"
+	"github.com/julienduchesne/pull-request-reminder/state"
+)
+
+const (
+	// configReloadInterval is the longest time the daemon waits before reloading the configuration
+	configReloadInterval = 5 * time.Minute
+	shutdownTimeout      = 30 * time.Second
+)
+
+// scheduledRun is the next run of a team, computed from its cron expression
+type scheduledRun struct {
+	cron string
+	at   time.Time
+}
+
+// daemon runs each team on its own cron schedule. The configuration is reloaded between runs
+type daemon struct {
+	readConfig func() (*config.GlobalConfig, error)
+	newStore   func(string) (state.Store, error)
+	runTeam    func(*config.TeamConfig, state.Store) error
+
+	// config and store are replaced on reload. The mutex guards them since they are also read by the HTTP handlers
+	mutex         sync.RWMutex
+	config        *config.GlobalConfig
+	store         state.Store
+	scheduledRuns map[string]scheduledRun
+	status        *status
+	snapshots     *api.Snapshots
+}
+
+func newDaemon(readConfig func() (*config.GlobalConfig, error), newStore func(string) (state.Store, error), dryRun *messages.DryRun) (*daemon, error) {
+	globalConfig, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	store, err := newStore(globalConfig.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := api.NewSnapshots()
+	return &daemon{
+		readConfig: readConfig,
+		newStore:   newStore,
+		runTeam: func(team *config.TeamConfig, store state.Store) error {
+			return runTeam(team, store, snapshots, dryRun)
+		},
+		config:        globalConfig,
+		store:         store,
+		scheduledRuns: map[string]scheduledRun{},
+		status:        newStatus(),
+		snapshots:     snapshots,
+	}, nil
+}
+
+// serve runs the daemon until SIGTERM or SIGINT is received. In dry-run mode, the messages are rendered instead of being sent
+func serve(readConfig func() (*config.GlobalConfig, error), dryRun *messages.DryRun) error {
+	newStore := state.NewStore
+	if dryRun != nil {
+		newStore = newReadOnlyStore
+	}
+	daemon, err := newDaemon(readConfig, newStore, dryRun)
+	if err != nil {
+		return fmt.Errorf("Error while initializing the daemon: %v", err)
+	}
+
+	servers := daemon.newServers(dryRun)
+	for _, server := range servers {
+		go func(server *http.Server) {
+			log.Infof("Listening on %s", server.Addr)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.WithError(err).Fatalf("Error while listening on %s", server.Addr)
+			}
+		}(server)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	daemon.run(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.WithError(err).Warningf("Error while stopping the server on %s", server.Addr)
+		}
+	}
+	log.Infoln("Stopped")
+	return nil
+}
+
+// newServers returns the server of the interactions and the other endpoints if the listen address is set, and the server of
+// the API and the dashboard if the internal listen address is set. The program doesn't listen in dry-run mode,
+// so that real Slack interactions are not handled
+func (daemon *daemon) newServers(dryRun *messages.DryRun) []*http.Server {
+	servers := []*http.Server{}
+	if dryRun != nil {
+		return servers
+	}
+	if daemon.config.ListenAddress != "" {
+		servers = append(servers, &http.Server{Addr: daemon.config.ListenAddress, Handler: newServeMux(daemon.current, daemon.status, daemon.snapshots)})
+	}
+	if daemon.config.InternalListenAddress != "" {
+		servers = append(servers, &http.Server{Addr: daemon.config.InternalListenAddress, Handler: newInternalServeMux(daemon.snapshots)})
+	}
+	return servers
+}
+
+// current returns the configuration and the store of the daemon, as of the last reload
+func (daemon *daemon) current() (*config.GlobalConfig, state.Store) {
+	daemon.mutex.RLock()
+	defer daemon.mutex.RUnlock()
+	return daemon.config, daemon.store
+}
+
+// run runs the teams when they are due until a signal is received. A run in progress is completed before stopping
+func (daemon *daemon) run(stop <-chan os.Signal) {
+	for {
+		daemon.status.beat(time.Now())
+		wakeUp := time.Now().Add(configReloadInterval)
+		if nextRun := daemon.schedule(time.Now()); !nextRun.IsZero() && nextRun.Before(wakeUp) {
+			wakeUp = nextRun
+		}
+		daemon.status.setReady()
+		timer := time.NewTimer(time.Until(wakeUp))
+		select {
+		case receivedSignal := <-stop:
+			timer.Stop()
+			log.Infof("Received %v, stopping", receivedSignal)
+			return
+		case <-timer.C:
+		}
+
+		daemon.reload()
+		daemon.schedule(time.Now())
+		if !daemon.runDueTeams(time.Now(), stop) {
+			return
+		}
+	}
+}
+
+// reload reads the configuration again. The previous configuration is kept if the new one is invalid
+func (daemon *daemon) reload() {
+	globalConfig, err := daemon.readConfig()
+	daemon.status.setConfigError(err)
+	if err != nil {
+		log.WithError(err).Errorln("Error while reloading the configuration. The previous configuration is kept")
+		return
+	}
+	store := daemon.store
+	if globalConfig.StatePath != daemon.config.StatePath {
+		store, err = daemon.newStore(globalConfig.StatePath)
+		daemon.status.setConfigError(err)
+		if err != nil {
+			log.WithError(err).Errorln("Error while initializing the state store. The previous configuration is kept")
+			return
+		}
+	}
+	daemon.mutex.Lock()
+	defer daemon.mutex.Unlock()
+	daemon.config, daemon.store = globalConfig, store
+}
+
+// schedule computes the next run of the teams that were added or whose cron expression changed and forgets the removed teams.
+// It returns the earliest run, or zero if no team is scheduled
+func (daemon *daemon) schedule(now time.Time) time.Time {
+	scheduledRuns := map[string]scheduledRun{}
+	var earliest time.Time
+	for _, team := range daemon.config.Teams {
+		scheduled, ok := daemon.scheduledRuns[team.Name]
+		if !ok || scheduled.cron != team.Cron {
+			scheduled = scheduledRun{cron: team.Cron, at: team.NextRun(now)}
+			if scheduled.at.IsZero() {
+				log.Warningf("The %s team has no cron expression, it will not be run", team.Name)
+			} else {
+				log.Infof("The next run of the %s team is at %v", team.Name, scheduled.at)
+			}
+		}
+		scheduledRuns[team.Name] = scheduled
+		if !scheduled.at.IsZero() && (earliest.IsZero() || scheduled.at.Before(earliest)) {
+			earliest = scheduled.at
+		}
+	}
+	daemon.scheduledRuns = scheduledRuns
+	daemon.updateNextRuns()
+	return earliest
+}
+
+// runDueTeams runs the teams whose next run is due and schedules their following run.
+// It returns false if a signal was received, in which case the remaining teams are not run
+func (daemon *daemon) runDueTeams(now time.Time, stop <-chan os.Signal) bool {
+	for _, team := range daemon.config.Teams {
+		scheduled := daemon.scheduledRuns[team.Name]
+		if scheduled.at.IsZero() || scheduled.at.After(now) {
+			continue
+		}
+		select {
+		case receivedSignal := <-stop:
+			log.Infof("Received %v, stopping", receivedSignal)
+			return false
+		default:
+		}
+
+		log.Infof("Running the %s team", team.Name)
+		start := time.Now()
+		daemon.status.startRun(team.Name, start)
+		err := daemon.runTeam(team, daemon.store)
+		daemon.status.recordRun(team.Name, start, time.Now(), err)
+		daemon.status.beat(time.Now())
+		if err != nil {
+			log.WithError(err).Errorf("Error while running the %s team", team.Name)
+		}
+		scheduled.at = team.NextRun(time.Now())
+		daemon.scheduledRuns[team.Name] = scheduled
+		daemon.updateNextRuns()
+		log.Infof("The next run of the %s team is at %v", team.Name, scheduled.at)
+	}
+	return true
+}
+
+// updateNextRuns reports the scheduled runs in the status
+func (daemon *daemon) updateNextRuns() {
+	nextRuns := map[string]time.Time{}
+	for team, scheduled := range daemon.scheduledRuns {
+		nextRuns[team] = scheduled.at
+	}
+	daemon.status.setNextRuns(nextRuns)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/julienduchesne/pull-request-reminder/config"
+	"github.com/julienduchesne/pull-request-reminder/messages"
+	"github.com/julienduchesne/pull-request-reminder/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDaemon(t *testing.T, teams ...*config.TeamConfig) (*daemon, *[]string) {
+	globalConfig := &config.GlobalConfig{StatePath: "state", Teams: teams}
+	daemon, err := newDaemon(
+		func() (*config.GlobalConfig, error) { return globalConfig, nil },
+		func(string) (state.Store, error) { return state.NewMemoryStore(), nil },
+		nil,
+	)
+	assert.Nil(t, err)
+	runs := &[]string{}
+	daemon.runTeam = func(team *config.TeamConfig, store state.Store) error {
+		*runs = append(*runs, team.Name)
+		return errors.New("errors are logged")
+	}
+	return daemon, runs
+}
+
+func TestDaemonSchedule(t *testing.T) {
+	t.Parallel()
+
+	daemon, runs := newTestDaemon(t,
+		&config.TeamConfig{Name: "montreal", Cron: "0 9 * * *", Schedule: config.Schedule{Timezone: "America/Montreal"}},
+		&config.TeamConfig{Name: "tokyo", Cron: "0 9 * * *", Schedule: config.Schedule{Timezone: "Asia/Tokyo"}},
+		&config.TeamConfig{Name: "no-cron"},
+	)
+	now := time.Date(2019, 7, 22, 12, 0, 0, 0, time.UTC)
+	nextRun := daemon.schedule(now)
+	// 9am in Montreal is 1pm in UTC and 9am in Tokyo is midnight in UTC
+	assert.True(t, time.Date(2019, 7, 22, 13, 0, 0, 0, time.UTC).Equal(nextRun), nextRun.String())
+	assert.True(t, time.Date(2019, 7, 23, 0, 0, 0, 0, time.UTC).Equal(daemon.scheduledRuns["tokyo"].at))
+	assert.True(t, daemon.scheduledRuns["no-cron"].at.IsZero())
+
+	stop := make(chan os.Signal, 1)
+	assert.True(t, daemon.runDueTeams(now.Add(2*time.Hour), stop))
+	assert.Equal(t, []string{"montreal"}, *runs)
+	assert.True(t, daemon.scheduledRuns["montreal"].at.After(time.Now()))
+	assert.Equal(t, failureOutcome, daemon.status.teams["montreal"].Outcome)
+	assert.True(t, daemon.scheduledRuns["montreal"].at.Equal(*daemon.status.teams["montreal"].NextRun))
+	assert.Nil(t, daemon.status.teams["no-cron"].NextRun)
+
+	// A signal stops the remaining runs
+	stop <- syscall.SIGTERM
+	assert.False(t, daemon.runDueTeams(daemon.scheduledRuns["tokyo"].at, stop))
+	assert.Equal(t, []string{"montreal"}, *runs)
+}
+
+func TestDaemonReload(t *testing.T) {
+	t.Parallel()
+
+	daemon, _ := newTestDaemon(t,
+		&config.TeamConfig{Name: "team1", Cron: "0 9 * * *"},
+		&config.TeamConfig{Name: "team2", Cron: "0 9 * * *"},
+	)
+	now := time.Date(2019, 7, 22, 12, 0, 0, 0, time.UTC)
+	daemon.schedule(now)
+
+	// The new cron expressions and teams are scheduled. Removed teams are forgotten
+	daemon.readConfig = func() (*config.GlobalConfig, error) {
+		return &config.GlobalConfig{StatePath: "other-state", Teams: []*config.TeamConfig{
+			{Name: "team1", Cron: "0 9 * * *"},
+			{Name: "team2", Cron: "0 15 * * *"},
+			{Name: "team3", Cron: "0 16 * * *"},
+		}}, nil
+	}
+	previousStore := daemon.store
+	daemon.reload()
+	assert.True(t, previousStore != daemon.store)
+	later := now.Add(time.Hour)
+	nextRun := daemon.schedule(later)
+	assert.True(t, time.Date(2019, 7, 22, 15, 0, 0, 0, time.UTC).Equal(nextRun), nextRun.String())
+	assert.True(t, time.Date(2019, 7, 23, 9, 0, 0, 0, time.UTC).Equal(daemon.scheduledRuns["team1"].at))
+	assert.Len(t, daemon.scheduledRuns, 3)
+
+	// Invalid configurations are ignored
+	daemon.readConfig = func() (*config.GlobalConfig, error) { return nil, errors.New("invalid config") }
+	daemon.reload()
+	assert.Len(t, daemon.config.Teams, 3)
+	assert.Equal(t, "invalid config", daemon.status.configError)
+}
+
+func TestDaemonStopsOnSignal(t *testing.T) {
+	t.Parallel()
+
+	daemon, runs := newTestDaemon(t, &config.TeamConfig{Name: "team", Cron: "* * * * *"})
+	stop := make(chan os.Signal, 1)
+	stop <- syscall.SIGTERM
+	done := make(chan bool)
+	go func() {
+		daemon.run(stop)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The daemon did not stop")
+	}
+	assert.Empty(t, *runs)
+}
+
+func TestDaemonNewServers(t *testing.T) {
+	t.Parallel()
+
+	daemon, _ := newTestDaemon(t)
+	assert.Empty(t, daemon.newServers(nil)) // No listen address
+
+	daemon.config.ListenAddress = ":8080"
+	daemon.config.InternalListenAddress = "127.0.0.1:8081"
+	servers := daemon.newServers(nil)
+	assert.Len(t, servers, 2)
+	assert.Equal(t, ":8080", servers[0].Addr)
+	assert.Equal(t, "127.0.0.1:8081", servers[1].Addr)
+
+	// Real Slack interactions are not handled in dry-run mode
+	assert.Empty(t, daemon.newServers(&messages.DryRun{Writer: ioutil.Discard}))
+}
+
+func newSignedSnoozeRequest(signingSecret, link string) *http.Request {
+	payload := fmt.Sprintf(`{"type":"block_actions","user":{"id":"U123","username":"jdoe"},"actions":[{"action_id":"snooze","value":%q}]}`, link)
+	body := url.Values{"payload": {payload}}.Encode()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	hash := hmac.New(sha256.New, []byte(signingSecret))
+	hash.Write([]byte(fmt.Sprintf("v0:%s:%s", timestamp, body)))
+
+	request := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	request.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(hash.Sum(nil)))
+	return request
+}
+
+func TestDaemonServerUsesReloadedConfig(t *testing.T) {
+	t.Parallel()
+
+	team := &config.TeamConfig{Name: "team1"}
+	team.Messaging.Slack.SigningSecret = "old-secret"
+	daemon, _ := newTestDaemon(t, team)
+	daemon.config.ListenAddress = ":8080"
+	handler := daemon.newServers(nil)[0].Handler
+
+	reloadedTeam := &config.TeamConfig{Name: "team1"}
+	reloadedTeam.Messaging.Slack.SigningSecret = "new-secret"
+	daemon.readConfig = func() (*config.GlobalConfig, error) {
+		return &config.GlobalConfig{StatePath: "other-state", Teams: []*config.TeamConfig{reloadedTeam}}, nil
+	}
+	previousStore := daemon.store
+	daemon.reload()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newSignedSnoozeRequest("old-secret", "pr.com"))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newSignedSnoozeRequest("new-secret", "pr.com"))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	// The choice is kept in the new store
+	pullRequestState, err := state.GetPullRequestState(daemon.store, "pr.com")
+	assert.Nil(t, err)
+	assert.False(t, pullRequestState.SnoozedUntil.IsZero())
+	pullRequestState, err = state.GetPullRequestState(previousStore, "pr.com")
+	assert.Nil(t, err)
+	assert.True(t, pullRequestState.SnoozedUntil.IsZero())
+}
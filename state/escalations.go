@@ -0,0 +1,87 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// EscalationState is the record of the escalation steps already sent for a team's pull request.
+// Steps are identified by their delay (ex: 48h0m0s)
+type EscalationState struct {
+	Sent map[string]time.Time `json:",omitempty"`
+	// SentTo is, for the steps that are not completely sent yet, when they were sent to each of their recipients (a channel or a user)
+	SentTo map[string]map[string]time.Time `json:",omitempty"`
+}
+
+// TeamEscalations are the escalation records of a team's pull requests, by pull request link
+type TeamEscalations map[string]*EscalationState
+
+func escalationsKey(team string) string {
+	return fmt.Sprintf("escalations/%s", team)
+}
+
+// GetTeamEscalations returns the persisted escalation records of the team's pull requests.
+// An empty set of records is returned if nothing was persisted
+func GetTeamEscalations(store Store, team string) (TeamEscalations, error) {
+	escalations := TeamEscalations{}
+	if _, err := store.Get(escalationsKey(team), &escalations); err != nil {
+		return nil, err
+	}
+	return escalations, nil
+}
+
+// SetTeamEscalations persists the escalation records of the team's pull requests
+func SetTeamEscalations(store Store, team string, escalations TeamEscalations) error {
+	return store.Set(escalationsKey(team), escalations)
+}
+
+// Get returns the escalation record of the pull request with the given link. It is added to the records if it doesn't exist
+func (escalations TeamEscalations) Get(link string) *EscalationState {
+	escalationState, ok := escalations[link]
+	if !ok || escalationState == nil {
+		escalationState = &EscalationState{}
+		escalations[link] = escalationState
+	}
+	return escalationState
+}
+
+// Prune removes the records of the pull requests that are not open anymore (merged or closed)
+func (escalations TeamEscalations) Prune(openLinks map[string]bool) {
+	for link := range escalations {
+		if !openLinks[link] {
+			delete(escalations, link)
+		}
+	}
+}
+
+// IsSent returns true if the given step was sent to all of its recipients
+func (escalationState *EscalationState) IsSent(step string) bool {
+	_, ok := escalationState.Sent[step]
+	return ok
+}
+
+// MarkSent records that the given step was sent to all of its recipients at the given time
+func (escalationState *EscalationState) MarkSent(step string, now time.Time) {
+	if escalationState.Sent == nil {
+		escalationState.Sent = map[string]time.Time{}
+	}
+	escalationState.Sent[step] = now
+	delete(escalationState.SentTo, step)
+}
+
+// IsSentTo returns true if the given step was sent to the given recipient
+func (escalationState *EscalationState) IsSentTo(step, recipient string) bool {
+	_, ok := escalationState.SentTo[step][recipient]
+	return ok || escalationState.IsSent(step)
+}
+
+// MarkSentTo records that the given step was sent to the given recipient at the given time
+func (escalationState *EscalationState) MarkSentTo(step, recipient string, now time.Time) {
+	if escalationState.SentTo == nil {
+		escalationState.SentTo = map[string]map[string]time.Time{}
+	}
+	if escalationState.SentTo[step] == nil {
+		escalationState.SentTo[step] = map[string]time.Time{}
+	}
+	escalationState.SentTo[step][recipient] = now
+}
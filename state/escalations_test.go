@@ -0,0 +1,59 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscalationState(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryStore()
+
+	escalations, err := GetTeamEscalations(store, "my-team")
+	assert.Nil(t, err)
+	assert.Empty(t, escalations)
+	escalationState := escalations.Get("pr.com")
+	assert.Equal(t, &EscalationState{}, escalationState)
+	assert.False(t, escalationState.IsSent("48h0m0s"))
+
+	escalationState.MarkSent("48h0m0s", time.Now())
+	assert.Nil(t, SetTeamEscalations(store, "my-team", escalations))
+
+	escalations, err = GetTeamEscalations(store, "my-team")
+	assert.Nil(t, err)
+	assert.True(t, escalations.Get("pr.com").IsSent("48h0m0s"))
+	assert.False(t, escalations.Get("pr.com").IsSent("96h0m0s"))
+
+	// Records are kept per team
+	escalations, err = GetTeamEscalations(store, "other-team")
+	assert.Nil(t, err)
+	assert.False(t, escalations.Get("pr.com").IsSent("48h0m0s"))
+}
+
+func TestEscalationStateRecipients(t *testing.T) {
+	t.Parallel()
+
+	escalationState := &EscalationState{}
+	escalationState.MarkSentTo("48h0m0s", "#channel", time.Now())
+	assert.True(t, escalationState.IsSentTo("48h0m0s", "#channel"))
+	assert.False(t, escalationState.IsSentTo("48h0m0s", "@user"))
+	assert.False(t, escalationState.IsSent("48h0m0s"))
+
+	// Once the step is sent to all of its recipients, the recipients are not kept anymore
+	escalationState.MarkSent("48h0m0s", time.Now())
+	assert.True(t, escalationState.IsSentTo("48h0m0s", "@user"))
+	assert.Empty(t, escalationState.SentTo)
+}
+
+func TestPruneTeamEscalations(t *testing.T) {
+	t.Parallel()
+
+	escalations := TeamEscalations{}
+	escalations.Get("open.com").MarkSent("48h0m0s", time.Now())
+	escalations.Get("merged.com").MarkSent("48h0m0s", time.Now())
+	escalations.Prune(map[string]bool{"open.com": true})
+	assert.Len(t, escalations, 1)
+	assert.Contains(t, escalations, "open.com")
+}
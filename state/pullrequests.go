@@ -0,0 +1,128 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PullRequestState represents what users chose to do with a pull request from a message (snooze, claim or skip)
+// and its notification history. Users are identified by their Slack user ID and by their Slack username
+type PullRequestState struct {
+	SnoozedUntil      time.Time            `json:",omitempty"`
+	ClaimedBy         string               `json:",omitempty"`
+	ClaimedByUsername string               `json:",omitempty"`
+	SkippedBy         map[string]time.Time `json:",omitempty"`
+
+	// FirstSeen is when the pull request was first found needing action and Notifications is when and in which category
+	// it was last notified to each destination (ex: a channel or a user)
+	FirstSeen     time.Time               `json:",omitempty"`
+	Notifications map[string]Notification `json:",omitempty"`
+}
+
+// Notification is when a pull request was last notified to a destination and the category (ex: ready_to_merge) it was in
+type Notification struct {
+	Time     time.Time
+	Category string
+}
+
+func pullRequestKey(link string) string {
+	return fmt.Sprintf("pullrequests/%s", link)
+}
+
+// GetPullRequestState returns the persisted state of the pull request with the given link.
+// An empty state is returned if nothing was persisted
+func GetPullRequestState(store Store, link string) (*PullRequestState, error) {
+	pullRequestState := &PullRequestState{}
+	if _, err := store.Get(pullRequestKey(link), pullRequestState); err != nil {
+		return nil, err
+	}
+	return pullRequestState, nil
+}
+
+// SetPullRequestState persists the state of the pull request with the given link
+func SetPullRequestState(store Store, link string, pullRequestState *PullRequestState) error {
+	return store.Set(pullRequestKey(link), pullRequestState)
+}
+
+// UpdatePullRequestState applies the given change to the persisted state of the pull request with the given link.
+// The state is read right before the change so that changes made since it was last read are kept
+func UpdatePullRequestState(store Store, link string, update func(*PullRequestState)) (*PullRequestState, error) {
+	pullRequestState, err := GetPullRequestState(store, link)
+	if err != nil {
+		return nil, err
+	}
+	update(pullRequestState)
+	return pullRequestState, SetPullRequestState(store, link, pullRequestState)
+}
+
+// PrunePullRequestStates removes the states of the pull requests of the given repositories (by link) that are not open anymore
+// (merged or closed). The states of the other repositories' pull requests are kept, they may be handled by other teams
+func PrunePullRequestStates(store Store, repositoryLinks []string, openLinks map[string]bool) error {
+	for _, repositoryLink := range repositoryLinks {
+		keys, err := store.Keys(pullRequestKey(strings.TrimSuffix(repositoryLink, "/") + "/"))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !openLinks[strings.TrimPrefix(key, pullRequestKey(""))] {
+				if err = store.Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetNotification returns when and in which category the pull request was last notified to the given destination.
+// An empty notification is returned if it was never notified to it
+func (pullRequestState *PullRequestState) GetNotification(destination string) Notification {
+	return pullRequestState.Notifications[destination]
+}
+
+// SetNotification records that the pull request was notified to the given destination at the given time, in the given category
+func (pullRequestState *PullRequestState) SetNotification(destination, category string, now time.Time) {
+	if pullRequestState.Notifications == nil {
+		pullRequestState.Notifications = map[string]Notification{}
+	}
+	pullRequestState.Notifications[destination] = Notification{Time: now, Category: category}
+}
+
+// IsSnoozed returns true if the pull request is snoozed at the given time
+func (pullRequestState *PullRequestState) IsSnoozed(now time.Time) bool {
+	return pullRequestState.SnoozedUntil.After(now)
+}
+
+// IsClaimedBy returns true if the pull request was claimed by the user with the given ID or username
+func (pullRequestState *PullRequestState) IsClaimedBy(userID, username string) bool {
+	return (userID != "" && pullRequestState.ClaimedBy == userID) || (username != "" && pullRequestState.ClaimedByUsername == username)
+}
+
+// Claim marks the pull request as claimed by the given user
+func (pullRequestState *PullRequestState) Claim(userID, username string) {
+	pullRequestState.ClaimedBy = userID
+	pullRequestState.ClaimedByUsername = username
+}
+
+// IsSkippedBy returns true if the user with the given ID or username chose to skip the pull request since its last update
+func (pullRequestState *PullRequestState) IsSkippedBy(userID, username string, updateTime time.Time) bool {
+	for _, user := range []string{userID, username} {
+		if skipTime, ok := pullRequestState.SkippedBy[user]; ok && user != "" && !skipTime.Before(updateTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Skip marks the pull request as skipped by the given user at the given time
+func (pullRequestState *PullRequestState) Skip(userID, username string, now time.Time) {
+	if pullRequestState.SkippedBy == nil {
+		pullRequestState.SkippedBy = map[string]time.Time{}
+	}
+	for _, user := range []string{userID, username} {
+		if user != "" {
+			pullRequestState.SkippedBy[user] = now
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullRequestState(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	pullRequestState, err := GetPullRequestState(store, "pr.com")
+	assert.Nil(t, err)
+	assert.Equal(t, &PullRequestState{}, pullRequestState)
+
+	now := time.Now()
+	pullRequestState.SnoozedUntil = now.Add(time.Hour)
+	pullRequestState.Claim("U1", "jdoe")
+	pullRequestState.Skip("U2", "jdoe2", now)
+	assert.Nil(t, SetPullRequestState(store, "pr.com", pullRequestState))
+
+	pullRequestState, err = GetPullRequestState(store, "pr.com")
+	assert.Nil(t, err)
+	assert.True(t, pullRequestState.IsSnoozed(now))
+	assert.False(t, pullRequestState.IsSnoozed(now.Add(2*time.Hour)))
+	assert.True(t, pullRequestState.IsClaimedBy("U1", ""))
+	assert.True(t, pullRequestState.IsClaimedBy("", "jdoe"))
+	assert.False(t, pullRequestState.IsClaimedBy("U2", "jdoe2"))
+	assert.False(t, pullRequestState.IsClaimedBy("", ""))
+	assert.True(t, pullRequestState.IsSkippedBy("U2", "", now.Add(-time.Hour)))
+	assert.True(t, pullRequestState.IsSkippedBy("", "jdoe2", now.Add(-time.Hour)))
+	assert.False(t, pullRequestState.IsSkippedBy("U2", "jdoe2", now.Add(time.Hour))) // Updated after the skip
+	assert.False(t, pullRequestState.IsSkippedBy("U1", "jdoe", now.Add(-time.Hour)))
+}
+
+func TestUpdatePullRequestState(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryStore()
+
+	now := time.Now()
+	SetPullRequestState(store, "pr.com", &PullRequestState{FirstSeen: now.Add(-time.Hour)})
+	pullRequestState, err := UpdatePullRequestState(store, "pr.com", func(pullRequestState *PullRequestState) {
+		pullRequestState.SetNotification("#channel", "ready_to_review", now)
+	})
+	assert.Nil(t, err)
+	assert.True(t, now.Equal(pullRequestState.GetNotification("#channel").Time))
+
+	pullRequestState, err = GetPullRequestState(store, "pr.com")
+	assert.Nil(t, err)
+	assert.True(t, now.Add(-time.Hour).Equal(pullRequestState.FirstSeen))
+	assert.True(t, now.Equal(pullRequestState.GetNotification("#channel").Time))
+	assert.Equal(t, "ready_to_review", pullRequestState.GetNotification("#channel").Category)
+	// Notifications are recorded per destination
+	assert.True(t, pullRequestState.GetNotification("U123").Time.IsZero())
+}
+
+func TestPrunePullRequestStates(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryStore()
+
+	for _, link := range []string{"https://github.com/org/repo/pull/1", "https://github.com/org/repo/pull/2", "https://github.com/org/repo-two/pull/1", "https://github.com/org/other/pull/1"} {
+		SetPullRequestState(store, link, &PullRequestState{FirstSeen: time.Now()})
+	}
+	assert.Nil(t, PrunePullRequestStates(store, []string{"https://github.com/org/repo", "https://github.com/org/repo-two"}, map[string]bool{
+		"https://github.com/org/repo/pull/2": true,
+	}))
+
+	keys, err := store.Keys("pullrequests/")
+	assert.Nil(t, err)
+	// The pull requests of the repositories handled by other teams are kept
+	assert.Equal(t, []string{"pullrequests/https://github.com/org/other/pull/1", "pullrequests/https://github.com/org/repo/pull/2"}, keys)
+}
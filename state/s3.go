@@ -0,0 +1,63 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// s3Document is a state document stored as an S3 object
+type s3Document struct {
+	client s3iface.S3API
+	bucket string
+	key    string
+}
+
+// newS3Store returns a Store that persists its values in the S3 object at the given path (s3://bucket/path/to/state)
+func newS3Store(client s3iface.S3API, s3Path string) (Store, error) {
+	splitS3Path, err := url.Parse(s3Path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the given S3 state path: %v", err)
+	}
+	key := strings.TrimPrefix(splitS3Path.Path, "/")
+	if splitS3Path.Host == "" || key == "" {
+		return nil, fmt.Errorf("Invalid S3 state path %q. The expected format is s3://bucket/path/to/state", s3Path)
+	}
+	return &documentStore{document: &s3Document{client: client, bucket: splitS3Path.Host, key: key}}, nil
+}
+
+func (document *s3Document) load() ([]byte, error) {
+	resp, err := document.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(document.bucket),
+		Key:    aws.String(document.key),
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to download the state file from S3, %v", err)
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the state file downloaded from S3, %v", err)
+	}
+	return content, nil
+}
+
+func (document *s3Document) save(content []byte) error {
+	if _, err := document.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(document.bucket),
+		Key:    aws.String(document.key),
+		Body:   bytes.NewReader(content),
+	}); err != nil {
+		return fmt.Errorf("Failed to upload the state file to S3, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockedS3Client struct {
+	s3iface.S3API
+	t       *testing.T
+	objects map[string][]byte
+	err     error
+	// gets and puts count the downloads and uploads of the state
+	gets, puts int
+}
+
+func (mock *mockedS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	assert.Equal(mock.t, "bucket-name", *input.Bucket)
+	mock.gets++
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	content, ok := mock.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(content))}, nil
+}
+
+func (mock *mockedS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	assert.Equal(mock.t, "bucket-name", *input.Bucket)
+	content, _ := ioutil.ReadAll(input.Body)
+	mock.objects[*input.Key] = content
+	mock.puts++
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3Store(t *testing.T) {
+	t.Parallel()
+
+	client := &mockedS3Client{t: t, objects: map[string][]byte{}}
+	store, err := newS3Store(client, "s3://bucket-name/path/to/state")
+	assert.Nil(t, err)
+	testStore(t, store)
+	assert.Contains(t, client.objects, "path/to/state")
+
+	// Values are persisted between instances
+	otherStore, _ := newS3Store(client, "s3://bucket-name/path/to/state")
+	value := &testValue{}
+	found, err := otherStore.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "first", Count: 2}, value)
+}
+
+func TestS3StoreFlush(t *testing.T) {
+	t.Parallel()
+
+	client := &mockedS3Client{t: t, objects: map[string][]byte{}}
+	store, _ := newS3Store(client, "s3://bucket-name/state")
+
+	// The state is downloaded once and uploaded once, when flushed
+	for _, key := range []string{"key1", "key2", "key3"} {
+		store.Get(key, &testValue{})
+		assert.Nil(t, store.Set(key, &testValue{Name: key}))
+	}
+	assert.Equal(t, 1, client.gets)
+	assert.Equal(t, 0, client.puts)
+	assert.Nil(t, store.Flush())
+	assert.Equal(t, 1, client.puts)
+
+	// The state is downloaded again after a flush, and not uploaded if it wasn't modified
+	value := &testValue{}
+	found, err := store.Get("key2", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "key2"}, value)
+	assert.Equal(t, 2, client.gets)
+	assert.Nil(t, store.Flush())
+	assert.Equal(t, 1, client.puts)
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := newS3Store(&mockedS3Client{t: t}, "s3://bucket-name")
+	assert.EqualError(t, err, `Invalid S3 state path "s3://bucket-name". The expected format is s3://bucket/path/to/state`)
+
+	store, _ := newS3Store(&mockedS3Client{t: t, err: fmt.Errorf("AccessDenied")}, "s3://bucket-name/state")
+	_, err = store.Get("key", &testValue{})
+	assert.EqualError(t, err, "Failed to download the state file from S3, AccessDenied")
+}
@@ -0,0 +1,332 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Store is the interface that wraps the Get, Set, Delete, Keys and Flush methods.
+// It persists JSON-serializable values between runs
+type Store interface {
+	// Get reads the value at the given key into the given pointer. It returns false if the key is not set
+	Get(key string, value interface{}) (bool, error)
+	// Set writes the given value at the given key. The value may only be persisted on the next flush
+	Set(key string, value interface{}) error
+	// Delete removes the value at the given key. The removal may only be persisted on the next flush
+	Delete(key string) error
+	// Keys returns the sorted keys starting with the given prefix
+	Keys(prefix string) ([]string, error)
+	// Flush persists the values written since the last flush
+	Flush() error
+}
+
+// NewStore returns a Store that persists its values in the file at the given path.
+// Paths starting with s3:// (ex: s3://bucket/path/to/state) are stored in S3. Without a path, the values are only kept in memory
+func NewStore(path string) (Store, error) {
+	if path == "" {
+		log.Warningln("The state path is not set. The state is only kept in memory and is lost when the program exits")
+		return NewMemoryStore(), nil
+	}
+	if strings.HasPrefix(path, "s3://") {
+		sess := session.Must(session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+		return newS3Store(s3.New(sess), path)
+	}
+	return &documentStore{document: &fileDocument{path: path}}, nil
+}
+
+// NewMemoryStore returns a Store that keeps its values in memory. Values are lost when the program exits
+func NewMemoryStore() Store {
+	return &memoryStore{values: map[string][]byte{}}
+}
+
+// stateDocument is where a documentStore persists all of its values, as a single JSON object
+type stateDocument interface {
+	// load returns the document's content. It is nil if the document doesn't exist yet
+	load() ([]byte, error)
+	save(content []byte) error
+}
+
+// documentStore is a Store that reads and writes all of its values in a single JSON document.
+// The document is loaded on first access and written on flush. Flushing also drops the loaded values so that
+// the document is read again on the next access (ex: on the next run in serve mode)
+type documentStore struct {
+	document stateDocument
+	values   map[string]json.RawMessage
+	modified bool
+	mutex    sync.Mutex
+}
+
+// load returns the document's values, reading the document if it was not loaded since the last flush
+func (store *documentStore) load() (map[string]json.RawMessage, error) {
+	if store.values != nil {
+		return store.values, nil
+	}
+	values := map[string]json.RawMessage{}
+	content, err := store.document.load()
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		if err = json.Unmarshal(content, &values); err != nil {
+			return nil, fmt.Errorf("Unable to parse the state file: %v", err)
+		}
+	}
+	store.values = values
+	return values, nil
+}
+
+func (store *documentStore) Get(key string, value interface{}) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	values, err := store.load()
+	if err != nil {
+		return false, err
+	}
+	rawValue, ok := values[key]
+	if !ok {
+		return false, nil
+	}
+	if err = json.Unmarshal(rawValue, value); err != nil {
+		return false, fmt.Errorf("Unable to parse the state value at %s: %v", key, err)
+	}
+	return true, nil
+}
+
+func (store *documentStore) Set(key string, value interface{}) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	values, err := store.load()
+	if err != nil {
+		return err
+	}
+	if values[key], err = json.Marshal(value); err != nil {
+		return fmt.Errorf("Unable to serialize the state value at %s: %v", key, err)
+	}
+	store.modified = true
+	return nil
+}
+
+func (store *documentStore) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	values, err := store.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[key]; ok {
+		delete(values, key)
+		store.modified = true
+	}
+	return nil
+}
+
+func (store *documentStore) Keys(prefix string) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	values, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return filterKeys(keys, prefix), nil
+}
+
+func (store *documentStore) Flush() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.modified {
+		content, err := json.MarshalIndent(store.values, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Unable to serialize the state: %v", err)
+		}
+		if err = store.document.save(content); err != nil {
+			// The values are kept so that the next flush tries again
+			return err
+		}
+	}
+	store.values, store.modified = nil, false
+	return nil
+}
+
+type fileDocument struct {
+	path string
+}
+
+func (document *fileDocument) load() ([]byte, error) {
+	content, err := ioutil.ReadFile(document.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read the state file: %v", err)
+	}
+	return content, nil
+}
+
+func (document *fileDocument) save(content []byte) error {
+	// Write to a temporary file first so that the state is never left half-written
+	tempFile, err := ioutil.TempFile(filepath.Dir(document.path), filepath.Base(document.path))
+	if err != nil {
+		return fmt.Errorf("Unable to create a temporary state file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err = tempFile.Write(content); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("Unable to write the state file: %v", err)
+	}
+	if err = tempFile.Close(); err != nil {
+		return fmt.Errorf("Unable to write the state file: %v", err)
+	}
+	if err = os.Rename(tempFile.Name(), document.path); err != nil {
+		return fmt.Errorf("Unable to write the state file: %v", err)
+	}
+	return nil
+}
+
+type memoryStore struct {
+	values map[string][]byte
+	mutex  sync.Mutex
+}
+
+func (store *memoryStore) Get(key string, value interface{}) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rawValue, ok := store.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(rawValue, value)
+}
+
+func (store *memoryStore) Set(key string, value interface{}) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	store.values[key] = rawValue
+	return nil
+}
+
+func (store *memoryStore) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.values, key)
+	return nil
+}
+
+func (store *memoryStore) Keys(prefix string) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	keys := []string{}
+	for key := range store.values {
+		keys = append(keys, key)
+	}
+	return filterKeys(keys, prefix), nil
+}
+
+func (store *memoryStore) Flush() error {
+	return nil
+}
+
+// filterKeys returns the given keys that start with the given prefix, sorted and without duplicates
+func filterKeys(keys []string, prefix string) []string {
+	filteredKeys := []string{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) && !seen[key] {
+			filteredKeys = append(filteredKeys, key)
+			seen[key] = true
+		}
+	}
+	sort.Strings(filteredKeys)
+	return filteredKeys
+}
+
+// NewOverlayStore returns a Store that reads the values of the given store and keeps the values it writes in memory.
+// The given store is never modified
+func NewOverlayStore(store Store) Store {
+	return &overlayStore{store: store, overlay: &memoryStore{values: map[string][]byte{}}, deleted: map[string]bool{}}
+}
+
+type overlayStore struct {
+	store   Store
+	overlay *memoryStore
+	// deleted are the keys deleted from the overlay store, they are hidden from the given store
+	deleted map[string]bool
+	mutex   sync.Mutex
+}
+
+func (store *overlayStore) Get(key string, value interface{}) (bool, error) {
+	if store.isDeleted(key) {
+		return false, nil
+	}
+	if found, err := store.overlay.Get(key, value); found || err != nil {
+		return found, err
+	}
+	return store.store.Get(key, value)
+}
+
+func (store *overlayStore) Set(key string, value interface{}) error {
+	store.mutex.Lock()
+	delete(store.deleted, key)
+	store.mutex.Unlock()
+	return store.overlay.Set(key, value)
+}
+
+func (store *overlayStore) Delete(key string) error {
+	store.mutex.Lock()
+	store.deleted[key] = true
+	store.mutex.Unlock()
+	return store.overlay.Delete(key)
+}
+
+func (store *overlayStore) Keys(prefix string) ([]string, error) {
+	keys, err := store.store.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	overlayKeys, _ := store.overlay.Keys(prefix)
+	visibleKeys := []string{}
+	for _, key := range append(keys, overlayKeys...) {
+		if !store.isDeleted(key) {
+			visibleKeys = append(visibleKeys, key)
+		}
+	}
+	return filterKeys(visibleKeys, prefix), nil
+}
+
+func (store *overlayStore) isDeleted(key string) bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.deleted[key]
+}
+
+// Flush does nothing: the values written to an overlay store are never persisted
+func (store *overlayStore) Flush() error {
+	return nil
+}
@@ -0,0 +1,141 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValue struct {
+	Name  string
+	Count int
+}
+
+func TestNewStoreWithoutPath(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewStore("")
+	assert.Nil(t, err)
+	testStore(t, store)
+}
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	tempDir, _ := ioutil.TempDir("", "prr-state")
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewStore(path.Join(tempDir, "state.json"))
+	assert.Nil(t, err)
+	testStore(t, store)
+
+	// Values are persisted between instances
+	otherStore, _ := NewStore(path.Join(tempDir, "state.json"))
+	value := &testValue{}
+	found, err := otherStore.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "first", Count: 2}, value)
+}
+
+func TestFileStoreWithInvalidFile(t *testing.T) {
+	t.Parallel()
+
+	tempDir, _ := ioutil.TempDir("", "prr-state")
+	defer os.RemoveAll(tempDir)
+	statePath := path.Join(tempDir, "state.json")
+	ioutil.WriteFile(statePath, []byte("not json"), 0644)
+
+	store, _ := NewStore(statePath)
+	_, err := store.Get("key", &testValue{})
+	assert.Contains(t, err.Error(), "Unable to parse the state file")
+	assert.Contains(t, store.Set("key", &testValue{}).Error(), "Unable to parse the state file")
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	testStore(t, NewMemoryStore())
+}
+
+func TestOverlayStore(t *testing.T) {
+	t.Parallel()
+
+	testStore(t, NewOverlayStore(NewMemoryStore()))
+
+	underlyingStore := NewMemoryStore()
+	underlyingStore.Set("key1", &testValue{Name: "underlying", Count: 1})
+	store := NewOverlayStore(underlyingStore)
+
+	value := &testValue{}
+	found, err := store.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "underlying", Count: 1}, value)
+
+	assert.Nil(t, store.Set("key1", &testValue{Name: "overlay", Count: 2}))
+	found, err = store.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "overlay", Count: 2}, value)
+
+	// Deleted values are hidden
+	underlyingStore.Set("key2", &testValue{Name: "underlying", Count: 1})
+	assert.Nil(t, store.Delete("key2"))
+	found, err = store.Get("key2", value)
+	assert.False(t, found)
+	assert.Nil(t, err)
+	keys, err := store.Keys("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key1"}, keys)
+
+	// The underlying store is not modified
+	found, err = underlyingStore.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "underlying", Count: 1}, value)
+	keys, _ = underlyingStore.Keys("")
+	assert.Equal(t, []string{"key1", "key2"}, keys)
+}
+
+func testStore(t *testing.T, store Store) {
+	value := &testValue{}
+	found, err := store.Get("key1", value)
+	assert.False(t, found)
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.Set("key1", &testValue{Name: "first", Count: 1}))
+	assert.Nil(t, store.Set("key2", &testValue{Name: "second", Count: 1}))
+	assert.Nil(t, store.Set("key1", &testValue{Name: "first", Count: 2}))
+
+	found, err = store.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "first", Count: 2}, value)
+
+	found, err = store.Get("key2", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "second", Count: 1}, value)
+
+	keys, err := store.Keys("key")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key1", "key2"}, keys)
+	assert.Nil(t, store.Delete("key2"))
+	assert.Nil(t, store.Delete("missing"))
+	found, err = store.Get("key2", value)
+	assert.False(t, found)
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.Flush())
+	found, err = store.Get("key1", value)
+	assert.True(t, found)
+	assert.Nil(t, err)
+	assert.Equal(t, &testValue{Name: "first", Count: 2}, value)
+	keys, err = store.Keys("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key1"}, keys)
+}
@@ -0,0 +1,32 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// TeamState is the record of a team's runs
+type TeamState struct {
+	// LastRun is when the team's pull requests were last notified
+	LastRun time.Time `json:",omitempty"`
+	// LastDigest is when all of the team's pull requests were last notified in delta mode
+	LastDigest time.Time `json:",omitempty"`
+}
+
+func teamKey(team string) string {
+	return fmt.Sprintf("teams/%s", team)
+}
+
+// GetTeamState returns the persisted record of the given team's runs. An empty record is returned if nothing was persisted
+func GetTeamState(store Store, team string) (*TeamState, error) {
+	teamState := &TeamState{}
+	if _, err := store.Get(teamKey(team), teamState); err != nil {
+		return nil, err
+	}
+	return teamState, nil
+}
+
+// SetTeamState persists the record of the given team's runs
+func SetTeamState(store Store, team string, teamState *TeamState) error {
+	return store.Set(teamKey(team), teamState)
+}
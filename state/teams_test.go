@@ -0,0 +1,24 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamState(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryStore()
+
+	teamState, err := GetTeamState(store, "my-team")
+	assert.Nil(t, err)
+	assert.Equal(t, &TeamState{}, teamState)
+
+	now := time.Now()
+	assert.Nil(t, SetTeamState(store, "my-team", &TeamState{LastRun: now, LastDigest: now.Add(-time.Hour)}))
+	teamState, err = GetTeamState(store, "my-team")
+	assert.Nil(t, err)
+	assert.True(t, now.Equal(teamState.LastRun))
+	assert.True(t, now.Add(-time.Hour).Equal(teamState.LastDigest))
+}
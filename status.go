@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// healthTimeout is the longest time the daemon can go without a heartbeat before being considered unhealthy.
+// The time spent running a team is not counted
+const healthTimeout = 30 * time.Minute
+
+// Outcomes of a team's run
+const (
+	successOutcome = "success"
+	failureOutcome = "failure"
+)
+
+// teamStatus is the outcome of a team's last run
+type teamStatus struct {
+	Team            string            `json:"team"`
+	LastRun         *time.Time        `json:"last_run,omitempty"`
+	RunningSince    *time.Time        `json:"running_since,omitempty"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Outcome         string            `json:"outcome,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	HostErrors      map[string]string `json:"host_errors,omitempty"`
+	NextRun         *time.Time        `json:"next_run,omitempty"`
+}
+
+// statusResponse is the body of the /status endpoint
+type statusResponse struct {
+	StartedAt     time.Time     `json:"started_at"`
+	Ready         bool          `json:"ready"`
+	LastHeartbeat *time.Time    `json:"last_heartbeat,omitempty"`
+	ConfigError   string        `json:"config_error,omitempty"`
+	Teams         []*teamStatus `json:"teams"`
+}
+
+// status keeps the state of the program reported by the /healthz, /readyz and /status endpoints
+type status struct {
+	mutex sync.Mutex
+
+	startedAt time.Time
+	ready     bool
+	// heartbeat is the last time the daemon's loop was active. It is zero when the program doesn't run as a daemon
+	heartbeat time.Time
+	// running is true while a team is run, the heartbeat is then only updated once the run is over
+	running     bool
+	configError string
+	teams       map[string]*teamStatus
+}
+
+func newStatus() *status {
+	return &status{startedAt: time.Now(), teams: map[string]*teamStatus{}}
+}
+
+func (status *status) setReady() {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.ready = true
+}
+
+func (status *status) beat(now time.Time) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.heartbeat = now
+}
+
+// setConfigError records the error of the last configuration reload. A nil error clears it
+func (status *status) setConfigError(err error) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.configError = ""
+	if err != nil {
+		status.configError = err.Error()
+	}
+}
+
+// setNextRuns records the next run of each team. Teams that are not in the given map are forgotten
+func (status *status) setNextRuns(nextRuns map[string]time.Time) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	teams := map[string]*teamStatus{}
+	for team, nextRun := range nextRuns {
+		teamStatus := status.getTeam(team)
+		teamStatus.NextRun = nil
+		if !nextRun.IsZero() {
+			nextRun := nextRun
+			teamStatus.NextRun = &nextRun
+		}
+		teams[team] = teamStatus
+	}
+	status.teams = teams
+}
+
+// startRun records that a team's run started
+func (status *status) startRun(team string, start time.Time) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.running = true
+	status.getTeam(team).RunningSince = &start
+}
+
+// recordRun records the outcome of a team's run. Errors of the hosts are reported separately
+func (status *status) recordRun(team string, start, end time.Time, err error) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	status.running = false
+	teamStatus := status.getTeam(team)
+	teamStatus.LastRun, teamStatus.RunningSince = &start, nil
+	teamStatus.DurationSeconds = end.Sub(start).Seconds()
+	teamStatus.Outcome, teamStatus.Error, teamStatus.HostErrors = successOutcome, "", nil
+	if err != nil {
+		teamStatus.Outcome, teamStatus.Error = failureOutcome, err.Error()
+	}
+	if errors, ok := err.(hostErrors); ok {
+		teamStatus.HostErrors = map[string]string{}
+		for hostName, hostError := range errors {
+			teamStatus.HostErrors[hostName] = hostError.Error()
+		}
+	}
+}
+
+func (status *status) getTeam(team string) *teamStatus {
+	if _, ok := status.teams[team]; !ok {
+		status.teams[team] = &teamStatus{Team: team}
+	}
+	return status.teams[team]
+}
+
+// isHealthy returns false if the daemon's loop has not been active recently. It is healthy while a team is run,
+// so that slow runs (ex: many repositories or rate limited hosts) don't get the daemon restarted
+func (status *status) isHealthy(now time.Time) bool {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	return status.heartbeat.IsZero() || status.running || now.Sub(status.heartbeat) < healthTimeout
+}
+
+func (status *status) isReady() bool {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+	return status.ready
+}
+
+func (status *status) healthzHandler(writer http.ResponseWriter, request *http.Request) {
+	if !status.isHealthy(time.Now()) {
+		http.Error(writer, "The scheduler is not running", http.StatusServiceUnavailable)
+		return
+	}
+	writer.Write([]byte("ok"))
+}
+
+func (status *status) readyzHandler(writer http.ResponseWriter, request *http.Request) {
+	if !status.isReady() {
+		http.Error(writer, "Not ready", http.StatusServiceUnavailable)
+		return
+	}
+	writer.Write([]byte("ok"))
+}
+
+func (status *status) statusHandler(writer http.ResponseWriter, request *http.Request) {
+	status.mutex.Lock()
+	response := statusResponse{StartedAt: status.startedAt, Ready: status.ready, ConfigError: status.configError, Teams: []*teamStatus{}}
+	if !status.heartbeat.IsZero() {
+		heartbeat := status.heartbeat
+		response.LastHeartbeat = &heartbeat
+	}
+	for _, teamStatus := range status.teams {
+		teamStatusCopy := *teamStatus
+		response.Teams = append(response.Teams, &teamStatusCopy)
+	}
+	status.mutex.Unlock()
+	sort.Slice(response.Teams, func(i, j int) bool { return response.Teams[i].Team < response.Teams[j].Team })
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
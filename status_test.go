@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusEndpoints(t *testing.T) {
+	t.Parallel()
+
+	status := newStatus()
+	get := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		return recorder
+	}
+	assert.Equal(t, http.StatusOK, get(status.healthzHandler).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(status.readyzHandler).Code)
+
+	status.setReady()
+	assert.Equal(t, http.StatusOK, get(status.readyzHandler).Code)
+
+	// The daemon is unhealthy when its loop is stuck
+	status.beat(time.Now().Add(-time.Hour))
+	assert.Equal(t, http.StatusServiceUnavailable, get(status.healthzHandler).Code)
+	status.beat(time.Now())
+	assert.Equal(t, http.StatusOK, get(status.healthzHandler).Code)
+
+	// The time spent running a team is not counted
+	status.beat(time.Now().Add(-time.Hour))
+	status.startRun("team1", time.Now().Add(-time.Hour))
+	assert.Equal(t, http.StatusOK, get(status.healthzHandler).Code)
+	running := &statusResponse{}
+	assert.Nil(t, json.Unmarshal(get(status.statusHandler).Body.Bytes(), running))
+	assert.NotNil(t, running.Teams[0].RunningSince)
+	status.recordRun("team1", time.Now().Add(-time.Hour), time.Now(), nil)
+	assert.Equal(t, http.StatusServiceUnavailable, get(status.healthzHandler).Code)
+	status.beat(time.Now())
+
+	start := time.Date(2019, 7, 22, 9, 0, 0, 0, time.UTC)
+	nextRun := start.Add(24 * time.Hour)
+	status.setNextRuns(map[string]time.Time{"team1": nextRun, "team2": {}, "removed": nextRun})
+	status.setNextRuns(map[string]time.Time{"team1": nextRun, "team2": {}})
+	status.recordRun("team1", start, start.Add(1500*time.Millisecond), nil)
+	status.recordRun("team2", start, start.Add(time.Second), hostErrors{"Github": errors.New("Bad credentials")})
+	status.setConfigError(errors.New("Invalid configuration"))
+
+	response := &statusResponse{}
+	recorder := get(status.statusHandler)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), response))
+	assert.True(t, response.Ready)
+	assert.Equal(t, "Invalid configuration", response.ConfigError)
+	assert.Len(t, response.Teams, 2)
+
+	team1 := response.Teams[0]
+	assert.Equal(t, "team1", team1.Team)
+	assert.Equal(t, successOutcome, team1.Outcome)
+	assert.Equal(t, 1.5, team1.DurationSeconds)
+	assert.True(t, start.Equal(*team1.LastRun))
+	assert.Nil(t, team1.RunningSince)
+	assert.True(t, nextRun.Equal(*team1.NextRun))
+
+	team2 := response.Teams[1]
+	assert.Equal(t, failureOutcome, team2.Outcome)
+	assert.Equal(t, "Error while fetching repositories from Github: Bad credentials", team2.Error)
+	assert.Equal(t, map[string]string{"Github": "Bad credentials"}, team2.HostErrors)
+	assert.Nil(t, team2.NextRun)
+}
@@ -1,6 +1,16 @@
 package utilities
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
 
 // GetEnv returns the value of an environment variable or a default if it's not set.
 func GetEnv(key, defaultValue string) string {
@@ -11,6 +21,16 @@ func GetEnv(key, defaultValue string) string {
 	return value
 }
 
+// FirstNonEmpty returns the first of the given values that is not empty.
+func FirstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
 // Unique returns the given slice without duplicates.
 func Unique(stringSlice []string) []string {
 	keys := make(map[string]bool)
@@ -23,3 +43,33 @@ func Unique(stringSlice []string) []string {
 	}
 	return list
 }
+
+// NormalizeName returns the given name in lower case, without accents and without any non-letter characters.
+// It is used to compare names coming from different sources
+func NormalizeName(name string) string {
+	transformChain := transform.Chain(norm.NFD, transform.RemoveFunc(isMn), norm.NFC)
+	result, _, _ := transform.String(transformChain, name)
+	return strings.ToLower(regexp.MustCompile("[^A-Za-z]").ReplaceAllString(result, ""))
+}
+
+func isMn(r rune) bool {
+	return unicode.Is(unicode.Mn, r) // Mn: nonspacing marks
+}
+
+// HumanizeDuration returns the largest unit of the given duration in a human readable format (ex: 3 days).
+func HumanizeDuration(duration time.Duration) string {
+	var format = func(value int, unit string) string {
+		if value == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", value, unit)
+	}
+	switch {
+	case duration >= 24*time.Hour:
+		return format(int(duration/(24*time.Hour)), "day")
+	case duration >= time.Hour:
+		return format(int(duration/time.Hour), "hour")
+	default:
+		return format(int(duration/time.Minute), "minute")
+	}
+}
@@ -0,0 +1,19 @@
+package utilities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "0 minutes", HumanizeDuration(0))
+	assert.Equal(t, "1 minute", HumanizeDuration(time.Minute))
+	assert.Equal(t, "59 minutes", HumanizeDuration(59*time.Minute))
+	assert.Equal(t, "1 hour", HumanizeDuration(90*time.Minute))
+	assert.Equal(t, "23 hours", HumanizeDuration(23*time.Hour))
+	assert.Equal(t, "3 days", HumanizeDuration(80*time.Hour))
+}